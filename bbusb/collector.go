@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
 )
 
 // IsPresent returns whether a BitBabbler device is connected and its device list.
@@ -13,8 +15,8 @@ func IsPresent() (bool, []DeviceInfo, error) {
 }
 
 // ReadBitsOnce opens the device (if present), reads the requested number of bits,
-// and returns the data as bytes. The last byte is masked so the buffer contains
-// exactly the requested number of bits (MSB-first in each byte).
+// and returns them most significant bit first, with the unused low bits of
+// the last byte zeroed.
 //
 // Parameters:
 // - bitrate: MPSSE clock in Hz (e.g., 2_500_000). If 0, a conservative default is used.
@@ -29,7 +31,7 @@ func ReadBitsOnce(ctx context.Context, bits int, bitrate uint, latencyMs uint8)
 	}
 	defer sess.Close()
 
-	numBytes := (bits + 7) / 8
+	numBytes := bitpack.BytesFor(bits)
 	buf := make([]byte, numBytes)
 	got, err := sess.ReadRandom(ctx, buf)
 	if err != nil {
@@ -38,11 +40,7 @@ func ReadBitsOnce(ctx context.Context, bits int, bitrate uint, latencyMs uint8)
 	if got < numBytes {
 		buf = buf[:got]
 	}
-	// Mask excess bits in the final byte to exactly match the requested bit count
-	if bits%8 != 0 && len(buf) > 0 {
-		excess := 8 - (bits % 8)
-		buf[len(buf)-1] &= 0xFF >> excess
-	}
+	bitpack.Mask(buf, bits)
 	return buf, nil
 }
 
@@ -52,8 +50,8 @@ type ReadResult struct {
 	Timestamp time.Time
 	// Number of bits the collector attempted to read.
 	BitsRequested int
-	// Data contains ceiling(BitsRequested/8) bytes with the last byte masked
-	// so the total bits equal BitsRequested.
+	// Data contains ceiling(BitsRequested/8) bytes; unused low bits of the
+	// last byte are zero.
 	Data []byte
 	// Err is non-nil if the read failed.
 	Err error
@@ -81,7 +79,7 @@ func StartBitCollector(ctx context.Context, bits int, interval time.Duration, bi
 	}
 
 	out := make(chan ReadResult)
-	numBytes := (bits + 7) / 8
+	numBytes := bitpack.BytesFor(bits)
 
 	go func() {
 		defer close(out)
@@ -103,9 +101,8 @@ func StartBitCollector(ctx context.Context, bits int, interval time.Duration, bi
 			if err == nil && n < numBytes {
 				buf = buf[:n]
 			}
-			if err == nil && bits%8 != 0 && len(buf) > 0 {
-				excess := 8 - (bits % 8)
-				buf[len(buf)-1] &= 0xFF >> excess
+			if err == nil {
+				bitpack.Mask(buf, bits)
 			}
 
 			select {
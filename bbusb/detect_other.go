@@ -0,0 +1,37 @@
+//go:build !windows
+
+package bbusb
+
+import (
+	"fmt"
+
+	"github.com/google/gousb"
+)
+
+// IsBitBabblerConnected returns whether a BitBabbler device (VID 0x0403, PID 0x7840)
+// is present and a slice of device infos.
+//
+// Non-Windows implementation notes:
+// - Enumerates USB device descriptors via libusb without opening any device
+// - DevicePath is "usb:<bus>:<address>", which changes when the device is replugged
+func IsBitBabblerConnected() (bool, []DeviceInfo, error) {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	var results []DeviceInfo
+	_, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if desc.Vendor == gousb.ID(ftdiVendorID) && desc.Product == gousb.ID(bbProductID) {
+			results = append(results, DeviceInfo{
+				DevicePath:   fmt.Sprintf("usb:%03d:%03d", desc.Bus, desc.Address),
+				HardwareIDs:  []string{fmt.Sprintf("USB\\VID_%04X&PID_%04X", ftdiVendorID, bbProductID)},
+				FriendlyName: "BitBabbler",
+			})
+		}
+		// Never open: enumeration only needs the descriptor.
+		return false
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return len(results) > 0, results, nil
+}
@@ -60,18 +60,6 @@ var (
 	procSetupDiDestroyDeviceInfoList      = modSetupapi.NewProc("SetupDiDestroyDeviceInfoList")
 )
 
-// DeviceInfo contains key metadata for a detected BitBabbler device.
-//
-// Fields may be empty if not available on the current system.
-type DeviceInfo struct {
-	// DevicePath is the system path to the device interface, e.g. \\?\usb#vid_0403&pid_7840#... (if available).
-	DevicePath string
-	// HardwareIDs is the list of hardware IDs from the registry, e.g. ["USB\\VID_0403&PID_7840", ...].
-	HardwareIDs []string
-	// FriendlyName is a human-friendly device label if present.
-	FriendlyName string
-}
-
 // IsBitBabblerConnected returns whether a BitBabbler device (VID 0x0403, PID 0x7840)
 // is present and a slice of device infos.
 //
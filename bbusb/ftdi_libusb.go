@@ -1,5 +1,3 @@
-//go:build windows
-
 package bbusb
 
 import (
@@ -226,10 +224,11 @@ func OpenBitBabbler(bitrate uint, latencyMs uint8) (*DeviceSession, error) {
 	return s, nil
 }
 
-// Close releases USB resources.
-func (s *DeviceSession) Close() {
+// Close releases USB resources. It always returns nil; the error result
+// makes DeviceSession an io.Closer.
+func (s *DeviceSession) Close() error {
 	if s == nil {
-		return
+		return nil
 	}
 	if s.intf != nil {
 		s.intf.Close()
@@ -243,6 +242,25 @@ func (s *DeviceSession) Close() {
 	if s.ctx != nil {
 		s.ctx.Close()
 	}
+	*s = DeviceSession{}
+	return nil
+}
+
+// maxMPSSERead is the most bytes one MPSSE read command can transfer: the
+// length field is 16 bits, holding n-1.
+const maxMPSSERead = 1 << 16
+
+// Read implements io.Reader, reading up to 64 KiB per call with one MPSSE
+// command; larger buffers get a short read, so use io.ReadFull to fill them.
+// A closed session returns an error.
+func (s *DeviceSession) Read(p []byte) (int, error) {
+	if s == nil || s.outEp == nil {
+		return 0, errors.New("bitbabbler session is closed")
+	}
+	if len(p) > maxMPSSERead {
+		p = p[:maxMPSSERead]
+	}
+	return s.ReadRandom(context.Background(), p)
 }
 
 // ReadRandom fills buf with random data from device. It issues an MPSSE read command
@@ -0,0 +1,182 @@
+// Package bitpack defines how samples of an arbitrary number of bits are
+// packed into bytes. Every source in this module (pseudorng, truerng, bbusb,
+// drbg, replay) and every analysis follows the same convention:
+//
+//   - An n-bit sample occupies BytesFor(n) = ceil(n/8) bytes.
+//   - Bits are packed MSB-first: sample bit 0 is the most significant bit of
+//     byte 0, bit 8 is the most significant bit of byte 1, and so on.
+//   - When n is not a multiple of 8, the sample's bits fill the high end of the
+//     final byte and the unused low bits are zero.
+//
+// For example, a 12-bit sample 1011 0110 0101 is stored as 0xB6 0x50.
+//
+// Before this convention was fixed, the BitBabbler code paths kept the low
+// bits of the final byte instead (zeroing the high ones). DetectAlignment and
+// Repack identify and convert captures written that way.
+package bitpack
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// BytesFor returns the number of bytes an n-bit sample occupies.
+func BytesFor(n int) int {
+	return (n + 7) / 8
+}
+
+// Padding returns the number of unused bits in the final byte of an n-bit
+// sample (0 when n is a multiple of 8).
+func Padding(n int) int {
+	return (8 - n%8) % 8
+}
+
+// Mask zeroes the unused low bits of the final byte of buf, which holds an
+// n-bit sample. buf may be shorter than BytesFor(n) after a short read, in
+// which case it is left unchanged.
+func Mask(buf []byte, n int) {
+	pad := Padding(n)
+	if pad == 0 || len(buf) != BytesFor(n) {
+		return
+	}
+	buf[len(buf)-1] &= byte(0xFF << pad)
+}
+
+// OnesCount returns the number of set bits among the first n bits of buf.
+// Padding bits are ignored whatever their value.
+func OnesCount(buf []byte, n int) int {
+	if n <= 0 || len(buf) == 0 {
+		return 0
+	}
+	full := n / 8
+	if full > len(buf) {
+		full = len(buf)
+	}
+	total := 0
+	for _, b := range buf[:full] {
+		total += bits.OnesCount8(b)
+	}
+	if pad := Padding(n); pad != 0 && full < len(buf) {
+		total += bits.OnesCount8(buf[full] & byte(0xFF<<pad))
+	}
+	return total
+}
+
+// Alignment describes where a capture keeps the bits of a partial final byte.
+type Alignment int
+
+const (
+	// AlignUnknown means the alignment cannot be determined.
+	AlignUnknown Alignment = iota
+	// AlignMSB is the module's convention: data in the high bits.
+	AlignMSB
+	// AlignLSB is the legacy BitBabbler layout: data in the low bits.
+	AlignLSB
+)
+
+func (a Alignment) String() string {
+	switch a {
+	case AlignMSB:
+		return "msb"
+	case AlignLSB:
+		return "lsb"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseAlignment parses "msb" or "lsb".
+func ParseAlignment(s string) (Alignment, error) {
+	switch s {
+	case "msb":
+		return AlignMSB, nil
+	case "lsb":
+		return AlignLSB, nil
+	default:
+		return AlignUnknown, errors.New("alignment must be msb or lsb")
+	}
+}
+
+// DetectAlignment inspects data, a sequence of n-bit samples, and reports how
+// the final byte of each sample is aligned. It returns AlignMSB when the
+// padding bits are zero in every sample (always the case when n is a multiple
+// of 8), otherwise AlignLSB when the top Padding(n) bits are zero in every
+// sample, and AlignUnknown if neither holds. A trailing partial sample is
+// ignored.
+func DetectAlignment(data []byte, n int) Alignment {
+	d := NewDetector(n)
+	d.Write(data)
+	return d.Alignment()
+}
+
+// Detector is an io.Writer that performs DetectAlignment incrementally over a
+// stream of n-bit samples, so large captures need not be held in memory.
+type Detector struct {
+	size     int
+	low      byte // padding bits under AlignMSB
+	high     byte // the same number of top bits, zero under AlignLSB
+	pos      int  // bytes seen modulo size
+	msb, lsb bool
+}
+
+// NewDetector returns a Detector for n-bit samples.
+func NewDetector(n int) *Detector {
+	pad := Padding(n)
+	return &Detector{
+		size: BytesFor(n),
+		low:  byte(0xFF >> (8 - pad)),
+		high: byte(0xFF << (8 - pad)),
+		msb:  true,
+		lsb:  true,
+	}
+}
+
+// Write inspects the final byte of every sample in p. It never fails.
+func (d *Detector) Write(p []byte) (int, error) {
+	if d.low == 0 {
+		return len(p), nil
+	}
+	for _, b := range p {
+		d.pos++
+		if d.pos < d.size {
+			continue
+		}
+		d.pos = 0
+		if b&d.low != 0 {
+			d.msb = false
+		}
+		if b&d.high != 0 {
+			d.lsb = false
+		}
+	}
+	return len(p), nil
+}
+
+// Alignment returns the alignment of the samples written so far.
+func (d *Detector) Alignment() Alignment {
+	switch {
+	case d.msb:
+		return AlignMSB
+	case d.lsb:
+		return AlignLSB
+	default:
+		return AlignUnknown
+	}
+}
+
+// Repack rewrites data, a sequence of n-bit samples in alignment from, in
+// place so that it follows the MSB-first convention. Only the final byte of
+// each sample changes. A trailing partial sample is left untouched.
+func Repack(data []byte, n int, from Alignment) error {
+	if from != AlignMSB && from != AlignLSB {
+		return errors.New("unknown source alignment")
+	}
+	size, pad := BytesFor(n), Padding(n)
+	for off := 0; off+size <= len(data); off += size {
+		if from == AlignLSB {
+			data[off+size-1] <<= pad
+		}
+		Mask(data[off:off+size], n)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package bitpack
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSizes(t *testing.T) {
+	tests := []struct {
+		n, bytes, padding int
+	}{
+		{0, 0, 0},
+		{1, 1, 7},
+		{7, 1, 1},
+		{8, 1, 0},
+		{9, 2, 7},
+		{12, 2, 4},
+		{16, 2, 0},
+		{2048, 256, 0},
+		{2049, 257, 7},
+	}
+	for _, tt := range tests {
+		if got := BytesFor(tt.n); got != tt.bytes {
+			t.Errorf("BytesFor(%d) = %d, want %d", tt.n, got, tt.bytes)
+		}
+		if got := Padding(tt.n); got != tt.padding {
+			t.Errorf("Padding(%d) = %d, want %d", tt.n, got, tt.padding)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		n    int
+		want []byte
+	}{
+		{"package example", []byte{0xb6, 0x5f}, 12, []byte{0xb6, 0x50}},
+		{"one bit", []byte{0xff}, 1, []byte{0x80}},
+		{"seven bits", []byte{0xff}, 7, []byte{0xfe}},
+		{"whole bytes", []byte{0xff, 0xff}, 16, []byte{0xff, 0xff}},
+		{"short read", []byte{0xff}, 12, []byte{0xff}},
+		{"long buffer", []byte{0xff, 0xff, 0xff}, 12, []byte{0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := append([]byte(nil), tt.buf...)
+			Mask(buf, tt.n)
+			if !bytes.Equal(buf, tt.want) {
+				t.Errorf("Mask(%x, %d) = %x, want %x", tt.buf, tt.n, buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnesCount(t *testing.T) {
+	tests := []struct {
+		buf  []byte
+		n    int
+		want int
+	}{
+		{[]byte{0xb6, 0x50}, 12, 7},
+		{[]byte{0xff, 0xff}, 12, 12},
+		{[]byte{0xff, 0x0f}, 12, 8}, // padding ignored
+		{[]byte{0x1f}, 3, 0},
+		{[]byte{0xe0}, 3, 3},
+		{[]byte{0xff, 0xff}, 16, 16},
+		{[]byte{0xff}, 16, 8}, // short buffer
+		{[]byte{0xff}, 0, 0},
+		{nil, 8, 0},
+	}
+	for _, tt := range tests {
+		if got := OnesCount(tt.buf, tt.n); got != tt.want {
+			t.Errorf("OnesCount(%x, %d) = %d, want %d", tt.buf, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAlignment(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		n    int
+		want Alignment
+	}{
+		{"msb", []byte{0xab, 0xc0, 0xde, 0xf0}, 12, AlignMSB},
+		{"lsb", []byte{0xab, 0x0c, 0xde, 0x0f}, 12, AlignLSB},
+		{"mixed", []byte{0xab, 0xc0, 0xde, 0x0f}, 12, AlignUnknown},
+		{"both bytes set", []byte{0xab, 0xcf}, 12, AlignUnknown},
+		// Zero final bytes fit both layouts; the convention wins.
+		{"zero final bytes", []byte{0xab, 0x00, 0xde, 0x00}, 12, AlignMSB},
+		{"whole bytes", []byte{0xff, 0xff}, 16, AlignMSB},
+		{"partial sample ignored", []byte{0xab, 0x0c, 0xff}, 12, AlignLSB},
+		{"empty", nil, 12, AlignMSB},
+		{"one bit lsb", []byte{0x01, 0x00, 0x01}, 1, AlignLSB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectAlignment(tt.data, tt.n); got != tt.want {
+				t.Errorf("DetectAlignment(%x, %d) = %v, want %v", tt.data, tt.n, got, tt.want)
+			}
+			// Writing a byte at a time must give the same answer.
+			d := NewDetector(tt.n)
+			for i := range tt.data {
+				d.Write(tt.data[i : i+1])
+			}
+			if got := d.Alignment(); got != tt.want {
+				t.Errorf("Detector over single bytes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepack(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		n    int
+		from Alignment
+		want []byte
+	}{
+		{"lsb", []byte{0xab, 0x0c, 0xde, 0x0f}, 12, AlignLSB, []byte{0xab, 0xc0, 0xde, 0xf0}},
+		{"lsb one bit", []byte{0x01, 0x00}, 1, AlignLSB, []byte{0x80, 0x00}},
+		{"msb masks padding", []byte{0xab, 0xcf}, 12, AlignMSB, []byte{0xab, 0xc0}},
+		{"partial sample untouched", []byte{0xab, 0x0c, 0x0d}, 12, AlignLSB, []byte{0xab, 0xc0, 0x0d}},
+		{"whole bytes", []byte{0x12, 0x34}, 16, AlignLSB, []byte{0x12, 0x34}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte(nil), tt.data...)
+			if err := Repack(data, tt.n, tt.from); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tt.want) {
+				t.Errorf("Repack(%x, %d, %v) = %x, want %x", tt.data, tt.n, tt.from, data, tt.want)
+			}
+			if got := DetectAlignment(data, tt.n); got != AlignMSB {
+				t.Errorf("repacked data detected as %v, want msb", got)
+			}
+		})
+	}
+	if err := Repack([]byte{0, 0}, 12, AlignUnknown); err == nil {
+		t.Error("Repack from AlignUnknown: no error")
+	}
+}
+
+func TestParseAlignment(t *testing.T) {
+	for _, a := range []Alignment{AlignMSB, AlignLSB} {
+		got, err := ParseAlignment(a.String())
+		if err != nil || got != a {
+			t.Errorf("ParseAlignment(%q) = %v, %v, want %v", a.String(), got, err, a)
+		}
+	}
+	if _, err := ParseAlignment("unknown"); err == nil {
+		t.Error(`ParseAlignment("unknown"): no error`)
+	}
+}
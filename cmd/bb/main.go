@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/Thiagojm/rng_go_cli/bbusb"
+	"github.com/Thiagojm/rng_go_cli/bitpack"
 )
 
 func main() {
@@ -48,7 +49,7 @@ func main() {
 	}
 
 	// Round bits up to bytes
-	numBytes := (numBits + 7) / 8
+	numBytes := bitpack.BytesFor(numBits)
 
 	sess, err := bbusb.OpenBitBabbler(2_500_000, 1)
 	if err != nil {
@@ -69,11 +70,9 @@ func main() {
 		buf = buf[:n]
 	}
 
-	// Trim excess bits from the last byte if needed
-	excess := (8 - (numBits % 8)) % 8
-	if excess != 0 && len(buf) > 0 {
-		buf[len(buf)-1] &= 0xFF >> excess
-	}
+	// Zero the padding bits of the last byte if needed
+	bitpack.Mask(buf, numBits)
+	excess := bitpack.Padding(numBits)
 
 	// Hex
 	fmt.Printf("HEX: %x\n", buf)
@@ -82,7 +81,7 @@ func main() {
 	var sb strings.Builder
 	for i, b := range buf {
 		if i == len(buf)-1 && excess != 0 {
-			fmt.Fprintf(&sb, "%0*b", 8-excess, b)
+			fmt.Fprintf(&sb, "%0*b", 8-excess, b>>excess)
 		} else {
 			fmt.Fprintf(&sb, "%08b", b)
 		}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// binrepack converts a .bin capture to the packing convention of package
+// bitpack. Captures whose sample size is not a multiple of 8 bits were written
+// by older BitBabbler code with the data in the low bits of each sample's final
+// byte; this rewrites them (and recounts the sibling .csv, whose counts were
+// taken from the wrong bits) into a separate directory.
+func main() {
+	from := flag.String("from", "auto", "alignment of the input: auto|msb|lsb")
+	bitsFlag := flag.Int("bits", 0, "bits per sample; 0 takes it from the file name")
+	outDir := flag.String("outdir", "", "output directory (default: <input dir>/repacked)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: binrepack [flags] <file.bin>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	in := flag.Arg(0)
+
+	bits := *bitsFlag
+	if bits == 0 {
+		info, err := naming.ParseBaseName(in)
+		if err != nil {
+			log.Fatalf("-bits not given and %v", err)
+		}
+		bits = info.Bits
+	}
+	if bits <= 0 {
+		log.Fatal("bits must be > 0")
+	}
+
+	align := bitpack.AlignUnknown
+	if *from == "auto" {
+		var err error
+		if align, err = detect(in, bits); err != nil {
+			log.Fatalf("detect: %v", err)
+		}
+		if align == bitpack.AlignUnknown {
+			log.Fatal("cannot tell how the padding is aligned; pass -from msb or -from lsb")
+		}
+		log.Printf("detected %s-aligned padding", align)
+	} else {
+		var err error
+		if align, err = bitpack.ParseAlignment(*from); err != nil {
+			log.Fatalf("-from: %v", err)
+		}
+	}
+
+	dir := *outDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(in), "repacked")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("outdir: %v", err)
+	}
+	out := filepath.Join(dir, filepath.Base(in))
+	if abs(out) == abs(in) {
+		log.Fatal("output would overwrite the input; choose another -outdir")
+	}
+
+	samples, err := repackBin(in, out, bits, align)
+	if err != nil {
+		log.Fatalf("repack: %v", err)
+	}
+	log.Printf("wrote %d samples to %s", samples, out)
+
+	csvIn := naming.SidecarPath(in, "csv")
+	if _, err := os.Stat(csvIn); err == nil {
+		csvOut := naming.SidecarPath(out, "csv")
+		if err := recount(csvOut, csvIn, out, bits); err != nil {
+			log.Fatalf("csv: %v", err)
+		}
+		log.Printf("recounted %s", csvOut)
+	}
+	metaIn := naming.SidecarPath(in, "meta.json")
+	if b, err := os.ReadFile(metaIn); err == nil {
+		if err := os.WriteFile(naming.SidecarPath(out, "meta.json"), b, 0o644); err != nil {
+			log.Fatalf("meta: %v", err)
+		}
+	}
+}
+
+// detect reports the padding alignment of the capture at path.
+func detect(path string, bits int) (bitpack.Alignment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return bitpack.AlignUnknown, err
+	}
+	defer f.Close()
+	d := bitpack.NewDetector(bits)
+	if _, err := io.Copy(d, f); err != nil {
+		return bitpack.AlignUnknown, err
+	}
+	return d.Alignment(), nil
+}
+
+// repackBin copies in to out, repacking whole samples, and returns how many
+// whole samples were written. A trailing partial sample is copied unchanged.
+func repackBin(in, out string, bits int, from bitpack.Alignment) (int, error) {
+	src, err := os.Open(in)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.Create(out)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(dst)
+
+	size := bitpack.BytesFor(bits)
+	buf := make([]byte, size*4096)
+	samples := 0
+	for {
+		n, rerr := io.ReadFull(src, buf)
+		if n > 0 {
+			if err := bitpack.Repack(buf[:n], bits, from); err != nil {
+				dst.Close()
+				return samples, err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				dst.Close()
+				return samples, err
+			}
+			samples += n / size
+		}
+		if errors.Is(rerr, io.EOF) || errors.Is(rerr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if rerr != nil {
+			dst.Close()
+			return samples, rerr
+		}
+	}
+	if err := w.Flush(); err != nil {
+		dst.Close()
+		return samples, err
+	}
+	return samples, dst.Close()
+}
+
+// recount writes csvOut with the timestamps of csvIn and ones counts taken
+// from the repacked capture bin. Rows beyond the capture are kept as is.
+func recount(csvOut, csvIn, bin string, bits int) error {
+	data, err := os.ReadFile(bin)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(csvIn)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	size := bitpack.BytesFor(bits)
+	for i, rec := range records {
+		off := i * size
+		if off+size > len(data) || len(rec) < 2 {
+			continue
+		}
+		rec[1] = strconv.Itoa(bitpack.OnesCount(data[off:off+size], bits))
+	}
+
+	out, err := os.Create(csvOut)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	if err := w.WriteAll(records); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// abs returns path made absolute, or path itself if that fails.
+func abs(path string) string {
+	if a, err := filepath.Abs(path); err == nil {
+		return a
+	}
+	return path
+}
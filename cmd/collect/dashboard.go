@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// dashboardLogLines is the number of log messages the dashboard shows.
+const dashboardLogLines = 6
+
+// sparkRunes draw the cumulative z history, lowest to highest.
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+// dashboard redraws a full-screen summary of the run on a terminal. It is
+// the -tui alternative to the per-sample lines and also takes over the log
+// output, keeping the latest messages on screen.
+type dashboard struct {
+	out    *os.File
+	status *status
+	keys   string
+
+	mu   sync.Mutex
+	logs []string
+	done chan struct{}
+}
+
+// newDashboard clears the screen and starts redrawing once a second, so the
+// elapsed time and state stay current between samples.
+func newDashboard(out *os.File, st *status, keys string) *dashboard {
+	d := &dashboard{out: out, status: st, keys: keys, done: make(chan struct{})}
+	fmt.Fprint(out, "\x1b[?25l\x1b[H\x1b[2J")
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-t.C:
+				d.render()
+			}
+		}
+	}()
+	return d
+}
+
+// Write receives log output; each line is kept for display.
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		d.logs = appendCapped(d.logs, line, dashboardLogLines)
+	}
+	d.mu.Unlock()
+	d.render()
+	return len(p), nil
+}
+
+// close draws a final frame and leaves the cursor below it.
+func (d *dashboard) close() {
+	close(d.done)
+	d.render()
+	fmt.Fprint(d.out, "\x1b[?25h\n")
+}
+
+// render redraws the screen.
+func (d *dashboard) render() {
+	st := d.status.snapshot()
+	width := terminalWidth(d.out)
+	if width <= 0 {
+		width = 80
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var b bytes.Buffer
+	line := func(label, format string, args ...any) {
+		s := fmt.Sprintf("%-8s ", label) + fmt.Sprintf(format, args...)
+		if r := []rune(s); len(r) > width {
+			s = string(r[:width])
+		}
+		b.WriteString(s + "\x1b[K\n")
+	}
+
+	b.WriteString("\x1b[H")
+	line("device", "%s  %s", st.device, st.desc)
+	elapsed := time.Since(st.start).Truncate(time.Second)
+	line("state", "%-24s elapsed %s", st.state, elapsed)
+	line("rate", "%.2f samples/s  %.0f bits/s  (%d bits every %s)", st.rate(), st.rate()*float64(st.bits), st.bits, st.interval)
+	recent := make([]string, len(st.recent))
+	for i, v := range st.recent {
+		recent[len(st.recent)-1-i] = fmt.Sprint(v)
+	}
+	line("samples", "%d  latest first: %s", st.samples, strings.Join(recent, " "))
+	z := st.cumulativeZ()
+	line("z", "%+.3f  p=%.4f  over %d bits", z, stats.TwoSidedP(z), int64(st.samples)*int64(st.bits))
+	spark, scale := sparkline(st.z, width-20)
+	line("", "%s  ±%.1f", spark, scale)
+	if st.healthFailures == 0 {
+		line("health", "ok  (repetition cutoff %d, proportion cutoff %d/1024)", st.rctCutoff, st.aptCutoff)
+	} else {
+		line("health", "FAILED %d×, last: %s", st.healthFailures, st.lastHealth)
+	}
+	line("files", "%s %s  %s %s  %s", ".bin", fileSize(st.binPath), ".csv", fileSize(st.csvPath), eventsSummary(st))
+	line("errors", "read %d  reconnects %d", st.readErrors, st.reconnects)
+	if d.keys != "" {
+		line("keys", "%s", strings.TrimPrefix(d.keys, "event keys: "))
+	}
+	b.WriteString("\x1b[K\n")
+	for _, l := range d.logs {
+		line("", "%s", l)
+	}
+	b.WriteString("\x1b[J")
+	_, _ = d.out.Write(b.Bytes())
+}
+
+// sparkline draws the last width values of zs scaled to ±scale, where scale
+// is the largest |z| shown but at least 2, so noise near zero stays flat.
+func sparkline(zs []float64, width int) (string, float64) {
+	if width < 1 {
+		width = 1
+	}
+	if len(zs) > width {
+		zs = zs[len(zs)-width:]
+	}
+	scale := 2.0
+	for _, z := range zs {
+		scale = math.Max(scale, math.Abs(z))
+	}
+	r := make([]rune, len(zs))
+	for i, z := range zs {
+		k := int((z + scale) / (2 * scale) * float64(len(sparkRunes)))
+		r[i] = sparkRunes[min(max(k, 0), len(sparkRunes)-1)]
+	}
+	return string(r), scale
+}
+
+// fileSize formats the size of the file at path.
+func fileSize(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "-"
+	}
+	n := float64(fi.Size())
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if n < 1024 || unit == "GB" {
+			if unit == "B" {
+				return fmt.Sprintf("%.0f %s", n, unit)
+			}
+			return fmt.Sprintf("%.1f %s", n, unit)
+		}
+		n /= 1024
+	}
+	return ""
+}
+
+// eventsSummary describes the events file for the files line.
+func eventsSummary(st statusSnapshot) string {
+	if st.events == 0 {
+		return ".events.csv -"
+	}
+	return fmt.Sprintf("%s %s (%d, last: %s)", ".events.csv", fileSize(events.Path(st.binPath)), st.events, st.lastEvent)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/device"
+	"github.com/Thiagojm/rng_go_cli/drbg"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/pseudorng"
+	"github.com/Thiagojm/rng_go_cli/replay"
+)
+
+// deviceReader reads bits from an opened device.
+type deviceReader struct {
+	// read returns bitCount bits packed MSB-first.
+	read func(ctx context.Context, bitCount int) ([]byte, error)
+	// reopen, if set, re-establishes the device session after a replug.
+	reopen func() error
+	// watch is the hardware device whose presence is watched for replugs,
+	// or empty for software sources.
+	watch naming.Device
+	// close, if set, releases the device.
+	close func()
+	// desc describes the device for the dashboard.
+	desc string
+	// id identifies the device instance in metrics: the serial port of a
+	// TrueRNG, the USB device path of a BitBabbler (as in hotplug), the
+	// algorithm of the pseudo generator or the capture of a replay.
+	id string
+}
+
+// Close releases the device, if needed.
+func (r *deviceReader) Close() {
+	if r != nil && r.close != nil {
+		r.close()
+	}
+}
+
+// deviceOptions carries the device-specific flags.
+type deviceOptions struct {
+	trngRules string
+	// pseudo
+	algo string
+	seed uint64
+	// replay
+	replay *replay.Source
+	// drbg
+	drbgMech        string
+	drbgSource      string
+	drbgReseed      time.Duration
+	personalization []byte
+}
+
+// openDevice prepares dev for reading and records device details in meta.
+func openDevice(ctx context.Context, dev naming.Device, opts deviceOptions, meta *runMetadata) (*deviceReader, error) {
+	switch dev {
+	case naming.DevicePseudo, naming.DeviceTrueRNG, naming.DeviceBitBabbler:
+		o := device.Options{TrueRNGRules: opts.trngRules, Seed: opts.seed}
+		if dev == naming.DevicePseudo {
+			algo, err := pseudorng.ParseAlgorithm(opts.algo)
+			if err != nil {
+				return nil, fmt.Errorf("-algo: %w", err)
+			}
+			o.Algorithm = algo
+		}
+		src, err := device.Open(dev, o)
+		if err != nil {
+			return nil, err
+		}
+		if g, ok := src.ReadCloser.(*pseudorng.Generator); ok {
+			meta.Algorithm = string(g.Algorithm())
+			meta.Seed = g.Seed()
+		}
+		log.Printf("using %s", src.Desc)
+		r := &deviceReader{desc: src.Desc, id: src.ID}
+		r.read = func(ctx context.Context, bitCount int) ([]byte, error) {
+			return src.ReadBits(bitCount)
+		}
+		r.close = func() { src.Close() }
+		if dev != naming.DevicePseudo {
+			r.watch = dev
+		}
+		if dev == naming.DeviceBitBabbler {
+			// A TrueRNG reopens its port by itself after a read error; a
+			// BitBabbler session has to be opened again.
+			o.TrueRNGRules = ""
+			r.reopen = func() error {
+				src.Close()
+				s, err := device.Open(dev, o)
+				if err != nil {
+					return err
+				}
+				src = s
+				return nil
+			}
+		}
+		return r, nil
+
+	case naming.DeviceReplay:
+		src := opts.replay
+		if src == nil {
+			return nil, errors.New("replay source not opened")
+		}
+		log.Printf("replaying %s", meta.Source)
+		return &deviceReader{read: src.ReadBits, desc: meta.Source, id: filepath.Base(meta.Source)}, nil
+
+	case naming.DeviceDRBG:
+		mech, err := drbg.ParseMechanism(opts.drbgMech)
+		if err != nil {
+			return nil, fmt.Errorf("-drbg-mech: %w", err)
+		}
+		entropyDev := naming.Device(opts.drbgSource)
+		if entropyDev != naming.DeviceTrueRNG && entropyDev != naming.DeviceBitBabbler && entropyDev != naming.DevicePseudo {
+			return nil, fmt.Errorf("invalid -drbg-source: %s (allowed: trng, bitb, pseudo)", opts.drbgSource)
+		}
+		// The entropy source's own details (e.g. a pseudo seed) land in meta too.
+		er, err := openDevice(ctx, entropyDev, opts, meta)
+		if err != nil {
+			return nil, err
+		}
+		entropy := func(ctx context.Context, n int) ([]byte, error) {
+			return er.read(ctx, n*8)
+		}
+		src, err := drbg.NewSource(ctx, mech, entropy, opts.drbgReseed, opts.personalization)
+		if err != nil {
+			er.Close()
+			return nil, fmt.Errorf("drbg instantiate: %w", err)
+		}
+		meta.DRBG = &drbgMetadata{Mechanism: string(mech), EntropySource: string(entropyDev), ReseedInterval: opts.drbgReseed.String()}
+		log.Printf("drbg: %s seeded from %s, reseed every %s", mech, entropyDev, opts.drbgReseed)
+		desc := fmt.Sprintf("%s from %s", mech, er.desc)
+		r := &deviceReader{read: src.ReadBits, close: er.close, desc: desc, id: string(entropyDev) + ":" + er.id, watch: er.watch}
+		if er.watch != "" {
+			// After a replug, reopen the entropy device and reseed so the
+			// DRBG is not left drawing on a state seeded before the outage.
+			r.reopen = func() error {
+				if er.reopen != nil {
+					if err := er.reopen(); err != nil {
+						return err
+					}
+				}
+				return src.Reseed(ctx)
+			}
+		}
+		return r, nil
+
+	default:
+		return nil, errors.New("unsupported device")
+	}
+}
@@ -3,63 +3,94 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math/bits"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"time"
 
-	"github.com/Thiagojm/rng_go_cli/bbusb"
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/drbg"
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/health"
+	"github.com/Thiagojm/rng_go_cli/hotplug"
+	"github.com/Thiagojm/rng_go_cli/metrics"
 	"github.com/Thiagojm/rng_go_cli/naming"
 	"github.com/Thiagojm/rng_go_cli/pseudorng"
-	"github.com/Thiagojm/rng_go_cli/truerng"
+	"github.com/Thiagojm/rng_go_cli/replay"
 )
 
-// countOnes returns the number of set bits in buf, considering only bitCount bits total.
-// The final byte is handled so that unused trailing bits (if any) are not counted.
-func countOnes(buf []byte, bitCount int) int {
-	if bitCount <= 0 || len(buf) == 0 {
-		return 0
-	}
-	bytesUsed := (bitCount + 7) / 8
-	if bytesUsed > len(buf) {
-		bytesUsed = len(buf)
-	}
-	// Count all full bytes except possibly the last.
-	total := 0
-	for i := 0; i < bytesUsed-1; i++ {
-		total += bits.OnesCount8(buf[i])
-	}
-	// Handle last byte respecting bitCount.
-	if bytesUsed > 0 {
-		usedBitsInLast := bitCount - (bytesUsed-1)*8
-		if usedBitsInLast <= 0 {
-			usedBitsInLast = 8
-		}
-		mask := byte(0xFF) << (8 - usedBitsInLast)
-		last := buf[bytesUsed-1] & mask
-		total += bits.OnesCount8(last)
+// runMetadata describes a collection run. It is written next to the .bin and
+// .csv files as <base>.meta.json so a run can be identified and, for the
+// pseudo device, replayed exactly.
+type runMetadata struct {
+	Device          string `json:"device"`
+	Bits            int    `json:"bits"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	Start           string `json:"start"`
+	// Algorithm and Seed are set for the pseudo device. The seed is encoded as
+	// a string so it survives JSON readers that use float64 numbers.
+	Algorithm string `json:"algorithm,omitempty"`
+	Seed      uint64 `json:"seed,omitempty,string"`
+	// Source is the capture played back by the replay device.
+	Source string `json:"source,omitempty"`
+	// DRBG describes the generator for the drbg device.
+	DRBG *drbgMetadata `json:"drbg,omitempty"`
+}
+
+// drbgMetadata records how the drbg device was configured.
+type drbgMetadata struct {
+	Mechanism      string `json:"mechanism"`
+	EntropySource  string `json:"entropy_source"`
+	ReseedInterval string `json:"reseed_interval"`
+}
+
+// writeMetadata writes m as indented JSON to path.
+func writeMetadata(path string, m runMetadata) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
 	}
-	return total
+	return os.WriteFile(path, append(data, '\n'), 0o644)
 }
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run collects until interrupted, returning errors instead of exiting so
+// that the deferred cleanup, including restoring the terminal, always runs.
+func run() error {
 	bitsFlag := flag.Int("bits", 2048, "number of bits per batch (required > 0)")
 	intervalSec := flag.Int("interval", 1, "interval between batches in seconds (required > 0)")
-	deviceFlag := flag.String("device", "pseudo", "device to read from: pseudo|trng|bitb")
+	deviceFlag := flag.String("device", "pseudo", "device to read from: pseudo|trng|bitb|replay|drbg")
 	outDir := flag.String("outdir", "data", "output directory for files")
+	trngRules := flag.String("trng-rules", "", "optional JSON file with extra TrueRNG match rules")
+	algoFlag := flag.String("algo", string(pseudorng.AlgoChaCha8), "pseudo algorithm: chacha8|pcg|legacy")
+	seedFlag := flag.Uint64("seed", 0, "pseudo seed; 0 draws a random seed (recorded in the .meta.json file)")
+	watchFlag := flag.Bool("watch", true, "pause on device unplug and resume on replug (trng, bitb, and drbg seeded from them)")
+	replayPath := flag.String("replay", "", "replay: .bin capture to play back")
+	replayLoop := flag.Bool("loop", false, "replay: restart at -offset when the capture ends")
+	replayOffset := flag.Int("offset", 0, "replay: number of samples to skip")
+	replayRealtime := flag.Bool("realtime", false, "replay: follow the capture's recorded timing instead of -interval")
+	drbgMech := flag.String("drbg-mech", string(drbg.MechHMAC), "drbg: mechanism hash|hmac|ctr")
+	drbgSource := flag.String("drbg-source", string(naming.DeviceTrueRNG), "drbg: entropy source trng|bitb|pseudo")
+	drbgReseed := flag.Duration("drbg-reseed", time.Minute, "drbg: reseed from the entropy source this often (0 = only when required)")
+	keysFlag := flag.String("keys", defaultKeys, `event keys as key=label (a mark), key=+name / key=-name (start / stop segment "name"); "" disables`)
+	eventsAddr := flag.String("events-addr", "", "listen address for posting events over HTTP, e.g. 127.0.0.1:8090 (off by default)")
+	httpAddr := flag.String("http", "", "listen address for the browser dashboard, e.g. :8080 (off by default)")
+	tuiFlag := flag.Bool("tui", false, "show a live dashboard instead of one line per sample (needs a terminal)")
 	flag.Parse()
 
-	if *bitsFlag <= 0 {
-		log.Fatal("-bits must be > 0")
-	}
-	if *intervalSec <= 0 {
-		log.Fatal("-interval must be > 0")
-	}
-
 	// Map device flag to naming.Device
 	var dev naming.Device
 	switch *deviceFlag {
@@ -69,23 +100,62 @@ func main() {
 		dev = naming.DeviceTrueRNG
 	case string(naming.DeviceBitBabbler):
 		dev = naming.DeviceBitBabbler
+	case string(naming.DeviceReplay):
+		dev = naming.DeviceReplay
+	case string(naming.DeviceDRBG):
+		dev = naming.DeviceDRBG
 	default:
-		log.Fatalf("invalid -device: %s (allowed: pseudo, trng, bitb)", *deviceFlag)
+		return fmt.Errorf("invalid -device: %s (allowed: pseudo, trng, bitb, replay, drbg)", *deviceFlag)
+	}
+
+	// A replay keeps the capture's sample size and interval unless overridden.
+	var replaySrc *replay.Source
+	if dev == naming.DeviceReplay {
+		if *replayPath == "" {
+			return errors.New("-device replay requires -replay <file.bin>")
+		}
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		opts := replay.Options{Offset: *replayOffset, Loop: *replayLoop, Realtime: *replayRealtime}
+		if set["bits"] {
+			opts.Bits = *bitsFlag
+		}
+		src, rerr := replay.Open(*replayPath, opts)
+		if rerr != nil {
+			return fmt.Errorf("replay open: %w", rerr)
+		}
+		defer src.Close()
+		replaySrc = src
+		*bitsFlag = src.Bits()
+		if !set["interval"] && src.Info().IntervalSeconds > 0 {
+			*intervalSec = src.Info().IntervalSeconds
+		}
+	}
+
+	if *bitsFlag <= 0 {
+		return errors.New("-bits must be > 0")
+	}
+	if *intervalSec <= 0 {
+		return errors.New("-interval must be > 0")
+	}
+	keys, err := parseKeys(*keysFlag)
+	if err != nil {
+		return fmt.Errorf("-keys: %w", err)
 	}
 
 	if err := os.MkdirAll(*outDir, 0o755); err != nil {
-		log.Fatalf("creating outdir: %v", err)
+		return fmt.Errorf("creating outdir: %w", err)
 	}
 
 	startTime := time.Now()
 	binPath, csvPath, err := naming.BuildBinCSVPaths(*outDir, startTime, dev, *bitsFlag, *intervalSec)
 	if err != nil {
-		log.Fatalf("build filenames: %v", err)
+		return fmt.Errorf("build filenames: %w", err)
 	}
 
 	binFile, err := os.OpenFile(binPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 	if err != nil {
-		log.Fatalf("open bin file: %v", err)
+		return fmt.Errorf("open bin file: %w", err)
 	}
 	defer func() { _ = binFile.Close() }()
 	binBuf := bufio.NewWriter(binFile)
@@ -93,121 +163,299 @@ func main() {
 
 	csvFile, err := os.OpenFile(csvPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 	if err != nil {
-		log.Fatalf("open csv file: %v", err)
+		return fmt.Errorf("open csv file: %w", err)
 	}
 	defer func() { _ = csvFile.Close() }()
 	csvBuf := bufio.NewWriter(csvFile)
 	defer csvBuf.Flush()
 
+	meta := runMetadata{
+		Device:          string(dev),
+		Bits:            *bitsFlag,
+		IntervalSeconds: *intervalSec,
+		Start:           startTime.Format(time.RFC3339),
+	}
+
+	if dev == naming.DeviceReplay {
+		meta.Source = *replayPath
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// Prepare a bitreader function for the chosen device.
 	bitCount := *bitsFlag
-	byteCount := (bitCount + 7) / 8
-	var readBits func(context.Context) ([]byte, error)
-
-	switch dev {
-	case naming.DevicePseudo:
-		readBits = func(ctx context.Context) ([]byte, error) {
-			return pseudorng.ReadBits(bitCount)
-		}
-	case naming.DeviceTrueRNG:
-		// Sanity check presence
-		present, derr := truerng.Detect()
-		if derr != nil {
-			log.Fatalf("trng detect: %v", derr)
-		}
-		if !present {
-			log.Fatal("TrueRNG device not found")
-		}
-		readBits = func(ctx context.Context) ([]byte, error) {
-			return truerng.ReadBits(bitCount)
-		}
-	case naming.DeviceBitBabbler:
-		// Check presence first for clearer errors
-		ok, devices, derr := bbusb.IsBitBabblerConnected()
-		if derr != nil {
-			log.Fatalf("bitb detect: %v", derr)
-		}
-		if !ok {
-			log.Fatal("No BitBabbler devices found (VID 0x0403 PID 0x7840)")
-		}
-		sess, oerr := bbusb.OpenBitBabbler(2_500_000, 1)
-		if oerr != nil {
-			log.Fatalf("bitb open: %v (ensure libusb-1.0.dll is available)", oerr)
-		}
-		defer sess.Close()
-		if len(devices) > 0 && devices[0].FriendlyName != "" {
-			log.Printf("using BitBabbler: %s", devices[0].FriendlyName)
-		}
-		readBits = func(ctx context.Context) ([]byte, error) {
-			buf := make([]byte, byteCount)
-			// Short per-read timeout to avoid hanging.
-			ct, cancel := context.WithTimeout(ctx, 3*time.Second)
-			defer cancel()
-			n, rerr := sess.ReadRandom(ct, buf)
-			if rerr != nil {
-				return nil, rerr
-			}
-			if n < len(buf) {
-				buf = buf[:n]
-			}
-			// Zero trailing unused bits so counting is consistent.
-			extra := (8 - (bitCount % 8)) % 8
-			if extra != 0 && len(buf) > 0 {
-				buf[len(buf)-1] &= 0xFF >> extra
-			}
-			return buf, nil
+	reader, err := openDevice(ctx, dev, deviceOptions{
+		trngRules:       *trngRules,
+		algo:            *algoFlag,
+		seed:            *seedFlag,
+		replay:          replaySrc,
+		drbgMech:        *drbgMech,
+		drbgSource:      *drbgSource,
+		drbgReseed:      *drbgReseed,
+		personalization: []byte(filepath.Base(binPath)),
+	}, &meta)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	readBits := func(ctx context.Context) ([]byte, error) {
+		return reader.read(ctx, bitCount)
+	}
+	reopen := reader.reopen
+
+	// The bits are checked with the SP 800-90B continuous health tests as
+	// they arrive; a failure is reported but collection goes on.
+	monitor, err := health.New(1)
+	if err != nil {
+		return err
+	}
+	st := newStatus(statusSnapshot{
+		device:   string(dev),
+		desc:     reader.desc,
+		deviceID: reader.id,
+		bits:     bitCount,
+		interval: time.Duration(*intervalSec) * time.Second,
+		start:    startTime,
+		binPath:  binPath,
+		csvPath:  csvPath,
+		state:    "collecting",
+	})
+	st.st.rctCutoff, st.st.aptCutoff = monitor.Cutoffs()
+
+	metaPath := naming.SidecarPath(binPath, "meta.json")
+	if merr := writeMetadata(metaPath, meta); merr != nil {
+		return fmt.Errorf("write metadata: %w", merr)
+	}
+
+	// Operator event markers go to <base>.events.csv, from key presses and,
+	// if enabled, an HTTP endpoint.
+	evlog := events.NewLog(events.Path(binPath))
+	defer evlog.Close()
+	evlog.OnAdd = func(e events.Event) {
+		st.event(e)
+		log.Printf("event: %s", e)
+	}
+
+	// Listeners are bound before the terminal mode changes, so a busy port
+	// fails with the terminal untouched.
+	if *eventsAddr != "" {
+		ln, lerr := net.Listen("tcp", *eventsAddr)
+		if lerr != nil {
+			return fmt.Errorf("events endpoint: %w", lerr)
 		}
-	default:
-		log.Fatal("unsupported device")
+		mux := http.NewServeMux()
+		mux.Handle("/events", events.Handler(evlog))
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() { _ = srv.Serve(ln) }()
+		defer srv.Close()
+		log.Printf("posting events at http://%s/events", ln.Addr())
+	}
+	if *httpAddr != "" {
+		ln, lerr := net.Listen("tcp", *httpAddr)
+		if lerr != nil {
+			return fmt.Errorf("http dashboard: %w", lerr)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/", webHandler(st, newWebInfo(st.snapshot(), meta), evlog))
+		mux.Handle("/metrics", metrics.Handler(st.writeMetrics))
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() { _ = srv.Serve(ln) }()
+		defer srv.Close()
+		log.Printf("dashboard at http://%s/, metrics at http://%s/metrics", ln.Addr(), ln.Addr())
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	// With -tui the dashboard replaces the per-sample lines and shows the log.
+	var dash *dashboard
+	if *tuiFlag {
+		restore, terr := dashboardMode(os.Stdout)
+		if terr != nil {
+			log.Printf("-tui: stdout is not a terminal (%v); printing plain lines", terr)
+		} else {
+			help := ""
+			if len(keys) > 0 {
+				help = keysHelp(keys)
+			}
+			dash = newDashboard(os.Stdout, st, help)
+			log.SetOutput(dash)
+			defer func() {
+				st.setState("stopped")
+				dash.close()
+				log.SetOutput(os.Stderr)
+				restore()
+			}()
+		}
+	}
 
+	if len(keys) > 0 {
+		restore, terr := keyInputMode(os.Stdin)
+		if errors.Is(terr, errBackground) {
+			log.Print("running in the background; event keys disabled")
+		} else {
+			if terr == nil {
+				defer restore()
+			}
+			log.Print(keysHelp(keys))
+			go readKeys(os.Stdin, keys, evlog)
+		}
+	}
 	interval := time.Duration(*intervalSec) * time.Second
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	tick := ticker.C
+	if dev == naming.DeviceReplay && *replayRealtime {
+		// The replay paces itself; a closed channel never blocks.
+		ch := make(chan time.Time)
+		close(ch)
+		tick = ch
+	}
+
+	// Hardware devices are watched so that unplugging pauses collection
+	// instead of ending it; collection resumes when the device comes back.
+	var plugEvents <-chan hotplug.Event
+	if *watchFlag && reader.watch != "" {
+		plugEvents, err = hotplug.Watch(ctx, time.Second, reader.watch)
+		if err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+	}
+	attached := make(map[hotplug.Device]bool)
+	paused := false
+	// handleEvent tracks presence and pauses once the last device is detached.
+	handleEvent := func(ev hotplug.Event) {
+		if ev.Err != nil {
+			log.Printf("watch: %v", ev.Err)
+			return
+		}
+		if ev.Attached {
+			attached[ev.Device] = true
+			return
+		}
+		delete(attached, ev.Device)
+		if len(attached) == 0 && !paused {
+			log.Printf("%s detached (%s); pausing", string(dev), ev.Device.ID)
+			paused = true
+			st.setState("paused (device detached)")
+		}
+	}
+	// waitForDevice blocks until the device is present again and reopened.
+	// It re-enumerates on its own rather than relying on an attach event, since
+	// a quick replug on the same port may never be observed as a change.
+	// It returns false if ctx is cancelled first.
+	waitForDevice := func() bool {
+		retry := time.NewTicker(time.Second)
+		defer retry.Stop()
+		for paused {
+			select {
+			case <-ctx.Done():
+				return false
+			case ev, ok := <-plugEvents:
+				if !ok {
+					return false
+				}
+				handleEvent(ev)
+			case <-retry.C:
+			}
+			present, lerr := hotplug.List(reader.watch)
+			if lerr != nil || len(present) == 0 {
+				continue
+			}
+			if reopen != nil {
+				if oerr := reopen(); oerr != nil {
+					log.Printf("%s reopen: %v; retrying", string(dev), oerr)
+					continue
+				}
+			}
+			log.Printf("%s attached (%s); resuming", string(dev), present[0].ID)
+			paused = false
+			st.reconnect()
+			st.setState("collecting")
+		}
+		return true
+	}
 
 	log.Printf("collecting %d bits every %s from %s", bitCount, interval.String(), string(dev))
 	sampleNum := 0
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		default:
 		}
 
+		if paused && !waitForDevice() {
+			return nil
+		}
+
+		readStart := time.Now()
 		batch, rerr := readBits(ctx)
+		readTime := time.Since(readStart)
 		if rerr != nil {
-			// Stop on read error
-			if !errors.Is(rerr, context.Canceled) {
-				log.Printf("read error: %v", rerr)
+			if errors.Is(rerr, context.Canceled) {
+				return nil
 			}
-			return
+			st.readError()
+			if errors.Is(rerr, io.EOF) && dev == naming.DeviceReplay {
+				log.Printf("replay finished after %d samples", sampleNum)
+				return nil
+			}
+			// A failed read from a device that has gone away pauses collection;
+			// anything else stops it as before.
+			if plugEvents != nil {
+				if present, lerr := hotplug.List(reader.watch); lerr == nil && len(present) == 0 {
+					log.Printf("read error: %v; %s detached, pausing", rerr, string(dev))
+					paused = true
+					st.setState("paused (device detached)")
+					continue
+				}
+			}
+			log.Printf("read error: %v", rerr)
+			return nil
 		}
 
 		// Write raw bytes to .bin
 		if _, werr := binBuf.Write(batch); werr != nil {
-			log.Fatalf("write bin: %v", werr)
+			return fmt.Errorf("write bin: %w", werr)
 		}
 		_ = binBuf.Flush()
 
 		// Compute ones across the intended bitCount
-		ones := countOnes(batch, bitCount)
+		ones := bitpack.OnesCount(batch, bitCount)
 		sampleNum++
-		ts := time.Now().Format("20060102T15:04:05")
+		ts := naming.FormatTimestamp(time.Now())
 		if _, werr := fmt.Fprintf(csvBuf, "%s,%d\n", ts, ones); werr != nil {
-			log.Fatalf("write csv: %v", werr)
+			return fmt.Errorf("write csv: %w", werr)
 		}
 		_ = csvBuf.Flush()
+		evlog.SetSample(sampleNum)
+		for _, f := range monitor.Feed(batch, bitCount) {
+			st.healthFailure(f)
+			log.Printf("health: sample %d: %v", sampleNum, f)
+		}
+		st.sample(len(batch), ones, readTime)
 
 		// Print progress to terminal
-		fmt.Printf("sample %d: ones=%d/%d at %s\n", sampleNum, ones, bitCount, ts)
+		if dash != nil {
+			dash.render()
+		} else {
+			fmt.Printf("sample %d: ones=%d/%d at %s\n", sampleNum, ones, bitCount, ts)
+		}
 
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
+		// Wait for the next tick, tracking presence changes meanwhile.
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case ev, ok := <-plugEvents:
+				if !ok {
+					plugEvents = nil
+					continue
+				}
+				handleEvent(ev)
+			case <-tick:
+				break wait
+			}
 		}
 	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+)
+
+// defaultKeys is the default -keys value.
+const defaultKeys = "s=+intention,e=-intention,h=high aim,l=low aim,m=mark"
+
+// errBackground is returned by keyInputMode when the process runs in the
+// background of its terminal; keys are not read then.
+var errBackground = errors.New("running in the background")
+
+// keyBinding is the event recorded for one key.
+type keyBinding struct {
+	kind  events.Kind
+	label string
+}
+
+// parseKeys parses a comma-separated list of key=label bindings. A label
+// starting with '+' starts the segment named by the rest, one starting with
+// '-' stops it, and any other label is a mark.
+func parseKeys(s string) (map[byte]keyBinding, error) {
+	keys := make(map[byte]keyBinding)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, label, ok := strings.Cut(item, "=")
+		if !ok || len(k) != 1 || k == "?" || strings.TrimSpace(label) == "" {
+			return nil, fmt.Errorf("invalid key binding %q (want key=label, key=+segment or key=-segment)", item)
+		}
+		b := keyBinding{kind: events.Mark, label: strings.TrimSpace(label)}
+		switch b.label[0] {
+		case '+':
+			b.kind, b.label = events.Start, strings.TrimSpace(b.label[1:])
+		case '-':
+			b.kind, b.label = events.Stop, strings.TrimSpace(b.label[1:])
+		}
+		if b.label == "" {
+			return nil, fmt.Errorf("invalid key binding %q: missing segment name", item)
+		}
+		keys[k[0]] = b
+	}
+	return keys, nil
+}
+
+// keysHelp describes the bindings in one line.
+func keysHelp(keys map[byte]keyBinding) string {
+	var parts []string
+	for k, b := range keys {
+		desc := b.label
+		if b.kind != events.Mark {
+			desc = string(b.kind) + " " + b.label
+		}
+		parts = append(parts, fmt.Sprintf("%c=%s", k, desc))
+	}
+	sort.Strings(parts)
+	return "event keys: " + strings.Join(parts, ", ") + ", ?=help"
+}
+
+// readKeys records an event in evlog for each bound key read from r until r
+// is exhausted. Line breaks are ignored, so keys followed by Enter work when
+// the terminal cannot be switched to single-key input.
+func readKeys(r io.Reader, keys map[byte]keyBinding, evlog *events.Log) {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		if c == '\n' || c == '\r' || c == ' ' {
+			continue
+		}
+		b, ok := keys[c]
+		if !ok {
+			log.Print(keysHelp(keys))
+			continue
+		}
+		if _, err := evlog.Add(b.kind, b.label); err != nil {
+			log.Printf("event: %v", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/Thiagojm/rng_go_cli/metrics"
+)
+
+// writeMetrics writes the run's metrics for Prometheus, labelled with the
+// device type and instance.
+func (s *status) writeMetrics(w *metrics.Writer) {
+	st := s.snapshot()
+	l := metrics.Labels{"device": st.device, "device_id": st.deviceID}
+	up := 0.0
+	if st.state == "collecting" {
+		up = 1
+	}
+	last := 0.0
+	if len(st.recent) > 0 {
+		last = float64(st.recent[len(st.recent)-1])
+	}
+	health := make(map[string]float64, len(st.healthByTest))
+	for k, v := range st.healthByTest {
+		health[k] = float64(v)
+	}
+
+	w.Gauge("rng_collect_up", "1 while collecting, 0 while paused (device detached).", l, up)
+	w.Gauge("rng_collect_start_time_seconds", "Start of the run, in seconds since the Unix epoch.", l, float64(st.start.Unix()))
+	w.Gauge("rng_collect_sample_bits", "Bits per sample.", l, float64(st.bits))
+	w.Counter("rng_collect_samples_total", "Samples collected.", l, float64(st.samples))
+	w.Counter("rng_collect_bytes_written_total", "Bytes written to the .bin file.", l, float64(st.bytes))
+	w.Counter("rng_collect_ones_total", "One bits counted over all samples.", l, float64(st.ones))
+	w.Gauge("rng_collect_last_sample_ones", "One bits in the latest sample.", l, last)
+	w.Gauge("rng_collect_cumulative_z", "Z-score of all ones counted so far against chance.", l, st.cumulativeZ())
+	w.Counter("rng_collect_read_errors_total", "Failed device reads.", l, float64(st.readErrors))
+	w.Counter("rng_collect_reconnects_total", "Times the device came back after being detached.", l, float64(st.reconnects))
+	w.CounterVec("rng_collect_health_failures_total", "SP 800-90B continuous health test failures, by test.", l, "test", health)
+	w.Histogram("rng_collect_read_duration_seconds", "Time taken by successful device reads.", l, s.latency)
+}
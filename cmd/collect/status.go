@@ -0,0 +1,202 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/health"
+	"github.com/Thiagojm/rng_go_cli/metrics"
+)
+
+// Limits on the history kept for the dashboard.
+const (
+	recentSamples = 16
+	zHistory      = 1024
+	rateWindow    = 10
+)
+
+// status is the live state of a collection run. The collection loop updates
+// it and the dashboards read snapshots; it is safe for concurrent use.
+type status struct {
+	mu   sync.Mutex
+	st   statusSnapshot
+	subs map[chan struct{}]struct{}
+	// latency holds the durations of successful reads, in seconds.
+	latency *metrics.Histogram
+}
+
+// newStatus returns the status of a run starting with st.
+func newStatus(st statusSnapshot) *status {
+	st.healthByTest = map[string]int{health.RepetitionCount: 0, health.AdaptiveProportion: 0}
+	return &status{st: st, latency: metrics.NewHistogram(metrics.ExponentialBuckets(0.001, 2, 14)...)}
+}
+
+// statusSnapshot is a copy of the state at one moment.
+type statusSnapshot struct {
+	device string
+	desc   string
+	// deviceID identifies the device instance, as in the metrics labels.
+	deviceID string
+	bits     int
+	interval time.Duration
+	start    time.Time
+	binPath  string
+	csvPath  string
+
+	// state is "collecting", "paused (...)" or "stopped".
+	state   string
+	samples int
+	bytes   int64
+	ones    int64
+	// recent holds the ones counts of the latest samples, oldest first.
+	recent []int
+	// times holds the times of the latest samples, for the rate.
+	times []time.Time
+	// z holds the cumulative z-score after each of the latest samples.
+	z []float64
+
+	readErrors int
+	reconnects int
+
+	rctCutoff, aptCutoff int
+	healthFailures       int
+	healthByTest         map[string]int
+	lastHealth           string
+
+	events    int
+	lastEvent string
+}
+
+// sample records a collected sample of n bytes with the given ones count,
+// read in the given time.
+func (s *status) sample(n, ones int, read time.Duration) {
+	s.latency.Observe(read.Seconds())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &s.st
+	st.samples++
+	st.bytes += int64(n)
+	st.ones += int64(ones)
+	st.recent = appendCapped(st.recent, ones, recentSamples)
+	st.times = appendCapped(st.times, time.Now(), rateWindow)
+	st.z = appendCapped(st.z, st.cumulativeZ(), zHistory)
+	s.changed()
+}
+
+// setState records the collection state.
+func (s *status) setState(state string) {
+	s.mu.Lock()
+	s.st.state = state
+	s.changed()
+	s.mu.Unlock()
+}
+
+// readError counts a failed read.
+func (s *status) readError() {
+	s.mu.Lock()
+	s.st.readErrors++
+	s.changed()
+	s.mu.Unlock()
+}
+
+// reconnect counts a device that came back after being detached.
+func (s *status) reconnect() {
+	s.mu.Lock()
+	s.st.reconnects++
+	s.changed()
+	s.mu.Unlock()
+}
+
+// healthFailure records a failed health test.
+func (s *status) healthFailure(f health.Failure) {
+	s.mu.Lock()
+	s.st.healthFailures++
+	s.st.healthByTest[f.Test]++
+	s.st.lastHealth = f.Error()
+	s.changed()
+	s.mu.Unlock()
+}
+
+// event records an operator event.
+func (s *status) event(e events.Event) {
+	s.mu.Lock()
+	s.st.events++
+	s.st.lastEvent = e.String()
+	s.changed()
+	s.mu.Unlock()
+}
+
+// subscribe returns a channel that receives a value after the state
+// changes; changes made while a value is pending are coalesced. cancel
+// stops the notifications.
+func (s *status) subscribe() (ch <-chan struct{}, cancel func()) {
+	c := make(chan struct{}, 1)
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[chan struct{}]struct{})
+	}
+	s.subs[c] = struct{}{}
+	s.mu.Unlock()
+	return c, func() {
+		s.mu.Lock()
+		delete(s.subs, c)
+		s.mu.Unlock()
+	}
+}
+
+// changed notifies the subscribers. s.mu must be held.
+func (s *status) changed() {
+	for c := range s.subs {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// snapshot returns a copy of the current state.
+func (s *status) snapshot() statusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.st
+	c.recent = append([]int(nil), c.recent...)
+	c.times = append([]time.Time(nil), c.times...)
+	c.z = append([]float64(nil), c.z...)
+	c.healthByTest = make(map[string]int, len(s.st.healthByTest))
+	for k, v := range s.st.healthByTest {
+		c.healthByTest[k] = v
+	}
+	return c
+}
+
+// cumulativeZ is the z-score of all ones counted so far against chance.
+func (st *statusSnapshot) cumulativeZ() float64 {
+	n := float64(st.samples) * float64(st.bits)
+	if n == 0 {
+		return 0
+	}
+	return (float64(st.ones) - n/2) / math.Sqrt(n/4)
+}
+
+// rate returns the recent sample rate per second, or 0 before two samples.
+func (st *statusSnapshot) rate() float64 {
+	if len(st.times) < 2 {
+		return 0
+	}
+	d := st.times[len(st.times)-1].Sub(st.times[0]).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(len(st.times)-1) / d
+}
+
+// appendCapped appends v to s, dropping the oldest values beyond max.
+func appendCapped[T any](s []T, v T, max int) []T {
+	s = append(s, v)
+	if len(s) > max {
+		s = append(s[:0], s[len(s)-max:]...)
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// keyInputMode switches the terminal on f to deliver key presses one at a
+// time without echo, keeping Ctrl+C working. It fails if f is not a
+// terminal, and returns errBackground if the process is not in the
+// terminal's foreground, where reading it would stop the process. The
+// returned function restores the previous mode.
+func keyInputMode(f *os.File) (restore func(), err error) {
+	fd := int(f.Fd())
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	if pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP); err == nil && pgrp != unix.Getpgrp() {
+		return nil, errBackground
+	}
+	t := *old
+	t.Lflag &^= unix.ICANON | unix.ECHO
+	t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &t); err != nil {
+		return nil, err
+	}
+	return func() { _ = unix.IoctlSetTermios(fd, unix.TCSETS, old) }, nil
+}
+
+// dashboardMode prepares the terminal on f for the -tui dashboard. It fails
+// if f is not a terminal. The returned function undoes any changes.
+func dashboardMode(f *os.File) (restore func(), err error) {
+	if _, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
+
+// terminalWidth returns the width of the terminal on f, or 0 if unknown.
+func terminalWidth(f *os.File) int {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// keyInputMode is not implemented here; keys are then read a line at a
+// time.
+func keyInputMode(f *os.File) (restore func(), err error) {
+	return nil, errors.New("single-key input not supported on this platform")
+}
+
+// dashboardMode is not implemented here; -tui falls back to plain lines.
+func dashboardMode(f *os.File) (restore func(), err error) {
+	return nil, errors.New("dashboard not supported on this platform")
+}
+
+// terminalWidth is unknown here.
+func terminalWidth(f *os.File) int {
+	return 0
+}
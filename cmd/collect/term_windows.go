@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// keyInputMode switches the console on f to deliver key presses one at a
+// time without echo, keeping Ctrl+C working. It fails if f is not a
+// console. The returned function restores the previous mode.
+func keyInputMode(f *os.File) (restore func(), err error) {
+	h := windows.Handle(f.Fd())
+	var old uint32
+	if err := windows.GetConsoleMode(h, &old); err != nil {
+		return nil, err
+	}
+	mode := old &^ (windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT)
+	if err := windows.SetConsoleMode(h, mode); err != nil {
+		return nil, err
+	}
+	return func() { _ = windows.SetConsoleMode(h, old) }, nil
+}
+
+// dashboardMode prepares the console on f for the -tui dashboard by enabling
+// ANSI escape sequences. It fails if f is not a console or the console
+// cannot process them. The returned function restores the previous mode.
+func dashboardMode(f *os.File) (restore func(), err error) {
+	h := windows.Handle(f.Fd())
+	var old uint32
+	if err := windows.GetConsoleMode(h, &old); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(h, old|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return nil, err
+	}
+	return func() { _ = windows.SetConsoleMode(h, old) }, nil
+}
+
+// terminalWidth returns the width of the console window on f, or 0 if
+// unknown.
+func terminalWidth(f *os.File) int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+//go:embed web/index.html
+var indexHTML []byte
+
+// sseKeepAlive is how often an idle stream sends a comment, so proxies and
+// browsers keep the connection open.
+const sseKeepAlive = 15 * time.Second
+
+// webInfo describes the run; it is sent once when a stream opens.
+type webInfo struct {
+	Device          string      `json:"device"`
+	Description     string      `json:"description"`
+	Bits            int         `json:"bits"`
+	IntervalSeconds float64     `json:"interval_seconds"`
+	Start           time.Time   `json:"start"`
+	Bin             string      `json:"bin"`
+	CSV             string      `json:"csv"`
+	Meta            runMetadata `json:"meta"`
+}
+
+// webUpdate is the state sent on every change.
+type webUpdate struct {
+	State   string  `json:"state"`
+	Samples int     `json:"samples"`
+	Z       float64 `json:"z"`
+	P       float64 `json:"p"`
+	Rate    float64 `json:"rate"`
+	// Points holds [sample, cumulative z] pairs for the samples not sent
+	// before on this stream.
+	Points [][2]float64 `json:"points"`
+	// Recent holds the ones counts of the latest samples, oldest first.
+	Recent         []int          `json:"recent"`
+	BinBytes       int64          `json:"bin_bytes"`
+	ReadErrors     int            `json:"read_errors"`
+	Reconnects     int            `json:"reconnects"`
+	HealthFailures int            `json:"health_failures"`
+	LastHealth     string         `json:"last_health,omitempty"`
+	Events         []events.Event `json:"events"`
+}
+
+// newWebUpdate builds the update for st, with the points after sample sent.
+func newWebUpdate(st statusSnapshot, sent int, evs []events.Event) webUpdate {
+	z := st.cumulativeZ()
+	u := webUpdate{
+		State:          st.state,
+		Samples:        st.samples,
+		Z:              z,
+		P:              stats.TwoSidedP(z),
+		Rate:           st.rate(),
+		Recent:         st.recent,
+		BinBytes:       st.bytes,
+		ReadErrors:     st.readErrors,
+		Reconnects:     st.reconnects,
+		HealthFailures: st.healthFailures,
+		LastHealth:     st.lastHealth,
+		Events:         evs,
+	}
+	// st.z[i] is the z after sample first+i.
+	first := st.samples - len(st.z) + 1
+	for i, v := range st.z {
+		if n := first + i; n > sent {
+			u.Points = append(u.Points, [2]float64{float64(n), v})
+		}
+	}
+	if u.Events == nil {
+		u.Events = []events.Event{}
+	}
+	return u
+}
+
+// webHandler serves the browser dashboard:
+//
+//	/        the page, which follows /stream
+//	/status  the current state as JSON, with the run description
+//	/stream  Server-Sent Events: one "info" event, then an "update" event
+//	         after every change
+func webHandler(st *status, info webInfo, evlog *events.Log) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(indexHTML)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Info   webInfo   `json:"info"`
+			Update webUpdate `json:"update"`
+		}{info, newWebUpdate(st.snapshot(), 0, evlog.Events())})
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		changes, cancel := st.subscribe()
+		defer cancel()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		send := func(event string, v any) bool {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+		if !send("info", info) {
+			return
+		}
+		sent := 0
+		update := func() bool {
+			snap := st.snapshot()
+			ok := send("update", newWebUpdate(snap, sent, evlog.Events()))
+			sent = snap.samples
+			return ok
+		}
+		if !update() {
+			return
+		}
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changes:
+				if !update() {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+	return mux
+}
+
+// newWebInfo describes the run for the browser dashboard.
+func newWebInfo(st statusSnapshot, meta runMetadata) webInfo {
+	return webInfo{
+		Device:          st.device,
+		Description:     st.desc,
+		Bits:            st.bits,
+		IntervalSeconds: st.interval.Seconds(),
+		Start:           st.start,
+		Bin:             filepath.Base(st.binPath),
+		CSV:             filepath.Base(st.csvPath),
+		Meta:            meta,
+	}
+}
@@ -0,0 +1,58 @@
+// Command egdclient reads from an EGD (Entropy Gathering Daemon) server,
+// such as rngd -egd, to check that it works.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/egd"
+)
+
+func main() {
+	addr := flag.String("addr", "unix:/var/run/egd-pool", "server address: unix:/path, tcp:host:port, a path or host:port")
+	n := flag.Int("n", 32, "bytes to read (blocking)")
+	nonblock := flag.Bool("nonblock", false, "read with the non-blocking command (at most 255 bytes, possibly fewer)")
+	raw := flag.Bool("raw", false, "write the bytes to stdout instead of printing hex")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout (0 = none)")
+	flag.Parse()
+
+	c, err := egd.Dial(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	c.Timeout = *timeout
+
+	if !*raw {
+		pid, err := c.PID()
+		if err != nil {
+			log.Fatalf("pid: %v", err)
+		}
+		level, err := c.EntropyLevel()
+		if err != nil {
+			log.Fatalf("entropy level: %v", err)
+		}
+		fmt.Printf("server pid %s, %d bits available\n", pid, level)
+	}
+
+	var data []byte
+	if *nonblock {
+		data, err = c.ReadNonblocking(*n)
+	} else {
+		data = make([]byte, *n)
+		_, err = c.Read(data)
+	}
+	if err != nil {
+		log.Fatalf("read: %v", err)
+	}
+	if *raw {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	fmt.Printf("%d bytes: %s\n", len(data), hex.EncodeToString(data))
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+const (
+	// maxChartPoints bounds the points kept for a chart; longer captures are
+	// decimated.
+	maxChartPoints = 32000
+	// maxStoredRowErrors bounds the skipped rows kept for reporting.
+	maxStoredRowErrors = 1000
+)
+
+// analysis is the result of a first, streaming pass over one input file. It
+// keeps summary values and a decimated chart series rather than every row;
+// scan reads the file again when all rows are needed.
+type analysis struct {
+	Path      string
+	Interval  int
+	BlockSize int
+	// Header is the first column header: "samples" or "time".
+	Header string
+	// Samples is the number of valid rows.
+	Samples int
+	FinalZ  float64
+	// FinalDeviation is the cumulative deviation after the last sample.
+	FinalDeviation float64
+	// ChiSquare is the sum of squared per-sample z-scores, with Samples
+	// degrees of freedom; ChiSquareP is its upper-tail p-value.
+	ChiSquare, ChiSquareP float64
+	// MaxAbsZ and MaxAbsDeviation are the largest magnitudes reached by the
+	// cumulative z-score and deviation.
+	MaxAbsZ, MaxAbsDeviation float64
+	// MeanOnes and SDOnes describe the ones count per sample.
+	MeanOnes, SDOnes float64
+	// Hist counts samples by ones count.
+	Hist map[int]int
+	// First and Last are the first and last timestamps of .csv input.
+	First, Last time.Time
+	// Bad holds up to maxStoredRowErrors skipped rows; BadCount counts all.
+	Bad      []RowError
+	BadCount int
+	// Chart holds every ChartStep-th row plus the last one, so it never has
+	// more than maxChartPoints+1 entries.
+	Chart     []DataRow
+	ChartStep int
+	// Events are the operator markers recorded with the capture, if any.
+	Events []events.Event
+	// Autocorrelation, Spectrum and BitAutocorrelation describe serial
+	// dependence; each is nil when not computed or the capture is too short.
+	// BitAutocorrelation needs .bin input.
+	Autocorrelation    *autocorrReport
+	Spectrum           *spectrumReport
+	BitAutocorrelation *bitAutocorrReport
+
+	loc   *time.Location
+	align bitpack.Alignment
+}
+
+// TimeAxis reports whether the rows carry timestamps.
+func (a *analysis) TimeAxis() bool { return !a.First.IsZero() }
+
+// analyze reads filePath once and computes the cumulative z-scores and the
+// serial dependence analyses selected by opts. loc is the time zone of
+// zone-less .csv timestamps.
+func analyze(filePath string, loc *time.Location, opts serialOptions) (*analysis, error) {
+	interval, err := findInterval(filePath)
+	if err != nil {
+		return nil, err
+	}
+	blockSize, err := findBitCount(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if blockSize <= 0 {
+		return nil, errors.New("invalid block size")
+	}
+
+	a := &analysis{Path: filePath, Interval: interval, BlockSize: blockSize, ChartStep: 1, Hist: make(map[int]int), loc: loc}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".bin":
+		a.Header = blockColumnName
+		if a.align, err = detectBinAlignment(filePath, blockSize); err != nil {
+			return nil, err
+		}
+		switch a.align {
+		case bitpack.AlignLSB:
+			fmt.Fprintf(os.Stderr, "%s: legacy LSB-aligned padding detected; run binrepack to convert the file\n", filepath.Base(filePath))
+		case bitpack.AlignUnknown:
+			fmt.Fprintf(os.Stderr, "%s: padding bits are set in some samples; they are ignored\n", filepath.Base(filePath))
+			a.align = bitpack.AlignMSB
+		}
+	case ".csv":
+		a.Header = timeColumnName
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(filePath))
+	}
+
+	var last DataRow
+	var sum, sumSq float64
+	serial := newSerialAccumulator(blockSize, opts)
+	var bitAC *bitAutocorr
+	var onBlock func([]byte)
+	if a.Header == blockColumnName && opts.BitLags > 0 {
+		bitAC = newBitAutocorr(opts.BitLags)
+		onBlock = bitAC.blockFunc(blockSize)
+	}
+	onBad := func(e RowError) {
+		if len(a.Bad) < maxStoredRowErrors {
+			a.Bad = append(a.Bad, e)
+		}
+		a.BadCount++
+	}
+	err = a.scanRows(func(r DataRow) error {
+		if a.Samples == 0 {
+			a.First = r.Time
+		}
+		if a.Samples%a.ChartStep == 0 {
+			a.Chart = append(a.Chart, r)
+			if len(a.Chart) > maxChartPoints {
+				a.Chart = halve(a.Chart)
+				a.ChartStep *= 2
+			}
+		}
+		a.Samples++
+		a.MaxAbsZ = math.Max(a.MaxAbsZ, math.Abs(r.ZScore))
+		a.MaxAbsDeviation = math.Max(a.MaxAbsDeviation, math.Abs(r.CumulativeDeviation))
+		a.Hist[r.Ones]++
+		sum += float64(r.Ones)
+		sumSq += float64(r.Ones) * float64(r.Ones)
+		serial.add(r.Ones)
+		last = r
+		return nil
+	}, onBad, onBlock)
+	if err != nil {
+		return nil, err
+	}
+	if a.Samples > 0 {
+		a.Autocorrelation = serial.autocorrelation()
+		a.Spectrum = serial.spectrum(a.Interval)
+		if bitAC != nil {
+			a.BitAutocorrelation = bitAC.report()
+		}
+	}
+	a.Last, a.FinalZ, a.FinalDeviation = last.Time, last.ZScore, last.CumulativeDeviation
+	a.ChiSquare = last.ChiSquare
+	if n := float64(a.Samples); n > 0 {
+		a.ChiSquareP = stats.ChiSquareP(a.ChiSquare, a.Samples)
+		a.MeanOnes = sum / n
+		if n > 1 {
+			a.SDOnes = math.Sqrt(math.Max(0, (sumSq-sum*sum/n)/(n-1)))
+		}
+	}
+	// End the chart on the final z-score.
+	if a.Samples > 0 && (a.Samples-1)%a.ChartStep != 0 {
+		a.Chart = append(a.Chart, last)
+	}
+	if a.Samples == 0 && a.BadCount > 0 {
+		return a, fmt.Errorf("no parseable rows in %s", filepath.Base(filePath))
+	}
+	// A broken events file should not cost the analysis.
+	a.Events, err = events.Read(events.Path(filePath), loc)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "%s: events ignored: %v\n", filepath.Base(filePath), err)
+	}
+	return a, nil
+}
+
+// scan reads the input again, calling fn with every valid row and its
+// cumulative statistics. Skipped rows are not reported again.
+func (a *analysis) scan(fn func(DataRow) error) error {
+	return a.scanRows(fn, nil, nil)
+}
+
+// scanRows streams the input through a zScorer into fn; onBad, if non-nil,
+// receives skipped .csv rows, and onBlock, if non-nil, the raw blocks of
+// .bin input.
+func (a *analysis) scanRows(fn func(DataRow) error, onBad func(RowError), onBlock func([]byte)) error {
+	z := newZScorer(a.BlockSize)
+	score := func(r DataRow) error {
+		z.add(&r)
+		return fn(r)
+	}
+	if a.Header == blockColumnName {
+		return scanBinFile(a.Path, a.BlockSize, a.align, onBlock, score)
+	}
+	return scanCSVFile(a.Path, a.loc, score, onBad)
+}
+
+// halve keeps every other entry of rows, in place.
+func halve(rows []DataRow) []DataRow {
+	n := 0
+	for i := 0; i < len(rows); i += 2 {
+		rows[n] = rows[i]
+		n++
+	}
+	return rows[:n]
+}
+
+// scanBinFile streams a .bin file, calling fn with the ones count of each
+// block. The block size is specified in bits; each block occupies
+// ceil(blockSize/8) bytes, and padding bits are not counted. align gives the
+// padding layout of the file (see detectBinAlignment) so legacy captures
+// count correctly. onBlock, if non-nil, sees each block in MSB-first layout
+// before fn.
+func scanBinFile(filePath string, blockSize int, align bitpack.Alignment, onBlock func([]byte), fn func(DataRow) error) error {
+	bytesPerBlock := bitpack.BytesFor(blockSize)
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	buf := make([]byte, bytesPerBlock)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if n == 0 {
+			break
+		}
+		// Allow partial block at EOF; error only if it's not EOF
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		if n == bytesPerBlock {
+			_ = bitpack.Repack(buf, blockSize, align)
+		}
+		if onBlock != nil {
+			onBlock(buf[:n])
+		}
+		if err := fn(DataRow{Ones: bitpack.OnesCount(buf[:n], blockSize)}); err != nil {
+			return err
+		}
+		if n < bytesPerBlock {
+			break
+		}
+	}
+	return nil
+}
+
+// detectBinAlignment reports how the padding of blockSize-bit samples in a
+// .bin file is aligned (see bitpack.DetectAlignment).
+func detectBinAlignment(filePath string, blockSize int) (bitpack.Alignment, error) {
+	if bitpack.Padding(blockSize) == 0 {
+		return bitpack.AlignMSB, nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return bitpack.AlignUnknown, err
+	}
+	defer f.Close()
+	d := bitpack.NewDetector(blockSize)
+	if _, err := io.Copy(d, f); err != nil {
+		return bitpack.AlignUnknown, err
+	}
+	return d.Alignment(), nil
+}
+
+// scanCSVFile streams a .csv file with two columns: timestamp and ones count,
+// calling fn for each valid row. Timestamps are parsed with
+// naming.ParseTimestampOn, reading zone-less values in loc and anchoring
+// time-only values to the capture time in the file name; without one they
+// are rejected. Rows that cannot be parsed are skipped and, if onBad is
+// non-nil, passed to it.
+func scanCSVFile(filePath string, loc *time.Location, fn func(DataRow) error, onBad func(RowError)) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var start time.Time
+	if info, err := naming.ParseBaseName(filePath); err == nil {
+		// The name holds wall-clock time; read it in loc like the rows.
+		y, mo, d := info.Time.Date()
+		h, mi, sec := info.Time.Clock()
+		start = time.Date(y, mo, d, h, mi, sec, 0, loc)
+	}
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	bad := func(line int, rec []string, err error) {
+		if onBad != nil {
+			onBad(RowError{Line: line, Text: strings.Join(rec, ","), Err: err})
+		}
+	}
+	// Expect no header; Python version used header=None
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			bad(perr.StartLine, rec, perr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := r.FieldPos(0)
+		if len(rec) < 2 {
+			bad(line, rec, errors.New("expected timestamp,ones"))
+			continue
+		}
+		t, err := naming.ParseTimestampOn(rec[0], loc, start)
+		if err != nil {
+			bad(line, rec, err)
+			continue
+		}
+		onesStr := strings.TrimSpace(rec[1])
+		ones, err := strconv.Atoi(onesStr)
+		if err != nil {
+			bad(line, rec, fmt.Errorf("invalid ones value '%s'", onesStr))
+			continue
+		}
+		if err := fn(DataRow{Time: t, Ones: ones}); err != nil {
+			return err
+		}
+	}
+}
+
+// reportRowErrors prints skipped rows to w, listing at most maxListed of
+// them. total is the number skipped, which may exceed len(bad).
+func reportRowErrors(w io.Writer, filePath string, bad []RowError, total, maxListed int) {
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s: skipped %d unparseable row(s):\n", filepath.Base(filePath), total)
+	for i, e := range bad {
+		if i == maxListed {
+			break
+		}
+		fmt.Fprintf(w, "  %v: %q\n", e, e.Text)
+	}
+	if listed := min(len(bad), maxListed); total > listed {
+		fmt.Fprintf(w, "  ... and %d more\n", total-listed)
+	}
+}
+
+// zScorer computes the cumulative statistics row by row.
+// expected_mean = 0.5 * block_size
+// expected_std_dev = sqrt(block_size * 0.25)
+// z_i = (cum_mean_i - expected_mean) / (expected_std_dev / sqrt(i+1))
+// sample_z_i = (ones_i - expected_mean) / expected_std_dev
+// chi_square_i = sum of sample_z² up to i
+//
+// With a constant block size z_i equals the Stouffer Z of the per-sample
+// z-scores, sum(sample_z) / sqrt(i+1).
+type zScorer struct {
+	expectedMean   float64
+	expectedStdDev float64
+	n, sum         int
+	chi            float64
+}
+
+func newZScorer(blockSize int) *zScorer {
+	return &zScorer{
+		expectedMean:   0.5 * float64(blockSize),
+		expectedStdDev: math.Sqrt(float64(blockSize) * 0.25),
+	}
+}
+
+// add fills in r's sample number and cumulative statistics.
+func (z *zScorer) add(r *DataRow) {
+	z.n++
+	z.sum += r.Ones
+	r.Index = z.n
+	r.CumulativeDeviation = float64(z.sum) - z.expectedMean*float64(z.n)
+	if z.expectedStdDev == 0 {
+		return
+	}
+	r.SampleZ = (float64(r.Ones) - z.expectedMean) / z.expectedStdDev
+	z.chi += r.SampleZ * r.SampleZ
+	r.ChiSquare = z.chi
+	cumMean := float64(z.sum) / float64(z.n)
+	r.CumulativeMean = cumMean
+	r.ZScore = (cumMean - z.expectedMean) / (z.expectedStdDev / math.Sqrt(float64(z.n)))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAnalyzeNoSamples checks that inputs without a usable sample report
+// an error instead of reaching the serial analyses.
+func TestAnalyzeNoSamples(t *testing.T) {
+	opts := serialOptions{Lags: 50, FFT: 1024, BitLags: 32}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string // from analyze; "" means process must fail instead
+	}{
+		{"empty csv", "20261001T130000_trng_s16_i1.csv", "", ""},
+		{"empty bin", "20261001T130000_bitb_s16_i1.bin", "", ""},
+		{"all rows bad", "20261001T130000_trng_s16_i1.csv", "x,1\n20261001T13:00:00,y\n", "no parseable rows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			a, err := analyze(path, time.UTC, opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("analyze: err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("analyze: %v", err)
+			} else if a.Samples != 0 || a.Autocorrelation != nil || a.Spectrum != nil || a.BitAutocorrelation != nil {
+				t.Fatalf("analyze: %d samples, serial results %v %v %v", a.Samples, a.Autocorrelation, a.Spectrum, a.BitAutocorrelation)
+			}
+			for _, format := range []string{"xlsx", "html", "json"} {
+				b := &batch{loc: time.UTC, serial: opts, formats: map[string]bool{format: true}, progress: io.Discard}
+				if r := b.process(path); r.Err == nil {
+					t.Errorf("process with %s: no error", format)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/stats"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	summarySheetName = "Summary"
+	// correlationSheetName holds the correlation without -combined.
+	correlationSheetName = "Correlation"
+	maxSheetNameLen      = 31
+)
+
+// batch processes many input files, either into one workbook each or into a
+// single combined workbook with a summary sheet.
+type batch struct {
+	loc *time.Location
+	// serial selects the serial dependence analyses.
+	serial serialOptions
+	// correlate compares the inputs as simultaneous captures, in the
+	// combined outputs or, without them, in outputs of its own.
+	correlate bool
+	// jobs is the number of files analysed in parallel.
+	jobs int
+	// formats are the outputs to write: "xlsx", "html" and/or "json".
+	formats map[string]bool
+	// combined, if set, is the path of the combined outputs; its extension
+	// is replaced by each format's.
+	combined string
+	// progress receives one line per finished file.
+	progress io.Writer
+
+	mu   sync.Mutex // guards done and writes to progress/stderr
+	done int
+}
+
+// fileResult is the outcome for one input file.
+type fileResult struct {
+	Path    string
+	Samples int
+	FinalZ  float64
+	// FinalDeviation, ChiSquare and ChiSquareP are as in analysis.
+	FinalDeviation        float64
+	ChiSquare, ChiSquareP float64
+	// Bad lists (up to maxStoredRowErrors of) the BadCount skipped rows.
+	Bad      []RowError
+	BadCount int
+	// Notes describe sheet splits and chart downsampling.
+	Notes []string
+	// Analysis is kept for the combined workbook only.
+	Analysis *analysis
+	// Report is kept for combined HTML and JSON output only.
+	Report *fileReport
+	Err    error
+}
+
+// pValue returns the two-sided p-value of the final z-score.
+func (r fileResult) pValue() float64 {
+	return stats.TwoSidedP(r.FinalZ)
+}
+
+// expandInputs resolves the command-line arguments to a list of input files.
+// A directory contributes its .bin and .csv files (not recursively); an
+// argument that does not exist is treated as a glob pattern, which helps on
+// shells that do not expand them.
+func expandInputs(args []string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if k := pathKey(p); !seen[k] {
+			seen[k] = true
+			out = append(out, p)
+		}
+	}
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		switch {
+		case err == nil && fi.IsDir():
+			entries, err := os.ReadDir(arg)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				if !e.IsDir() && supportedInput(e.Name()) {
+					add(filepath.Join(arg, e.Name()))
+				}
+			}
+		case err == nil:
+			// Shell globs such as *.csv also match sidecar files.
+			if !isSidecarFile(arg) {
+				add(arg)
+			}
+		case strings.ContainsAny(arg, "*?["):
+			matches, gerr := filepath.Glob(arg)
+			if gerr != nil {
+				return nil, fmt.Errorf("%s: %w", arg, gerr)
+			}
+			sort.Strings(matches)
+			n := 0
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && !fi.IsDir() && supportedInput(m) {
+					add(m)
+					n++
+				}
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("no .bin or .csv files match %s", arg)
+			}
+		default:
+			return nil, err
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no .bin or .csv files found")
+	}
+	return out, nil
+}
+
+// supportedInput reports whether name has a .bin or .csv extension and is
+// not a sidecar file.
+func supportedInput(name string) bool {
+	if isSidecarFile(name) {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".bin", ".csv":
+		return true
+	}
+	return false
+}
+
+// isSidecarFile reports whether name is a CSV file that accompanies a
+// capture rather than being one: <base>.events.csv from collect or
+// <base>.trials.csv from rngexp.
+func isSidecarFile(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".events.csv") || strings.HasSuffix(name, ".trials.csv")
+}
+
+// outputPath returns the per-file output of the given format for input:
+// the input path with the format appended, e.g. "x.bin.xlsx", so that a
+// .bin capture and its sidecar .csv do not write to the same file.
+func outputPath(input, format string) string {
+	return input + "." + format
+}
+
+// combinedPath returns the combined output of the given format, b.combined
+// with its extension replaced.
+func (b *batch) combinedPath(format string) string {
+	return strings.TrimSuffix(b.combined, filepath.Ext(b.combined)) + "." + format
+}
+
+// pathKey returns the key under which path is compared with others: the
+// cleaned absolute path, case-folded where file names are usually
+// case-insensitive.
+func pathKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.Clean(path)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// checkOutputs returns an error if two of the outputs that would be written
+// for paths are the same file, or if an output would overwrite an input.
+func (b *batch) checkOutputs(paths []string) error {
+	inputs := make(map[string]bool)
+	for _, p := range paths {
+		inputs[pathKey(p)] = true
+	}
+	outputs := make(map[string]string) // key -> input or flag writing it
+	claim := func(out, from string) error {
+		k := pathKey(out)
+		if inputs[k] {
+			return fmt.Errorf("%s: output of %s would overwrite an input", out, from)
+		}
+		if prev, ok := outputs[k]; ok {
+			return fmt.Errorf("%s: written for both %s and %s", out, prev, from)
+		}
+		outputs[k] = from
+		return nil
+	}
+	for _, f := range []string{"xlsx", "html", "json"} {
+		if !b.formats[f] {
+			continue
+		}
+		if b.correlate && b.combined == "" {
+			if err := claim(correlationPath(paths, f), "-correlate"); err != nil {
+				return err
+			}
+		}
+		if b.combined != "" {
+			if err := claim(b.combinedPath(f), "-combined"); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, p := range paths {
+			if err := claim(outputPath(p, f), p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run analyses paths with b.jobs workers and writes the workbook(s). It
+// returns an error if any file failed; the others are still written.
+// Nothing is written if two outputs would collide.
+func (b *batch) run(paths []string) error {
+	if err := b.checkOutputs(paths); err != nil {
+		return err
+	}
+	results := make([]fileResult, len(paths))
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				results[i] = b.process(paths[i])
+				b.report(len(paths), results[i])
+			}
+		}()
+	}
+	for i := range paths {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	var corr *correlationReport
+	if b.correlate {
+		var err error
+		if corr, err = b.correlateResults(results); err != nil {
+			return fmt.Errorf("correlate: %w", err)
+		}
+	}
+	if b.combined != "" {
+		if err := b.writeCombined(results, corr); err != nil {
+			return err
+		}
+	} else if corr != nil {
+		if err := b.writeCorrelation(paths, corr); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", failed, len(paths))
+	}
+	return nil
+}
+
+// process analyses one file and, unless a combined workbook is being
+// built, writes its own workbook.
+func (b *batch) process(path string) fileResult {
+	r := fileResult{Path: path}
+	a, err := analyze(path, b.loc, b.serial)
+	if a != nil {
+		r.Samples = a.Samples
+		r.Bad, r.BadCount = a.Bad, a.BadCount
+		r.FinalZ, r.FinalDeviation = a.FinalZ, a.FinalDeviation
+		r.ChiSquare, r.ChiSquareP = a.ChiSquare, a.ChiSquareP
+	}
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if a.Samples == 0 {
+		r.Err = errors.New("no data to write")
+		return r
+	}
+	r.Notes = serialNotes(a)
+	if b.combined != "" {
+		if b.formats["xlsx"] {
+			r.Analysis = a
+		}
+		if b.formats["html"] || b.formats["json"] {
+			r.Report = newFileReport(a)
+		}
+		return r
+	}
+	if b.formats["xlsx"] {
+		notes, err := writeToExcel(a, outputPath(path, "xlsx"))
+		r.Notes = append(r.Notes, notes...)
+		if r.Err = err; err != nil {
+			return r
+		}
+	}
+	if b.formats["html"] || b.formats["json"] {
+		rep := newFileReport(a)
+		if b.formats["html"] {
+			if r.Err = writeHTMLReport(outputPath(path, "html"), rep.File, []*fileReport{rep}, nil); r.Err != nil {
+				return r
+			}
+		}
+		if b.formats["json"] {
+			r.Err = writeJSONReport(outputPath(path, "json"), rep)
+		}
+	}
+	return r
+}
+
+// correlateResults compares the files analysed without error and prints
+// the outcome to progress.
+func (b *batch) correlateResults(results []fileResult) (*correlationReport, error) {
+	var paths []string
+	for _, r := range results {
+		if r.Err == nil {
+			paths = append(paths, r.Path)
+		}
+	}
+	corr, err := correlate(paths, b.loc)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range correlationNotes(corr) {
+		fmt.Fprintln(b.progress, n)
+	}
+	return corr, nil
+}
+
+// correlationPath returns the output of the given format for the
+// correlation of paths without -combined: "correlation" in the directory of
+// the first input, prefixed with the earliest capture time in the input
+// names so that sessions kept in one directory do not overwrite each other,
+// e.g. "20250301T100000_correlation.html".
+func correlationPath(paths []string, format string) string {
+	var start time.Time
+	for _, p := range paths {
+		if info, err := naming.ParseBaseName(p); err == nil && (start.IsZero() || info.Time.Before(start)) {
+			start = info.Time
+		}
+	}
+	name := "correlation." + format
+	if !start.IsZero() {
+		name = start.Format("20060102T150405") + "_" + name
+	}
+	return filepath.Join(filepath.Dir(paths[0]), name)
+}
+
+// writeCorrelation writes corr in every requested format when there is no
+// combined output to hold it.
+func (b *batch) writeCorrelation(paths []string, corr *correlationReport) error {
+	if b.formats["xlsx"] {
+		path := correlationPath(paths, "xlsx")
+		f := excelize.NewFile()
+		defer f.Close()
+		if err := f.SetSheetName(f.GetSheetName(0), correlationSheetName); err != nil {
+			return err
+		}
+		writeCorrelationSummary(f, correlationSheetName, 1, corr)
+		_ = f.SetColWidth(correlationSheetName, "A", "B", 40)
+		_ = f.SetColWidth(correlationSheetName, "C", "J", 14)
+		if err := f.SaveAs(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	if b.formats["html"] {
+		path := correlationPath(paths, "html")
+		if err := writeHTMLReport(path, "Simultaneous captures", nil, corr); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	if b.formats["json"] {
+		path := correlationPath(paths, "json")
+		if err := writeJSONReport(path, corr); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	return nil
+}
+
+// writeCombined writes the combined output of every requested format; corr,
+// if non-nil, is included.
+func (b *batch) writeCombined(results []fileResult, corr *correlationReport) error {
+	if b.formats["xlsx"] {
+		path := b.combinedPath("xlsx")
+		if err := writeCombined(path, results, corr, b.progress); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	if !b.formats["html"] && !b.formats["json"] {
+		return nil
+	}
+	reports := make([]*fileReport, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			reports[i] = failedReport(r.Path, r.Err)
+		} else {
+			reports[i] = r.Report
+		}
+	}
+	if b.formats["html"] {
+		path := b.combinedPath("html")
+		if err := writeHTMLReport(path, strings.TrimSuffix(filepath.Base(b.combined), filepath.Ext(b.combined)), reports, corr); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	if b.formats["json"] {
+		path := b.combinedPath("json")
+		rep := combinedReport{Generated: time.Now(), Files: reports, Correlation: corr}
+		rep.StoufferZ, rep.StoufferFiles = stoufferOfReports(reports)
+		rep.StoufferP = stats.TwoSidedP(rep.StoufferZ)
+		if err := writeJSONReport(path, rep); err != nil {
+			return err
+		}
+		fmt.Fprintf(b.progress, "wrote %s\n", path)
+	}
+	return nil
+}
+
+// report prints a progress line for r, followed by any skipped rows.
+func (b *batch) report(total int, r fileResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done++
+	name := filepath.Base(r.Path)
+	if r.Err != nil {
+		fmt.Fprintf(b.progress, "[%d/%d] %s: error: %v\n", b.done, total, name, r.Err)
+	} else {
+		fmt.Fprintf(b.progress, "[%d/%d] %s: %d samples, z=%.3f, p=%.4f\n", b.done, total, name, r.Samples, r.FinalZ, r.pValue())
+	}
+	for _, n := range r.Notes {
+		fmt.Fprintf(b.progress, "  %s\n", n)
+	}
+	reportRowErrors(os.Stderr, r.Path, r.Bad, r.BadCount, 20)
+}
+
+// writeCombined writes one workbook with a summary sheet listing every input
+// and a z-score sheet and chart per successfully analysed file. Notes on
+// sheet splits and downsampling go to the summary and to progress. corr, if
+// non-nil, is tabled below the files on the summary sheet.
+func writeCombined(path string, results []fileResult, corr *correlationReport, progress io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName(f.GetSheetName(0), summarySheetName); err != nil {
+		return err
+	}
+
+	headers := []string{"file", "device", "bits", "interval_s", "samples", "final_z", "p_value", "cumulative_deviation", "chi_square", "chi_square_p", "skipped_rows", "error", "notes",
+		"ljung_box_p", "significant_lags", "spectrum_peaks", "strongest_period_samples", "significant_bit_lags"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		_ = f.SetCellStr(summarySheetName, cell, h)
+	}
+	linkStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "1265BE", Underline: "single"}})
+	if err != nil {
+		return err
+	}
+
+	used := map[string]bool{strings.ToLower(summarySheetName): true}
+	var finalZs []float64
+	for i := range results {
+		r := &results[i]
+		row := i + 2
+		cell := func(col string) string { return fmt.Sprintf("%s%d", col, row) }
+		base := filepath.Base(r.Path)
+		_ = f.SetCellStr(summarySheetName, cell("A"), base)
+		if info, err := naming.ParseBaseName(r.Path); err == nil {
+			_ = f.SetCellStr(summarySheetName, cell("B"), string(info.Device))
+			_ = f.SetCellInt(summarySheetName, cell("C"), info.Bits)
+			_ = f.SetCellInt(summarySheetName, cell("D"), info.IntervalSeconds)
+		}
+		if r.Err != nil {
+			_ = f.SetCellStr(summarySheetName, cell("L"), r.Err.Error())
+			continue
+		}
+		_ = f.SetCellInt(summarySheetName, cell("E"), r.Samples)
+		_ = f.SetCellFloat(summarySheetName, cell("F"), r.FinalZ, 6, 64)
+		_ = f.SetCellFloat(summarySheetName, cell("G"), r.pValue(), 6, 64)
+		_ = f.SetCellFloat(summarySheetName, cell("H"), r.FinalDeviation, 6, 64)
+		_ = f.SetCellFloat(summarySheetName, cell("I"), r.ChiSquare, 6, 64)
+		_ = f.SetCellFloat(summarySheetName, cell("J"), r.ChiSquareP, 6, 64)
+		_ = f.SetCellInt(summarySheetName, cell("K"), r.BadCount)
+		finalZs = append(finalZs, r.FinalZ)
+		writeSerialSummary(f, row, r.Analysis)
+
+		sheet := uniqueSheetName(used, strings.TrimSuffix(base, filepath.Ext(base)))
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+		notes, err := writeZscoreSheets(f, sheet, used, r.Analysis)
+		if err != nil {
+			return fmt.Errorf("%s: %w", base, err)
+		}
+		if len(notes) > 0 {
+			_ = f.SetCellStr(summarySheetName, cell("M"), strings.Join(notes, "; "))
+			for _, n := range notes {
+				fmt.Fprintf(progress, "%s: %s\n", base, n)
+			}
+		}
+		_ = f.SetCellHyperLink(summarySheetName, cell("A"), sheetRef(sheet)+"!A1", "Location")
+		_ = f.SetCellStyle(summarySheetName, cell("A"), cell("A"), linkStyle)
+		// The chart series is in the workbook now; let it go.
+		r.Analysis = nil
+	}
+	// Combine the files' final z-scores, each with equal weight.
+	if len(finalZs) > 1 {
+		row := len(results) + 3
+		z := stoufferZ(finalZs)
+		_ = f.SetCellStr(summarySheetName, fmt.Sprintf("A%d", row), "Combined (Stouffer Z)")
+		_ = f.SetCellFloat(summarySheetName, fmt.Sprintf("F%d", row), z, 6, 64)
+		_ = f.SetCellFloat(summarySheetName, fmt.Sprintf("G%d", row), stats.TwoSidedP(z), 6, 64)
+		_ = f.SetCellStr(summarySheetName, fmt.Sprintf("M%d", row), fmt.Sprintf("sum(final_z) / sqrt(%d) over the files analysed", len(finalZs)))
+	}
+	if corr != nil {
+		writeCorrelationSummary(f, summarySheetName, len(results)+5, corr)
+	}
+	_ = f.SetColWidth(summarySheetName, "A", "A", 40)
+	_ = f.SetColWidth(summarySheetName, "B", "K", 12)
+	_ = f.SetColWidth(summarySheetName, "L", "M", 50)
+	_ = f.SetColWidth(summarySheetName, "N", "R", 16)
+	return f.SaveAs(path)
+}
+
+// writeSerialSummary fills the serial dependence columns of a summary row.
+func writeSerialSummary(f *excelize.File, row int, a *analysis) {
+	cell := func(col string) string { return fmt.Sprintf("%s%d", col, row) }
+	if ac := a.Autocorrelation; ac != nil {
+		_ = f.SetCellFloat(summarySheetName, cell("N"), ac.LjungBoxP, 6, 64)
+		_ = f.SetCellStr(summarySheetName, cell("O"), joinInts(ac.Significant))
+	}
+	if s := a.Spectrum; s != nil {
+		_ = f.SetCellInt(summarySheetName, cell("P"), len(s.Peaks))
+		_ = f.SetCellFloat(summarySheetName, cell("Q"), s.Strongest.PeriodSamples, 6, 64)
+	}
+	if bac := a.BitAutocorrelation; bac != nil {
+		_ = f.SetCellStr(summarySheetName, cell("R"), joinInts(bac.Significant))
+	}
+}
+
+// writeCorrelationSummary tables corr on sheet from row on: one
+// row per pair of captures, then the network variance.
+func writeCorrelationSummary(f *excelize.File, sheet string, row int, corr *correlationReport) {
+	setRow := func(values ...interface{}) {
+		_ = f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &values)
+		row++
+	}
+	setRow(fmt.Sprintf("Correlation of simultaneous captures (samples aligned by timestamp, %d s interval)", corr.IntervalSeconds))
+	setRow("file_a", "file_b", "common_samples", "correlation", "correlation_z", "correlation_p", "bits_compared", "agreement_rate", "agreement_z", "agreement_p")
+	for _, p := range corr.Pairs {
+		values := []interface{}{p.A, p.B, p.Samples, p.Correlation, p.CorrelationZ, p.CorrelationP}
+		if p.Bits > 0 {
+			values = append(values, p.Bits, p.AgreementRate, p.AgreementZ, p.AgreementP)
+		}
+		setRow(values...)
+	}
+	row++
+	nv := corr.NetworkVariance
+	setRow("network_variance", "aligned_samples", "chi_square", "z", "p")
+	setRow("Stouffer Z² per aligned sample, summed", nv.Seconds, nv.ChiSquare, nv.Z, nv.P)
+	row++
+	setRow("file", "samples", "matched", "skipped")
+	for _, c := range corr.Captures {
+		setRow(c.File, c.Samples, c.Matched, c.Skipped)
+	}
+}
+
+// uniqueSheetName turns name into a valid sheet name not yet in used (which
+// holds lower-cased names, since Excel compares them case-insensitively) and
+// records it.
+func uniqueSheetName(used map[string]bool, name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	candidate := truncateRunes(name, maxSheetNameLen)
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		suffix := fmt.Sprintf("~%d", n)
+		candidate = truncateRunes(name, maxSheetNameLen-len(suffix)) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// truncateRunes returns s cut to at most n runes.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		r = r[:n]
+	}
+	return string(r)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckOutputs(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "20261001T130000_trng_s16_i1.bin")
+	csv := filepath.Join(dir, "20261001T130000_trng_s16_i1.csv")
+	tests := []struct {
+		name      string
+		paths     []string
+		formats   []string
+		combined  string
+		correlate bool
+		wantErr   bool
+	}{
+		{"bin and sidecar csv", []string{bin, csv}, []string{"xlsx", "html", "json"}, "", false, false},
+		{"same input twice", []string{csv, filepath.Join(dir, ".", filepath.Base(csv))}, []string{"xlsx"}, "", false, true},
+		{"output is an input", []string{csv, csv + ".xlsx"}, []string{"xlsx"}, "", false, true},
+		{"other format only", []string{csv, csv + ".xlsx"}, []string{"json"}, "", false, false},
+		{"combined", []string{bin, csv}, []string{"xlsx", "json"}, filepath.Join(dir, "out.xlsx"), false, false},
+		{"correlate", []string{bin, csv}, []string{"xlsx", "html"}, "", true, false},
+		{"correlate over input", []string{csv, filepath.Join(dir, "20261001T130000_correlation.json")}, []string{"json"}, "", true, true},
+		{"combined over input", []string{csv, filepath.Join(dir, "out.xlsx")}, []string{"xlsx"}, filepath.Join(dir, "out.xlsx"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &batch{formats: map[string]bool{}, combined: tt.combined, correlate: tt.correlate}
+			for _, f := range tt.formats {
+				b.formats[f] = true
+			}
+			err := b.checkOutputs(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOutputs(%q) = %v, want error %v", tt.paths, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutputPathKeepsExtension(t *testing.T) {
+	if a, b := outputPath("x.bin", "xlsx"), outputPath("x.csv", "xlsx"); a == b {
+		t.Errorf("outputPath gives %s for both x.bin and x.csv", a)
+	}
+}
+
+func TestCorrelationPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"earliest capture", []string{filepath.Join("data", "20250301T100500_trng_s2048_i1.csv"), filepath.Join("other", "20250301T100000_bitb_s2048_i1.bin")}, filepath.Join("data", "20250301T100000_correlation.json")},
+		{"no capture time", []string{filepath.Join("data", "a.csv"), filepath.Join("data", "b.csv")}, filepath.Join("data", "correlation.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := correlationPath(tt.paths, "json"); got != tt.want {
+				t.Errorf("correlationPath(%q) = %s, want %s", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
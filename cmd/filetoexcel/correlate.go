@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// correlationReport compares captures taken in parallel, matching their
+// samples by timestamp.
+type correlationReport struct {
+	IntervalSeconds int                `json:"interval_seconds"`
+	Captures        []correlationInput `json:"captures"`
+	Pairs           []pairReport       `json:"pairs"`
+	NetworkVariance netvarReport       `json:"network_variance"`
+}
+
+// correlationInput is one capture of a correlationReport.
+type correlationInput struct {
+	File string `json:"file"`
+	// Samples counts the samples read, Matched those sharing a timestamp
+	// with at least one other capture and Skipped those without a usable
+	// timestamp or repeating the previous one.
+	Samples int `json:"samples"`
+	Matched int `json:"matched"`
+	Skipped int `json:"skipped"`
+}
+
+// pairReport compares two captures over their common samples.
+type pairReport struct {
+	A       string `json:"a"`
+	B       string `json:"b"`
+	Samples int    `json:"samples"`
+	// Correlation is Pearson's r of the ones counts, with the two-sided
+	// p-value of its Fisher z-transform.
+	Correlation  float64 `json:"correlation"`
+	CorrelationZ float64 `json:"correlation_z"`
+	CorrelationP float64 `json:"correlation_p"`
+	// Bits, AgreementRate and AgreementZ are set for two .bin captures of
+	// the same sample size: the proportion of bits equal in both (XOR zero),
+	// 0.5 for independent devices.
+	Bits          int64   `json:"bits,omitempty"`
+	AgreementRate float64 `json:"agreement_rate,omitempty"`
+	AgreementZ    float64 `json:"agreement_z,omitempty"`
+	AgreementP    float64 `json:"agreement_p,omitempty"`
+}
+
+// netvarReport is the Global Consciousness Project's network variance: each
+// second the sample z-scores of the captures present are combined into a
+// Stouffer Z, and the Z² are summed into a chi-square with one degree of
+// freedom per second.
+type netvarReport struct {
+	// Seconds counts the aligned samples with at least two captures; the
+	// name follows the GCP, whose samples are one second apart.
+	Seconds   int     `json:"seconds"`
+	ChiSquare float64 `json:"chi_square"`
+	P         float64 `json:"p"`
+	// Z is the chi-square standardised, (χ² − df) / √(2·df).
+	Z float64 `json:"z"`
+	// Series is the cumulative deviation Σ(Z² − 1), every Step-th second
+	// plus the last.
+	Step   int           `json:"step"`
+	Series []netvarPoint `json:"series"`
+}
+
+// netvarPoint is one point of the network variance series.
+type netvarPoint struct {
+	Time                time.Time `json:"time"`
+	CumulativeDeviation float64   `json:"cumulative_deviation"`
+}
+
+// alignedSample is one timestamped sample of a capture.
+type alignedSample struct {
+	Time time.Time
+	Ones int
+	// Block is the sample of .bin input in MSB-first layout, valid until
+	// the next sample is read.
+	Block []byte
+}
+
+// capture is one input of correlate.
+type capture struct {
+	path     string
+	bits     int
+	interval int
+	bin      bool
+	align    bitpack.Alignment
+	loc      *time.Location
+	err      error
+}
+
+// openCapture reads what correlate needs to know about path.
+func openCapture(path string, loc *time.Location) (*capture, error) {
+	interval, err := findInterval(path)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := findBitCount(path)
+	if err != nil {
+		return nil, err
+	}
+	if bits <= 0 {
+		return nil, errors.New("invalid block size")
+	}
+	c := &capture{path: path, bits: bits, interval: interval, loc: loc}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".bin":
+		c.bin = true
+		if c.align, err = detectBinAlignment(path, bits); err != nil {
+			return nil, err
+		}
+		if c.align == bitpack.AlignUnknown {
+			c.align = bitpack.AlignMSB
+		}
+	case ".csv":
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(path))
+	}
+	return c, nil
+}
+
+// errStopScan ends a scan early.
+var errStopScan = errors.New("scan stopped")
+
+// samples returns c's samples in file order. Those of a .bin capture are
+// timed by its sidecar .csv, row by row, or without one by the start time
+// in its name and the interval. A sample whose timestamp cannot be parsed
+// has a zero Time. A read error ends the sequence and is left in c.err.
+func (c *capture) samples() iter.Seq[alignedSample] {
+	return func(yield func(alignedSample) bool) {
+		if !c.bin {
+			c.err = scanCSVFile(c.path, c.loc, func(r DataRow) error {
+				if !yield(alignedSample{Time: r.Time, Ones: r.Ones}) {
+					return errStopScan
+				}
+				return nil
+			}, func(RowError) {})
+		} else {
+			var times []time.Time
+			times, c.err = sidecarTimes(naming.SidecarPath(c.path, "csv"), c.loc)
+			if c.err != nil {
+				return
+			}
+			var start time.Time
+			if times == nil {
+				info, err := naming.ParseBaseName(c.path)
+				if err != nil {
+					c.err = fmt.Errorf("no timestamps: no sidecar .csv and %w", err)
+					return
+				}
+				start = info.Time
+			}
+			var block []byte
+			i := 0
+			c.err = scanBinFile(c.path, c.bits, c.align, func(b []byte) { block = b }, func(r DataRow) error {
+				if len(block) < bitpack.BytesFor(c.bits) {
+					return nil
+				}
+				s := alignedSample{Ones: r.Ones, Block: block}
+				switch {
+				case times == nil:
+					s.Time = start.Add(time.Duration(i*c.interval) * time.Second)
+				case i < len(times):
+					s.Time = times[i]
+				default:
+					// The capture outlived its timestamps.
+					return errStopScan
+				}
+				i++
+				if !yield(s) {
+					return errStopScan
+				}
+				return nil
+			})
+		}
+		if errors.Is(c.err, errStopScan) {
+			c.err = nil
+		}
+	}
+}
+
+// sidecarTimes reads the timestamps of a .bin capture's sidecar .csv, one
+// per sample, with a zero time for rows that cannot be parsed so the rest
+// stay aligned. A missing file yields nil.
+func sidecarTimes(path string, loc *time.Location) ([]time.Time, error) {
+	times := []time.Time{}
+	err := scanCSVFile(path, loc, func(r DataRow) error {
+		times = append(times, r.Time)
+		return nil
+	}, func(RowError) {
+		times = append(times, time.Time{})
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return times, err
+}
+
+// cursor walks one capture during the merge.
+type cursor struct {
+	*capture
+	in    correlationInput
+	next  func() (alignedSample, bool)
+	stop  func()
+	head  alignedSample
+	key   int64
+	ok    bool
+	moved bool
+}
+
+// advance moves to the next sample with a timestamp later than the
+// current one's, in units of interval seconds.
+func (c *cursor) advance(interval int) {
+	for {
+		s, ok := c.next()
+		if !ok {
+			c.ok = false
+			return
+		}
+		c.in.Samples++
+		if s.Time.IsZero() {
+			c.in.Skipped++
+			continue
+		}
+		key := int64(math.Round(float64(s.Time.Unix()) / float64(interval)))
+		if c.moved && key <= c.key {
+			c.in.Skipped++
+			continue
+		}
+		c.head, c.key, c.ok, c.moved = s, key, true, true
+		return
+	}
+}
+
+// pairStats accumulates the comparison of two captures.
+type pairStats struct {
+	n                        int
+	sumA, sumB, sumAA, sumBB float64
+	sumAB                    float64
+	bits, differ             int64
+	xor                      []byte
+	comparable               bool
+	sampleBits               int
+}
+
+// add adds one common sample, given the sample z-scores of both captures.
+func (p *pairStats) add(za, zb float64, a, b alignedSample) {
+	p.n++
+	p.sumA += za
+	p.sumB += zb
+	p.sumAA += za * za
+	p.sumBB += zb * zb
+	p.sumAB += za * zb
+	if p.comparable {
+		for i := range p.xor {
+			p.xor[i] = a.Block[i] ^ b.Block[i]
+		}
+		p.differ += int64(bitpack.OnesCount(p.xor, p.sampleBits))
+		p.bits += int64(p.sampleBits)
+	}
+}
+
+// report summarises p for captures a and b.
+func (p *pairStats) report(a, b string) pairReport {
+	r := pairReport{A: a, B: b, Samples: p.n}
+	n := float64(p.n)
+	if den := (p.sumAA - p.sumA*p.sumA/n) * (p.sumBB - p.sumB*p.sumB/n); p.n > 3 && den > 0 {
+		r.Correlation = (p.sumAB - p.sumA*p.sumB/n) / math.Sqrt(den)
+		r.CorrelationZ = math.Atanh(math.Max(-1+1e-15, math.Min(1-1e-15, r.Correlation))) * math.Sqrt(n-3)
+		r.CorrelationP = stats.TwoSidedP(r.CorrelationZ)
+	}
+	if p.bits > 0 {
+		r.Bits = p.bits
+		agree := float64(p.bits - p.differ)
+		r.AgreementRate = agree / float64(p.bits)
+		r.AgreementZ = (agree - 0.5*float64(p.bits)) / math.Sqrt(0.25*float64(p.bits))
+		r.AgreementP = stats.TwoSidedP(r.AgreementZ)
+	}
+	return r
+}
+
+// correlate aligns the captures at paths by timestamp and compares them
+// pairwise and as a network. The captures must share a sampling interval.
+// A .csv that is the sidecar of a .bin also in paths is left out, since the
+// .bin is timed by it and carries the bits.
+func correlate(paths []string, loc *time.Location) (*correlationReport, error) {
+	inputs := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		inputs[p] = true
+	}
+	var caps []*capture
+	for _, p := range paths {
+		if strings.EqualFold(filepath.Ext(p), ".csv") && inputs[naming.SidecarPath(p, "bin")] {
+			continue
+		}
+		c, err := openCapture(p, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+		if len(caps) > 0 && c.interval != caps[0].interval {
+			return nil, fmt.Errorf("%s: interval %d s differs from %d s of %s", filepath.Base(p), c.interval, caps[0].interval, filepath.Base(caps[0].path))
+		}
+		caps = append(caps, c)
+	}
+	if len(caps) < 2 {
+		return nil, errors.New("need at least two captures to correlate")
+	}
+	interval := max(caps[0].interval, 1)
+
+	cursors := make([]*cursor, len(caps))
+	for i, c := range caps {
+		cur := &cursor{capture: c, in: correlationInput{File: filepath.Base(c.path)}}
+		cur.next, cur.stop = iter.Pull(c.samples())
+		defer cur.stop()
+		cur.advance(interval)
+		cursors[i] = cur
+	}
+	pairs := make([][]*pairStats, len(caps))
+	for i := range caps {
+		pairs[i] = make([]*pairStats, len(caps))
+		for j := i + 1; j < len(caps); j++ {
+			p := &pairStats{}
+			if caps[i].bin && caps[j].bin && caps[i].bits == caps[j].bits {
+				p.comparable, p.sampleBits = true, caps[i].bits
+				p.xor = make([]byte, bitpack.BytesFor(p.sampleBits))
+			}
+			pairs[i][j] = p
+		}
+	}
+
+	nv := netvarReport{Step: 1}
+	var chi float64
+	var last netvarPoint
+	zs := make([]float64, len(caps))
+	var group []int
+	for {
+		group = group[:0]
+		var key int64
+		for i, c := range cursors {
+			switch {
+			case !c.ok:
+			case len(group) == 0 || c.key < key:
+				group, key = append(group[:0], i), c.key
+			case c.key == key:
+				group = append(group, i)
+			}
+		}
+		if len(group) == 0 {
+			break
+		}
+		if len(group) >= 2 {
+			sum := 0.0
+			for _, i := range group {
+				c := cursors[i]
+				c.in.Matched++
+				half := 0.5 * float64(c.bits)
+				zs[i] = (float64(c.head.Ones) - half) / math.Sqrt(0.5*half)
+				sum += zs[i]
+			}
+			for gi, i := range group {
+				for _, j := range group[gi+1:] {
+					pairs[i][j].add(zs[i], zs[j], cursors[i].head, cursors[j].head)
+				}
+			}
+			z := sum / math.Sqrt(float64(len(group)))
+			chi += z * z
+			last = netvarPoint{Time: cursors[group[0]].head.Time, CumulativeDeviation: chi - float64(nv.Seconds+1)}
+			if nv.Seconds%nv.Step == 0 {
+				nv.Series = append(nv.Series, last)
+				if len(nv.Series) > maxChartPoints {
+					nv.Series = halvePoints(nv.Series)
+					nv.Step *= 2
+				}
+			}
+			nv.Seconds++
+		}
+		for _, i := range group {
+			cursors[i].advance(interval)
+		}
+	}
+	for _, c := range cursors {
+		if c.err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(c.path), c.err)
+		}
+	}
+	if nv.Seconds == 0 {
+		return nil, errors.New("the captures have no timestamps in common")
+	}
+	// End the series on the final value.
+	if (nv.Seconds-1)%nv.Step != 0 {
+		nv.Series = append(nv.Series, last)
+	}
+	nv.ChiSquare = chi
+	nv.P = stats.ChiSquareP(chi, nv.Seconds)
+	nv.Z = (chi - float64(nv.Seconds)) / math.Sqrt(2*float64(nv.Seconds))
+
+	r := &correlationReport{IntervalSeconds: interval, NetworkVariance: nv}
+	for i, c := range cursors {
+		r.Captures = append(r.Captures, c.in)
+		for j := i + 1; j < len(cursors); j++ {
+			r.Pairs = append(r.Pairs, pairs[i][j].report(c.in.File, cursors[j].in.File))
+		}
+	}
+	return r, nil
+}
+
+// halvePoints keeps every other point, in place.
+func halvePoints(points []netvarPoint) []netvarPoint {
+	n := 0
+	for i := 0; i < len(points); i += 2 {
+		points[n] = points[i]
+		n++
+	}
+	return points[:n]
+}
+
+// correlationNotes summarises r in a few lines for the progress output.
+func correlationNotes(r *correlationReport) []string {
+	var notes []string
+	for _, p := range r.Pairs {
+		s := fmt.Sprintf("%s vs %s: %d common samples, r=%.4f (p=%.4f)", p.A, p.B, p.Samples, p.Correlation, p.CorrelationP)
+		if p.Bits > 0 {
+			s += fmt.Sprintf(", bit agreement %.6f (z=%.3f, p=%.4f)", p.AgreementRate, p.AgreementZ, p.AgreementP)
+		}
+		notes = append(notes, s)
+	}
+	nv := r.NetworkVariance
+	return append(notes, fmt.Sprintf("network variance over %d aligned samples: χ²=%.1f, z=%.3f, p=%.4f", nv.Seconds, nv.ChiSquare, nv.Z, nv.P))
+}
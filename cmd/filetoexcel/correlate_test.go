@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// writeFiles writes name → content into dir and returns the paths in order.
+func writeFiles(t *testing.T, dir string, files ...[2]string) []string {
+	t.Helper()
+	var paths []string
+	for _, f := range files {
+		path := filepath.Join(dir, f[0])
+		if err := os.WriteFile(path, []byte(f[1]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestCorrelate(t *testing.T) {
+	// 16-bit samples have z = (ones - 8) / 2. Over the common seconds 1-4
+	// a has z -1, 2, 0, 1 and b has -1, 1, 1, -1. a's repeated second is
+	// skipped.
+	paths := writeFiles(t, t.TempDir(),
+		[2]string{"20261001T130000_trng_s16_i1.csv", "20261001T13:00:00,10\n20261001T13:00:01,6\n20261001T13:00:02,12\n20261001T13:00:02,16\n20261001T13:00:03,8\n20261001T13:00:04,10\n"},
+		[2]string{"20261001T130000_bitb_s16_i1.csv", "20261001T13:00:01,6\n20261001T13:00:02,10\n20261001T13:00:03,10\n20261001T13:00:04,6\n20261001T13:00:05,12\n"},
+	)
+	r, err := correlate(paths, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInputs := []correlationInput{
+		{File: filepath.Base(paths[0]), Samples: 6, Matched: 4, Skipped: 1},
+		{File: filepath.Base(paths[1]), Samples: 5, Matched: 4, Skipped: 0},
+	}
+	for i, want := range wantInputs {
+		if r.Captures[i] != want {
+			t.Errorf("capture %d = %+v, want %+v", i, r.Captures[i], want)
+		}
+	}
+
+	// Deviations from the means 0.5 and 0: a -1.5, 1.5, -0.5, 0.5 and
+	// b -1, 1, 1, -1. r = 2 / sqrt(5 · 4).
+	if len(r.Pairs) != 1 {
+		t.Fatalf("%d pairs, want 1", len(r.Pairs))
+	}
+	p := r.Pairs[0]
+	wantR := 1 / math.Sqrt(5)
+	if p.Samples != 4 || !near(p.Correlation, wantR) || !near(p.CorrelationZ, math.Atanh(wantR)) || !near(p.CorrelationP, stats.TwoSidedP(math.Atanh(wantR))) {
+		t.Errorf("pair = %+v, want 4 samples, r %v, z %v", p, wantR, math.Atanh(wantR))
+	}
+	if p.Bits != 0 {
+		t.Errorf("bit agreement over %d bits of .csv captures", p.Bits)
+	}
+
+	// Stouffer Z² per second: 4/2, 9/2, 1/2 and 0, so chi-square 7 on 4
+	// df, with upper tail e^-3.5 · (1 + 3.5).
+	nv := r.NetworkVariance
+	if nv.Seconds != 4 || !near(nv.ChiSquare, 7) || !near(nv.P, 4.5*math.Exp(-3.5)) || !near(nv.Z, 3/math.Sqrt(8)) {
+		t.Errorf("network variance = %d s, chi-square %v, p %v, z %v; want 4, 7, %v, %v", nv.Seconds, nv.ChiSquare, nv.P, nv.Z, 4.5*math.Exp(-3.5), 3/math.Sqrt(8))
+	}
+	wantSeries := []float64{1, 4.5, 4, 3}
+	if len(nv.Series) != len(wantSeries) {
+		t.Fatalf("series has %d points, want %d", len(nv.Series), len(wantSeries))
+	}
+	start := time.Date(2026, 10, 1, 13, 0, 1, 0, time.UTC)
+	for i, pt := range nv.Series {
+		if !near(pt.CumulativeDeviation, wantSeries[i]) || !pt.Time.Equal(start.Add(time.Duration(i)*time.Second)) {
+			t.Errorf("point %d = %v at %v, want %v at %v", i, pt.CumulativeDeviation, pt.Time, wantSeries[i], start.Add(time.Duration(i)*time.Second))
+		}
+	}
+}
+
+func TestCorrelateBitAgreement(t *testing.T) {
+	// Without sidecars the samples are timed from the names, one a second.
+	// The captures differ only in the last byte, 0xaa against 0x55: 24 of
+	// 32 bits agree.
+	paths := writeFiles(t, t.TempDir(),
+		[2]string{"20261001T130000_trng_s8_i1.bin", "\xff\x00\xf0\xaa"},
+		[2]string{"20261001T130000_bitb_s8_i1.bin", "\xff\x00\xf0\x55"},
+	)
+	r, err := correlate(paths, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := r.Pairs[0]
+	if p.Samples != 4 || p.Bits != 32 || !near(p.AgreementRate, 0.75) || !near(p.AgreementZ, 8/math.Sqrt(8)) {
+		t.Errorf("pair = %+v, want 4 samples, 24 of 32 bits agreeing, z %v", p, 8/math.Sqrt(8))
+	}
+	// Both have 8, 0, 4 and 4 ones.
+	if !near(p.Correlation, 1) {
+		t.Errorf("correlation = %v, want 1", p.Correlation)
+	}
+}
+
+func TestCorrelateSidecarTimes(t *testing.T) {
+	// a's sidecar cannot time its second sample, which is skipped; the
+	// other three meet b's samples, timed from its name. 16 of their 24
+	// bits agree.
+	paths := writeFiles(t, t.TempDir(),
+		[2]string{"20261001T130000_trng_s8_i1.bin", "\xff\x00\xf0\xaa"},
+		[2]string{"20261001T130000_bitb_s8_i1.bin", "\xff\xff\xf0\x55"},
+		[2]string{"20261001T130000_trng_s8_i1.csv", "20261001T13:00:00,8\nnot a time,0\n20261001T13:00:02,4\n20261001T13:00:03,4\n"},
+	)
+	r, err := correlate(paths, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Captures) != 2 {
+		t.Fatalf("%d captures, want the sidecar left out", len(r.Captures))
+	}
+	if in := r.Captures[0]; in.Samples != 4 || in.Matched != 3 || in.Skipped != 1 {
+		t.Errorf("capture a = %+v, want 4 samples, 3 matched, 1 skipped", in)
+	}
+	if p := r.Pairs[0]; p.Samples != 3 || p.Bits != 24 || !near(p.AgreementRate, 16.0/24) {
+		t.Errorf("pair = %+v, want 3 samples, 16 of 24 bits agreeing", p)
+	}
+}
+
+func TestCorrelateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   [][2]string
+		wantErr string
+	}{
+		{"one capture", [][2]string{{"20261001T130000_trng_s16_i1.csv", "20261001T13:00:00,8\n"}}, "at least two"},
+		{"intervals differ", [][2]string{
+			{"20261001T130000_trng_s16_i1.csv", "20261001T13:00:00,8\n"},
+			{"20261001T130000_bitb_s16_i2.csv", "20261001T13:00:00,8\n"},
+		}, "interval"},
+		{"no common time", [][2]string{
+			{"20261001T130000_trng_s16_i1.csv", "20261001T13:00:00,8\n"},
+			{"20261001T130000_bitb_s16_i1.csv", "20261001T13:00:01,8\n"},
+		}, "no timestamps in common"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := correlate(writeFiles(t, t.TempDir(), tt.files...), time.UTC)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("correlate: err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	errorSheetName = "Errors"
+	timeCellFormat = "yyyy-mm-dd hh:mm:ss"
+	// maxDataRows is the number of data rows that fit on a sheet below the
+	// header row.
+	maxDataRows = excelize.TotalRows - 1
+)
+
+// writeToExcel writes a's rows to the Excel file fileToSave with a chart of
+// the z-score. Skipped rows are listed on an "Errors" sheet. It returns
+// notes on any sheet split or chart downsampling.
+func writeToExcel(a *analysis, fileToSave string) ([]string, error) {
+	if a.Samples == 0 {
+		return nil, errors.New("no data to write")
+	}
+	f := excelize.NewFile()
+	defer f.Close()
+
+	// Ensure we have a clean sheet named Zscore
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{strings.ToLower(sheetName): true, strings.ToLower(errorSheetName): true}
+	notes, err := writeZscoreSheets(f, sheetName, used, a)
+	if err != nil {
+		return nil, err
+	}
+	if a.BadCount > 0 {
+		if err := writeErrorSheet(f, a.Bad, a.BadCount); err != nil {
+			return nil, err
+		}
+	}
+	return notes, f.SaveAs(fileToSave)
+}
+
+// writeZscoreSheets writes a's rows to the existing sheet with charts of the
+// z-score and the cumulative deviation, each with the ±1.96 and ±2.58
+// significance envelopes. The first column header depends on input type:
+// either "samples" or "time". Timestamped rows are written as date-time cells
+// and charted against a real time axis; otherwise against sample numbers.
+//
+// Rows are streamed from the input rather than held in memory. Rows beyond
+// Excel's row limit continue on further sheets named after the first, and a
+// capture with more than maxChartPoints samples is charted from a decimated
+// copy on a separate sheet. used holds the lower-cased sheet names already
+// taken and is updated. The returned notes describe what was done.
+func writeZscoreSheets(f *excelize.File, sheet string, used map[string]bool, a *analysis) ([]string, error) {
+	if a.Samples == 0 {
+		return nil, errors.New("no data to write")
+	}
+	timeAxis := a.TimeAxis()
+	var timeStyle int
+	if timeAxis {
+		var err error
+		timeStyle, err = f.NewStyle(&excelize.Style{CustomNumFmt: stringPtr(timeCellFormat)})
+		if err != nil {
+			return nil, err
+		}
+	}
+	xCell := func(r DataRow) interface{} {
+		if timeAxis {
+			return excelize.Cell{StyleID: timeStyle, Value: r.Time}
+		}
+		return r.Index
+	}
+
+	parts := []string{sheet}
+	for n := maxDataRows; n < a.Samples; n += maxDataRows {
+		name := uniqueSheetName(used, sheet)
+		if _, err := f.NewSheet(name); err != nil {
+			return nil, err
+		}
+		parts = append(parts, name)
+	}
+	var notes []string
+	if len(parts) > 1 {
+		notes = append(notes, fmt.Sprintf("%d samples exceed Excel's %d-row sheet limit; data split across sheets %s",
+			a.Samples, excelize.TotalRows, strings.Join(parts, ", ")))
+	}
+
+	// The charts go on before the data is streamed: excelize keeps drawings
+	// added to a sheet ahead of its stream writer.
+	src := chartSource{sheet: sheet, rows: a.Samples, x: "A", z: "D", deviation: "E"}
+	if len(parts) > 1 || a.Samples > maxChartPoints {
+		src = chartSource{sheet: uniqueSheetName(used, sheet+" chart"), rows: len(a.Chart), x: "A", z: "B", deviation: "C"}
+		if _, err := f.NewSheet(src.sheet); err != nil {
+			return nil, err
+		}
+		if err := writeChartData(f, src.sheet, a, xCell); err != nil {
+			return nil, err
+		}
+		notes = append(notes, fmt.Sprintf("chart downsampled to every %d sample(s) (%d points, sheet %s)",
+			a.ChartStep, len(a.Chart), src.sheet))
+	}
+	side := newSideTable(a)
+	if err := f.AddChart(sheet, "R2", zscoreChart(a, src, sheet, side)); err != nil {
+		return nil, err
+	}
+	if err := f.AddChart(sheet, "R22", deviationChart(a, src, sheet, side)); err != nil {
+		return nil, err
+	}
+	if len(a.Events) > 0 {
+		if err := writeEventsSheet(f, uniqueSheetName(used, sheet+" events"), a); err != nil {
+			return nil, err
+		}
+	}
+	if a.Autocorrelation != nil || a.Spectrum != nil || a.BitAutocorrelation != nil {
+		if err := writeSerialSheet(f, uniqueSheetName(used, sheet+" serial"), a); err != nil {
+			return nil, err
+		}
+	}
+
+	var sw *excelize.StreamWriter
+	part, rowIdx := -1, maxDataRows+1
+	err := a.scan(func(r DataRow) error {
+		if rowIdx > maxDataRows {
+			if sw != nil {
+				if err := sw.Flush(); err != nil {
+					return err
+				}
+			}
+			part++
+			var err error
+			if sw, err = startDataSheet(f, parts[part], a.Header, timeAxis); err != nil {
+				return err
+			}
+			rowIdx = 1
+		}
+		rowIdx++
+		cell, _ := excelize.CoordinatesToCellName(1, rowIdx)
+		row := []interface{}{xCell(r), r.Ones, r.CumulativeMean, r.ZScore, r.CumulativeDeviation, r.SampleZ, r.ChiSquare}
+		if part == 0 && rowIdx-2 < side.rows() {
+			row = append(row, nil)
+			row = append(row, side.row(rowIdx-2, xCell)...)
+		}
+		return sw.SetRow(cell, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A short capture may have fewer rows than the helper table.
+	if part == 0 {
+		for i := rowIdx - 1; i < side.rows(); i++ {
+			cell, _ := excelize.CoordinatesToCellName(sideColumn, i+2)
+			if err := sw.SetRow(cell, side.row(i, xCell)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if sw != nil {
+		if err := sw.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+// startDataSheet opens a stream writer on sheet and writes the header row.
+func startDataSheet(f *excelize.File, sheet, firstColumnHeader string, timeAxis bool) (*excelize.StreamWriter, error) {
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+	if timeAxis {
+		if err := sw.SetColWidth(1, 1, 20); err != nil {
+			return nil, err
+		}
+		if err := sw.SetColWidth(sideColumn, sideColumn, 20); err != nil {
+			return nil, err
+		}
+		if err := sw.SetColWidth(eventColumn(), eventColumn(), 20); err != nil {
+			return nil, err
+		}
+	}
+	header := []interface{}{firstColumnHeader, onesColumnName, "cumulative_mean", "z_test", "cumulative_deviation", "sample_z", "chi_square", nil}
+	return sw, sw.SetRow("A1", append(header, sideHeader()...))
+}
+
+// writeChartData writes a's decimated chart series (sample number or time,
+// z-score, cumulative deviation) to sheet.
+func writeChartData(f *excelize.File, sheet string, a *analysis, xCell func(DataRow) interface{}) error {
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	if a.TimeAxis() {
+		if err := sw.SetColWidth(1, 1, 20); err != nil {
+			return err
+		}
+	}
+	if err := sw.SetRow("A1", []interface{}{a.Header, "z_test", "cumulative_deviation"}); err != nil {
+		return err
+	}
+	for i, r := range a.Chart {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := sw.SetRow(cell, []interface{}{xCell(r), r.ZScore, r.CumulativeDeviation}); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// sideColumn is the column number of the chart helper table on the first
+// data sheet, which starts at column I (after a blank column H).
+const sideColumn = 9
+
+// eventLinePoints is the number of markers drawn for each event line.
+const eventLinePoints = 25
+
+// sideTable holds the chart helper columns written beside the data: the
+// significance envelopes, then the event lines. Excelize draws scatter
+// series as markers only, so each is a set of points:
+//
+//   - Each envelope is one series covering both bounds, so the envelope
+//     samples are listed twice: first with the upper bounds, then with the
+//     lower ones.
+//   - Each event is a vertical line of eventLinePoints markers spanning the
+//     chart's y range.
+type sideTable struct {
+	env []DataRow
+	// sampleSD is the expected standard deviation of one sample's ones count.
+	sampleSD float64
+	events   []events.Event
+	// zRange and devRange are the half-heights of the event lines.
+	zRange, devRange float64
+}
+
+// maxEnvelopePoints is the number of samples the envelopes are drawn at.
+const maxEnvelopePoints = 150
+
+// newSideTable builds the helper table for a, picking the envelope samples
+// evenly from its chart series, including the last.
+func newSideTable(a *analysis) sideTable {
+	n := min(len(a.Chart), maxEnvelopePoints)
+	picked := make([]DataRow, 0, n)
+	for i := 0; i < n; i++ {
+		j := 0
+		if n > 1 {
+			j = i * (len(a.Chart) - 1) / (n - 1)
+		}
+		picked = append(picked, a.Chart[j])
+	}
+	t := sideTable{
+		env:      append(picked, picked...),
+		sampleSD: math.Sqrt(float64(a.BlockSize) * 0.25),
+		events:   a.Events,
+	}
+	k := envelopeZ[len(envelopeZ)-1]
+	t.zRange = 1.05 * math.Max(a.MaxAbsZ, k)
+	t.devRange = 1.05 * math.Max(a.MaxAbsDeviation, k*math.Sqrt(float64(a.Samples))*t.sampleSD)
+	return t
+}
+
+// eventColumn is the column number of the event lines' x values.
+func eventColumn() int { return sideColumn + 1 + 2*len(envelopeZ) }
+
+// sideHeader names the helper table columns: the envelopes' x value, the z
+// and cumulative deviation bounds for each level of envelopeZ, then the
+// event lines' x, z and deviation values.
+func sideHeader() []interface{} {
+	header := []interface{}{"envelope_x"}
+	for _, name := range []string{"z", "deviation"} {
+		for _, k := range envelopeZ {
+			header = append(header, fmt.Sprintf("%s_±%.2f", name, k))
+		}
+	}
+	return append(header, "event_x", "event_z", "event_deviation")
+}
+
+// rows returns the number of rows in the table.
+func (t sideTable) rows() int {
+	return max(len(t.env), t.eventRows())
+}
+
+// eventRows returns the number of rows holding event line points.
+func (t sideTable) eventRows() int {
+	return len(t.events) * eventLinePoints
+}
+
+// row returns row i of the table, starting at column sideColumn.
+func (t sideTable) row(i int, xCell func(DataRow) interface{}) []interface{} {
+	row := make([]interface{}, 0, eventColumn()-sideColumn+3)
+	if i < len(t.env) {
+		r := t.env[i]
+		sign := 1.0
+		if i >= len(t.env)/2 {
+			sign = -1
+		}
+		// The cumulative deviation after n samples has sd sqrt(n * N/4).
+		devSD := math.Sqrt(float64(r.Index)) * t.sampleSD
+		row = append(row, xCell(r))
+		for _, k := range envelopeZ {
+			row = append(row, sign*k)
+		}
+		for _, k := range envelopeZ {
+			row = append(row, sign*k*devSD)
+		}
+	} else {
+		row = row[:eventColumn()-sideColumn]
+	}
+	if i < t.eventRows() {
+		e := t.events[i/eventLinePoints]
+		frac := -1 + 2*float64(i%eventLinePoints)/(eventLinePoints-1)
+		row = append(row, xCell(DataRow{Index: e.Sample, Time: e.Time}), frac*t.zRange, frac*t.devRange)
+	}
+	return row
+}
+
+// chartSource locates the series a chart is drawn from: rows 2..rows+1 of
+// the x, z and deviation columns of sheet.
+type chartSource struct {
+	sheet           string
+	rows            int
+	x, z, deviation string
+}
+
+// column returns the absolute reference to col over the source rows.
+func (s chartSource) column(col string) string {
+	return fmt.Sprintf("%s!$%s$2:$%s$%d", sheetRef(s.sheet), col, col, s.rows+1)
+}
+
+// zscoreChart builds the z-score chart from src, with envelopes and event
+// lines from the helper table side on sideSheet.
+func zscoreChart(a *analysis, src chartSource, sideSheet string, side sideTable) *excelize.Chart {
+	chart := envelopeChart(a, src, src.z, sideSheet, side, 0)
+	chart.Title = []excelize.RichTextRun{{Text: filepath.Base(a.Path)}}
+	chart.YAxis.Title = []excelize.RichTextRun{{Text: fmt.Sprintf("Z-score - Sample Size =  %d bits)", a.BlockSize)}}
+	return chart
+}
+
+// deviationChart builds the cumulative deviation chart from src, with
+// envelopes and event lines from the helper table side on sideSheet.
+func deviationChart(a *analysis, src chartSource, sideSheet string, side sideTable) *excelize.Chart {
+	chart := envelopeChart(a, src, src.deviation, sideSheet, side, 1)
+	chart.Title = []excelize.RichTextRun{{Text: filepath.Base(a.Path) + " - cumulative deviation"}}
+	chart.YAxis.Title = []excelize.RichTextRun{{Text: fmt.Sprintf("Σ(ones − %d/2)", a.BlockSize)}}
+	return chart
+}
+
+// envelopeChart builds a scatter chart of column valCol of src together with
+// one series per level of envelopeZ and one for the event lines, read from
+// the helper table on sideSheet; which is 0 for the z columns and 1 for the
+// deviation ones. The data is drawn as small circles, the envelopes as
+// short dashes and the event lines as dotted verticals.
+func envelopeChart(a *analysis, src chartSource, valCol, sideSheet string, side sideTable, which int) *excelize.Chart {
+	chart := &excelize.Chart{
+		Type: excelize.Scatter,
+		Series: []excelize.ChartSeries{{
+			Name:       fmt.Sprintf("%s!$%s$1", sheetRef(src.sheet), valCol),
+			Categories: src.column(src.x),
+			Values:     src.column(valCol),
+			Marker:     excelize.ChartMarker{Symbol: "circle", Size: 2},
+		}},
+		Legend: excelize.ChartLegend{Position: "bottom"},
+		XAxis:  excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: fmt.Sprintf("Number of Samples - one sample every %d second(s)", a.Interval)}}},
+		YAxis:  excelize.ChartAxis{MajorGridLines: true},
+	}
+	addSeries := func(xCol, valCol, rows int, marker excelize.ChartMarker) {
+		src := chartSource{sheet: sideSheet, rows: rows}
+		x, _ := excelize.ColumnNumberToName(xCol)
+		col, _ := excelize.ColumnNumberToName(valCol)
+		chart.Series = append(chart.Series, excelize.ChartSeries{
+			Name:       fmt.Sprintf("%s!$%s$1", sheetRef(sideSheet), col),
+			Categories: src.column(x),
+			Values:     src.column(col),
+			Marker:     marker,
+		})
+	}
+	for i := range envelopeZ {
+		addSeries(sideColumn, sideColumn+1+which*len(envelopeZ)+i, len(side.env), excelize.ChartMarker{
+			Symbol: "dash",
+			Size:   4,
+			Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{envelopeColors[i]}},
+		})
+	}
+	if len(side.events) > 0 {
+		addSeries(eventColumn(), eventColumn()+1+which, side.eventRows(), excelize.ChartMarker{
+			Symbol: "circle",
+			Size:   2,
+			Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{eventColor}},
+		})
+	}
+	if a.TimeAxis() {
+		minX, maxX := excelSerial(a.First), excelSerial(a.Last)
+		chart.XAxis = excelize.ChartAxis{
+			Title:   []excelize.RichTextRun{{Text: fmt.Sprintf("Time (%s) - one sample every %d second(s)", a.First.Format("MST"), a.Interval)}},
+			NumFmt:  excelize.ChartNumFmt{CustomNumFmt: timeAxisFormat(a.Last.Sub(a.First))},
+			Minimum: &minX,
+			Maximum: &maxX,
+		}
+	} else {
+		minX, maxX := 1.0, float64(a.Samples)
+		chart.XAxis.Minimum, chart.XAxis.Maximum = &minX, &maxX
+	}
+	return chart
+}
+
+// writeEventsSheet lists a's events and the segments they delimit on a new
+// sheet.
+func writeEventsSheet(f *excelize.File, sheet string, a *analysis) error {
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+	timeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: stringPtr(timeCellFormat)})
+	if err != nil {
+		return err
+	}
+	setCells := func(row, col int, values ...interface{}) {
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		_ = f.SetSheetRow(sheet, cell, &values)
+	}
+	setTime := func(row, col int, t time.Time) {
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		_ = f.SetCellValue(sheet, cell, t)
+		_ = f.SetCellStyle(sheet, cell, cell, timeStyle)
+	}
+	setCells(1, 1, "timestamp", "sample", "kind", "label")
+	for i, e := range a.Events {
+		setTime(i+2, 1, e.Time)
+		setCells(i+2, 2, e.Sample, string(e.Kind), e.Label)
+	}
+	if segs := events.Segments(a.Events); len(segs) > 0 {
+		row := len(a.Events) + 4
+		setCells(row, 1, "segment", "start", "stop", "start_sample", "stop_sample", "samples", "duration_s")
+		for _, s := range segs {
+			row++
+			setCells(row, 1, s.Label)
+			setTime(row, 2, s.Start.Time)
+			if s.Open {
+				setCells(row, 3, "(not stopped)", s.Start.Sample)
+				continue
+			}
+			setTime(row, 3, s.Stop.Time)
+			setCells(row, 4, s.Start.Sample, s.Stop.Sample, s.Stop.Sample-s.Start.Sample, s.Stop.Time.Sub(s.Start.Time).Seconds())
+		}
+	}
+	_ = f.SetColWidth(sheet, "A", "C", 20)
+	return nil
+}
+
+// writeSerialSheet lists a's autocorrelations and periodogram on a new
+// sheet, side by side: the ones-count autocorrelation in A:D, the bit
+// autocorrelation in F:I and the periodogram bins in K:P, each below a
+// short summary.
+func writeSerialSheet(f *excelize.File, sheet string, a *analysis) error {
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+	setCells := func(row, col int, values ...interface{}) {
+		cell, _ := excelize.CoordinatesToCellName(col, row)
+		_ = f.SetSheetRow(sheet, cell, &values)
+	}
+	flag := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return ""
+	}
+	lagTable := func(col int, title string, threshold float64, values []lagValue) {
+		setCells(1, col, title)
+		setCells(2, col, "threshold", threshold)
+		setCells(5, col, "lag", "r", "z", "significant")
+		for i, v := range values {
+			setCells(i+6, col, v.Lag, v.R, v.Z, flag(v.Significant))
+		}
+	}
+	if ac := a.Autocorrelation; ac != nil {
+		lagTable(1, "autocorrelation of ones", ac.Threshold, ac.Values)
+		setCells(3, 1, "ljung_box", ac.LjungBox, "df", ac.LjungBoxDF)
+		setCells(4, 1, "ljung_box_p", ac.LjungBoxP)
+	}
+	if bac := a.BitAutocorrelation; bac != nil {
+		lagTable(6, "bit autocorrelation (threshold is |z|)", bac.Threshold, bac.Values)
+		setCells(3, 6, "bits", bac.Bits)
+	}
+	if s := a.Spectrum; s != nil {
+		setCells(1, 11, "periodogram of ones")
+		setCells(2, 11, "threshold", s.Threshold)
+		setCells(3, 11, "segment_length", s.SegmentLength, "segments", s.Segments)
+		setCells(4, 11, "peaks", len(s.Peaks))
+		setCells(5, 11, "frequency", "period_samples", "period_s", "power", "p", "significant")
+		for i, b := range s.Bins {
+			var seconds interface{}
+			if b.PeriodSeconds > 0 {
+				seconds = b.PeriodSeconds
+			}
+			setCells(i+6, 11, b.Frequency, b.PeriodSamples, seconds, b.Power, b.P, flag(b.Significant))
+		}
+	}
+	_ = f.SetColWidth(sheet, "A", "P", 13)
+	return nil
+}
+
+// timeAxisFormat picks a chart tick label format suited to the time span.
+func timeAxisFormat(span time.Duration) string {
+	if span >= 24*time.Hour {
+		return "yyyy-mm-dd hh:mm"
+	}
+	return "hh:mm:ss"
+}
+
+// excelSerial converts t's wall-clock time to an Excel date serial number,
+// matching how date-time cells are stored.
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// writeErrorSheet lists skipped input rows; total is the number skipped,
+// which may exceed len(bad).
+func writeErrorSheet(f *excelize.File, bad []RowError, total int) error {
+	if _, err := f.NewSheet(errorSheetName); err != nil {
+		return err
+	}
+	_ = f.SetCellStr(errorSheetName, "A1", "line")
+	_ = f.SetCellStr(errorSheetName, "B1", "row")
+	_ = f.SetCellStr(errorSheetName, "C1", "error")
+	for i, e := range bad {
+		rowIdx := i + 2
+		_ = f.SetCellInt(errorSheetName, fmt.Sprintf("A%d", rowIdx), e.Line)
+		_ = f.SetCellStr(errorSheetName, fmt.Sprintf("B%d", rowIdx), e.Text)
+		_ = f.SetCellStr(errorSheetName, fmt.Sprintf("C%d", rowIdx), e.Err.Error())
+	}
+	if total > len(bad) {
+		_ = f.SetCellStr(errorSheetName, fmt.Sprintf("B%d", len(bad)+2), fmt.Sprintf("... and %d more", total-len(bad)))
+	}
+	return nil
+}
+
+func stringPtr(s string) *string { return &s }
+
+// sheetRef quotes a sheet name for use in a cell reference.
+func sheetRef(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
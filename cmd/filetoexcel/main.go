@@ -1,21 +1,17 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"math"
-	"math/bits"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/xuri/excelize/v2"
 )
 
 const (
@@ -25,13 +21,36 @@ const (
 	timeColumnName  = "time"
 )
 
-// DataRow represents a single input row with category label and ones count,
-// plus computed cumulative mean and z-score.
+// DataRow represents a single input row with its ones count, plus computed
+// cumulative statistics.
 type DataRow struct {
-	Category       string
+	// Index is the 1-based sample number among valid rows.
+	Index int
+	// Time is the sample timestamp for .csv input; zero for .bin input.
+	Time           time.Time
 	Ones           int
 	CumulativeMean float64
 	ZScore         float64
+	// CumulativeDeviation is the running sum of (ones - block_size/2).
+	CumulativeDeviation float64
+	// SampleZ is this sample's own z-score, (ones - block_size/2) / sqrt(block_size/4).
+	SampleZ float64
+	// ChiSquare is the running sum of SampleZ², chi-square distributed with
+	// Index degrees of freedom under the null hypothesis.
+	ChiSquare float64
+}
+
+// RowError describes an input row that could not be parsed.
+type RowError struct {
+	// Line is the 1-based line number in the input file.
+	Line int
+	// Text is the raw row content.
+	Text string
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
 }
 
 // findInterval extracts the sampling interval in seconds from the file path.
@@ -64,218 +83,77 @@ func findBitCount(filePath string) (int, error) {
 	return val, nil
 }
 
-// readBinFile reads a .bin file and returns rows of (block number label, ones count).
-// The block size is specified in bits; the function reads blockSize/8 bytes per block.
-func readBinFile(filePath string, blockSize int) ([]DataRow, error) {
-	if blockSize%8 != 0 {
-		return nil, errors.New("block size must be a multiple of 8 bits for .bin files")
-	}
-	bytesPerBlock := blockSize / 8
-	if bytesPerBlock <= 0 {
-		return nil, errors.New("invalid block size")
-	}
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	reader := bufio.NewReader(f)
-	rows := make([]DataRow, 0, 1024)
-	buf := make([]byte, bytesPerBlock)
-	block := 1
-	for {
-		n, err := reader.Read(buf)
-		if n == 0 {
-			break
-		}
-		// Allow partial block at EOF; error only if it's not EOF
-		if err != nil && !errors.Is(err, io.EOF) {
-			return nil, err
-		}
-		// Count ones in the read bytes (truncate if partial read)
-		count := 0
-		for i := 0; i < n; i++ {
-			count += bits.OnesCount8(buf[i])
-		}
-		rows = append(rows, DataRow{Category: strconv.Itoa(block), Ones: count})
-		block++
-		if n < bytesPerBlock {
-			break
-		}
-	}
-	return rows, nil
-}
-
-// readCSVFile reads a .csv file with two columns: timestamp and ones count.
-// It returns rows using the timestamp formatted as HH:MM:SS for the category label.
-func readCSVFile(filePath string) ([]DataRow, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	r := csv.NewReader(f)
-	r.FieldsPerRecord = -1
-	// Expect no header; Python version used header=None
-	records, err := r.ReadAll()
-	if err != nil {
-		return nil, err
-	}
-	rows := make([]DataRow, 0, len(records))
-	for _, rec := range records {
-		if len(rec) < 2 {
-			continue
-		}
-		label := formatTimeLabel(strings.TrimSpace(rec[0]))
-		onesStr := strings.TrimSpace(rec[1])
-		ones, err := strconv.Atoi(onesStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid ones value '%s': %w", onesStr, err)
+// parseFormats parses the -format list.
+func parseFormats(list string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		switch f {
+		case "xlsx", "html", "json":
+			set[f] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown format %q (allowed: xlsx, html, json)", f)
 		}
-		rows = append(rows, DataRow{Category: label, Ones: ones})
 	}
-	return rows, nil
-}
-
-// formatTimeLabel attempts to parse various timestamp formats and returns HH:MM:SS.
-// If parsing fails, it returns the original string.
-func formatTimeLabel(s string) string {
-	formats := []string{
-		// Common formats that pandas may accept
-		time.RFC3339,
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-		"15:04:05",
-		"15:04",
-	}
-	for _, layout := range formats {
-		if t, err := time.Parse(layout, s); err == nil {
-			return t.Format("15:04:05")
-		}
+	if len(set) == 0 {
+		return nil, errors.New("no format given")
 	}
-	return s
+	return set, nil
 }
 
-// calculateZTest computes cumulative mean of ones and the z-score per row.
-// expected_mean = 0.5 * block_size
-// expected_std_dev = sqrt(block_size * 0.25)
-// z_i = (cum_mean_i - expected_mean) / (expected_std_dev / sqrt(i+1))
-func calculateZTest(rows []DataRow, blockSize int) []DataRow {
-	expectedMean := 0.5 * float64(blockSize)
-	expectedStdDev := math.Sqrt(float64(blockSize) * 0.25)
-	if expectedStdDev == 0 {
-		return rows
-	}
-	sum := 0
-	for i := range rows {
-		sum += rows[i].Ones
-		cumMean := float64(sum) / float64(i+1)
-		z := (cumMean - expectedMean) / (expectedStdDev / math.Sqrt(float64(i+1)))
-		rows[i].CumulativeMean = cumMean
-		rows[i].ZScore = z
-	}
-	return rows
-}
-
-// writeToExcel writes the rows to an Excel file with a line chart of the z-score.
-// The first column header depends on input type: either "samples" or "time".
-// The file is written next to the input path with a .xlsx extension.
-func writeToExcel(rows []DataRow, filePath string, blockSize int, intervalSec int, firstColumnHeader string) error {
-	if len(rows) == 0 {
-		return errors.New("no data to write")
-	}
-	fileToSave := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".xlsx"
-	f := excelize.NewFile()
-	defer f.Close()
-
-	// Ensure we have a clean sheet named Zscore
-	defaultSheet := f.GetSheetName(0)
-	if defaultSheet != sheetName {
-		f.NewSheet(sheetName)
-		f.DeleteSheet(defaultSheet)
-	}
-
-	// Headers
-	_ = f.SetCellStr(sheetName, "A1", firstColumnHeader)
-	_ = f.SetCellStr(sheetName, "B1", onesColumnName)
-	_ = f.SetCellStr(sheetName, "C1", "cumulative_mean")
-	_ = f.SetCellStr(sheetName, "D1", "z_test")
-
-	// Data rows
-	for i, r := range rows {
-		rowIdx := i + 2
-		_ = f.SetCellStr(sheetName, fmt.Sprintf("A%d", rowIdx), r.Category)
-		_ = f.SetCellInt(sheetName, fmt.Sprintf("B%d", rowIdx), r.Ones)
-		_ = f.SetCellFloat(sheetName, fmt.Sprintf("C%d", rowIdx), r.CumulativeMean, 6, 64)
-		_ = f.SetCellFloat(sheetName, fmt.Sprintf("D%d", rowIdx), r.ZScore, 6, 64)
-	}
-
-	// Build chart using struct API
-	endRow := len(rows) + 1
-	catRange := fmt.Sprintf("%s!$A$2:$A$%d", sheetName, endRow)
-	valRange := fmt.Sprintf("%s!$D$2:$D$%d", sheetName, endRow)
-	chart := &excelize.Chart{
-		Type: excelize.Line,
-		Series: []excelize.ChartSeries{
-			{
-				Name:       fmt.Sprintf("%s!$D$1", sheetName),
-				Categories: catRange,
-				Values:     valRange,
-			},
-		},
-		Title:  []excelize.RichTextRun{{Text: filepath.Base(filePath)}},
-		Legend: excelize.ChartLegend{Position: "none"},
-		XAxis:  excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: fmt.Sprintf("Number of Samples - one sample every %d second(s)", intervalSec)}}},
-		YAxis:  excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: fmt.Sprintf("Z-score - Sample Size =  %d bits)", blockSize)}}, MajorGridLines: true},
-	}
-	if err := f.AddChart(sheetName, "F2", chart); err != nil {
-		return err
+// main is the entry-point CLI that mirrors file_to_excel.py behavior.
+// Usage: filetoexcel [flags] <file|dir|glob>...
+func main() {
+	tz := flag.String("tz", "Local", "time zone of .csv timestamps without an offset (e.g. UTC, Europe/Lisbon)")
+	formats := flag.String("format", "xlsx", "comma-separated outputs: xlsx, html, json")
+	combined := flag.String("combined", "", "write one combined output per format (workbook with a summary sheet, HTML report, JSON) to this path, with the extension replaced, instead of one per file")
+	jobs := flag.Int("j", runtime.NumCPU(), "number of files processed in parallel")
+	quiet := flag.Bool("q", false, "suppress per-file progress output")
+	lags := flag.Int("lags", 50, "largest lag of the ones-count autocorrelation (0 = skip)")
+	fftLen := flag.Int("fft", 1024, "periodogram segment length in samples, a power of two >= 16 (0 = skip)")
+	bitLags := flag.Int("bit-lags", 32, fmt.Sprintf("largest lag of the bit autocorrelation of .bin files, at most %d (0 = skip)", maxBitLags))
+	correlate := flag.Bool("correlate", false, "align the inputs by timestamp and compare them as simultaneous captures: pairwise correlation, bit agreement and network variance; written to the -combined outputs or, without -combined, to <time>_correlation.<format> next to the first input")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: filetoexcel [flags] <file|dir|glob>...")
+		fmt.Fprintln(os.Stderr, "Directories are scanned for .bin and .csv files; glob patterns are expanded.")
+		fmt.Fprintln(os.Stderr, "Each input gets <input>.<format> next to it, or with -combined one output per format.")
+		fmt.Fprintln(os.Stderr, "With -correlate the inputs are also compared as simultaneous captures; at least two are needed.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
 	}
-
-	return f.SaveAs(fileToSave)
-}
-
-// run performs the end-to-end workflow: parse inputs, read data, compute, and export.
-func run(filePath string) error {
-	interval, err := findInterval(filePath)
+	loc, err := time.LoadLocation(*tz)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, "error: -tz:", err)
+		os.Exit(2)
 	}
-	blockSize, err := findBitCount(filePath)
+	formatSet, err := parseFormats(*formats)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, "error: -format:", err)
+		os.Exit(2)
 	}
-
-	var rows []DataRow
-	firstHeader := blockColumnName
-	if strings.HasSuffix(strings.ToLower(filePath), ".bin") {
-		rows, err = readBinFile(filePath, blockSize)
-		firstHeader = blockColumnName
-	} else if strings.HasSuffix(strings.ToLower(filePath), ".csv") {
-		rows, err = readCSVFile(filePath)
-		firstHeader = timeColumnName
-	} else {
-		return fmt.Errorf("unsupported file type: %s", filepath.Ext(filePath))
+	if *jobs < 1 {
+		*jobs = 1
 	}
+	serial := serialOptions{Lags: *lags, FFT: *fftLen, BitLags: *bitLags}
+	if err := serial.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	paths, err := expandInputs(flag.Args())
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
-
-	rows = calculateZTest(rows, blockSize)
-	return writeToExcel(rows, filePath, blockSize, interval, firstHeader)
-}
-
-// main is the entry-point CLI that mirrors file_to_excel.py behavior.
-// Usage: filetoexcel <path-to-.bin-or-.csv>
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintln(os.Stderr, "Usage: filetoexcel <path-to-.bin-or-.csv>")
-		os.Exit(2)
+	b := &batch{loc: loc, serial: serial, correlate: *correlate, jobs: *jobs, formats: formatSet, combined: *combined, progress: os.Stderr}
+	if *quiet {
+		b.progress = io.Discard
 	}
-	filePath := os.Args[1]
-	if err := run(filePath); err != nil {
+	if err := b.run(paths); err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// fileReport is the machine-readable result for one input file. It is
+// written as JSON and rendered as HTML.
+type fileReport struct {
+	File            string `json:"file"`
+	Device          string `json:"device,omitempty"`
+	Bits            int    `json:"bits,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+	// Error is set, and the statistics omitted, if the file failed.
+	Error string `json:"error,omitempty"`
+
+	Samples             int        `json:"samples"`
+	Start               *time.Time `json:"start,omitempty"`
+	End                 *time.Time `json:"end,omitempty"`
+	MeanOnes            float64    `json:"mean_ones"`
+	SDOnes              float64    `json:"sd_ones"`
+	ExpectedMean        float64    `json:"expected_mean"`
+	ExpectedSD          float64    `json:"expected_sd"`
+	FinalZ              float64    `json:"final_z"`
+	PValue              float64    `json:"p_value"`
+	CumulativeDeviation float64    `json:"cumulative_deviation"`
+	// ChiSquare is the sum of the squared per-sample z-scores, with
+	// ChiSquareDF (= Samples) degrees of freedom.
+	ChiSquare   float64 `json:"chi_square"`
+	ChiSquareDF int     `json:"chi_square_df"`
+	ChiSquareP  float64 `json:"chi_square_p"`
+	SkippedRows int     `json:"skipped_rows"`
+	// Series is the cumulative z-score and deviation, every Step-th sample
+	// plus the last.
+	Series reportSeries `json:"series"`
+	// Histogram counts samples by ones count, with the count expected from
+	// the binomial distribution.
+	Histogram []histBin `json:"histogram"`
+	// Events are the operator markers recorded with the capture.
+	Events []events.Event `json:"events,omitempty"`
+	// Autocorrelation and Spectrum test the ones counts for serial
+	// dependence, such as drift or a periodic disturbance;
+	// BitAutocorrelation tests the bits of a .bin capture.
+	Autocorrelation    *autocorrReport    `json:"autocorrelation,omitempty"`
+	Spectrum           *spectrumReport    `json:"spectrum,omitempty"`
+	BitAutocorrelation *bitAutocorrReport `json:"bit_autocorrelation,omitempty"`
+}
+
+// reportSeries is a possibly decimated cumulative series.
+type reportSeries struct {
+	Step   int           `json:"step"`
+	Points []seriesPoint `json:"points"`
+}
+
+// seriesPoint is one point of a reportSeries.
+type seriesPoint struct {
+	Sample              int        `json:"sample"`
+	Time                *time.Time `json:"time,omitempty"`
+	Z                   float64    `json:"z"`
+	CumulativeDeviation float64    `json:"cumulative_deviation"`
+}
+
+// histBin is the number of samples with a given ones count.
+type histBin struct {
+	Ones     int     `json:"ones"`
+	Count    int     `json:"count"`
+	Expected float64 `json:"expected"`
+}
+
+// combinedReport is the JSON document written for -combined.
+type combinedReport struct {
+	Generated time.Time `json:"generated"`
+	// StoufferZ combines the final z-scores of the StoufferFiles files
+	// analysed; StoufferP is its two-sided p-value.
+	StoufferZ     float64       `json:"stouffer_z"`
+	StoufferP     float64       `json:"stouffer_p"`
+	StoufferFiles int           `json:"stouffer_files"`
+	Files         []*fileReport `json:"files"`
+	// Correlation compares the files as simultaneous captures (-correlate).
+	Correlation *correlationReport `json:"correlation,omitempty"`
+}
+
+// newFileReport summarises a.
+func newFileReport(a *analysis) *fileReport {
+	r := baseReport(a.Path)
+	r.Bits, r.IntervalSeconds = a.BlockSize, a.Interval
+	r.Samples = a.Samples
+	if a.TimeAxis() {
+		first, last := a.First, a.Last
+		r.Start, r.End = &first, &last
+	}
+	r.MeanOnes, r.SDOnes = a.MeanOnes, a.SDOnes
+	r.ExpectedMean = 0.5 * float64(a.BlockSize)
+	r.ExpectedSD = math.Sqrt(float64(a.BlockSize) * 0.25)
+	r.FinalZ = a.FinalZ
+	r.PValue = stats.TwoSidedP(a.FinalZ)
+	r.CumulativeDeviation = a.FinalDeviation
+	r.ChiSquare, r.ChiSquareDF, r.ChiSquareP = a.ChiSquare, a.Samples, a.ChiSquareP
+	r.SkippedRows = a.BadCount
+
+	r.Series.Step = a.ChartStep
+	r.Series.Points = make([]seriesPoint, len(a.Chart))
+	for i, row := range a.Chart {
+		p := seriesPoint{Sample: row.Index, Z: row.ZScore, CumulativeDeviation: row.CumulativeDeviation}
+		if !row.Time.IsZero() {
+			t := row.Time
+			p.Time = &t
+		}
+		r.Series.Points[i] = p
+	}
+
+	for ones, n := range a.Hist {
+		r.Histogram = append(r.Histogram, histBin{Ones: ones, Count: n, Expected: float64(a.Samples) * binomialPMF(a.BlockSize, ones)})
+	}
+	sort.Slice(r.Histogram, func(i, j int) bool { return r.Histogram[i].Ones < r.Histogram[j].Ones })
+	r.Events = a.Events
+	r.Autocorrelation, r.Spectrum, r.BitAutocorrelation = a.Autocorrelation, a.Spectrum, a.BitAutocorrelation
+	return r
+}
+
+// stoufferOfReports returns the Stouffer Z of the final z-scores of the
+// reports that have statistics, and how many there were.
+func stoufferOfReports(reports []*fileReport) (float64, int) {
+	var zs []float64
+	for _, r := range reports {
+		if r.Error == "" && r.Samples > 0 {
+			zs = append(zs, r.FinalZ)
+		}
+	}
+	return stoufferZ(zs), len(zs)
+}
+
+// failedReport describes a file that could not be analysed.
+func failedReport(path string, err error) *fileReport {
+	r := baseReport(path)
+	r.Error = err.Error()
+	return r
+}
+
+// baseReport fills in what the file name tells.
+func baseReport(path string) *fileReport {
+	r := &fileReport{File: filepath.Base(path)}
+	if info, err := naming.ParseBaseName(path); err == nil {
+		r.Device, r.Bits, r.IntervalSeconds = string(info.Device), info.Bits, info.IntervalSeconds
+	}
+	return r
+}
+
+// writeJSONReport writes v as indented JSON to path.
+func writeJSONReport(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// htmlFile is one file's section of the HTML report.
+type htmlFile struct {
+	Anchor string
+	*fileReport
+	Charts   []template.HTML
+	Segments []events.Segment
+}
+
+// writeHTMLReport writes a self-contained HTML report (inline CSS and SVG,
+// no scripts or external resources) covering reports and, if non-nil, corr
+// to path.
+func writeHTMLReport(path, title string, reports []*fileReport, corr *correlationReport) error {
+	page := struct {
+		Title     string
+		Generated string
+		Files     []htmlFile
+		// Stouffer combines the files' final z-scores.
+		StoufferZ, StoufferP float64
+		StoufferFiles        int
+		Correlation          *correlationReport
+		NetvarChart          template.HTML
+	}{Title: title, Generated: time.Now().Format("2006-01-02 15:04:05 MST"), Correlation: corr}
+	if corr != nil {
+		page.NetvarChart = template.HTML(netvarChart(corr).render())
+	}
+	page.StoufferZ, page.StoufferFiles = stoufferOfReports(reports)
+	page.StoufferP = stats.TwoSidedP(page.StoufferZ)
+	for i, r := range reports {
+		hf := htmlFile{Anchor: fmt.Sprintf("f%d", i+1), fileReport: r, Segments: events.Segments(r.Events)}
+		if r.Error == "" && r.Samples > 0 {
+			for _, c := range reportCharts(r) {
+				hf.Charts = append(hf.Charts, template.HTML(c.render()))
+			}
+		}
+		page.Files = append(page.Files, hf)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := htmlTemplate.Execute(f, page); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reportCharts builds the cumulative z-score, cumulative deviation and
+// histogram charts for r.
+func reportCharts(r *fileReport) []*svgChart {
+	timeAxis := r.Start != nil
+	xs := make([]float64, len(r.Series.Points))
+	zs := make([]float64, len(xs))
+	devs := make([]float64, len(xs))
+	for i, p := range r.Series.Points {
+		xs[i] = float64(p.Sample)
+		if timeAxis && p.Time != nil {
+			xs[i] = float64(p.Time.UnixNano()) / 1e9
+		}
+		zs[i], devs[i] = p.Z, p.CumulativeDeviation
+	}
+	zRefs := []svgRef{{Y: 0, Color: "#888"}}
+	devLines := []svgLine{{X: xs, Y: devs, Color: "#1f5fa8"}}
+	for i, k := range envelopeZ {
+		color := envelopeColors[i]
+		label := fmt.Sprintf("±%.2f", k)
+		zRefs = append(zRefs, svgRef{Y: k, Color: color, Label: label}, svgRef{Y: -k, Color: color})
+		// The deviation after n samples has sd sqrt(n·N/4); the curve is
+		// smooth, so a few points are enough.
+		var ex, hi, lo []float64
+		step := max(1, len(xs)/maxEnvelopePoints)
+		for j := 0; j < len(xs); j += step {
+			if j+step >= len(xs) {
+				j = len(xs) - 1
+			}
+			bound := k * math.Sqrt(float64(r.Series.Points[j].Sample)) * r.ExpectedSD
+			ex, hi, lo = append(ex, xs[j]), append(hi, bound), append(lo, -bound)
+		}
+		devLines = append(devLines,
+			svgLine{X: ex, Y: hi, Color: color, Dashed: true, Label: label},
+			svgLine{X: ex, Y: lo, Color: color, Dashed: true})
+	}
+	xLabel := fmt.Sprintf("Number of Samples - one sample every %d second(s)", r.IntervalSeconds)
+	var loc *time.Location
+	if timeAxis {
+		loc = r.Start.Location()
+		xLabel = fmt.Sprintf("Time (%s) - one sample every %d second(s)", r.Start.Format("MST"), r.IntervalSeconds)
+	}
+
+	marks, spans := eventMarks(r, xs)
+	z := &svgChart{
+		Title: "Cumulative z-score", XLabel: xLabel, YLabel: "z", XTime: timeAxis, Loc: loc,
+		Lines:  []svgLine{{X: xs, Y: zs, Color: "#1f5fa8"}},
+		HLines: zRefs,
+		VLines: marks, Spans: spans,
+	}
+	dev := &svgChart{
+		Title: "Cumulative deviation", XLabel: xLabel, YLabel: "Σ(ones − N/2)", XTime: timeAxis, Loc: loc,
+		Lines:  devLines,
+		HLines: []svgRef{{Y: 0, Color: "#888"}},
+		VLines: marks, Spans: spans,
+	}
+	charts := []*svgChart{z, dev, histogramChart(r)}
+	if r.Autocorrelation != nil {
+		charts = append(charts, lagChart("Autocorrelation of ones per sample", "Lag (samples)", "r", r.Autocorrelation.Values, r.Autocorrelation.Threshold, false))
+	}
+	if r.Spectrum != nil {
+		charts = append(charts, spectrumChart(r.Spectrum))
+	}
+	if r.BitAutocorrelation != nil {
+		charts = append(charts, lagChart("Bit autocorrelation", "Lag (bits)", "z", r.BitAutocorrelation.Values, r.BitAutocorrelation.Threshold, true))
+	}
+	return charts
+}
+
+// lagChart draws an autocorrelation as one bar per lag, with the flagging
+// threshold; it plots the z-scores if z is set, else the correlations.
+func lagChart(title, xLabel, yLabel string, values []lagValue, threshold float64, z bool) *svgChart {
+	c := &svgChart{Title: title, XLabel: xLabel, YLabel: yLabel, YFromZero: true}
+	for _, v := range values {
+		y := v.R
+		if z {
+			y = v.Z
+		}
+		c.Bars = append(c.Bars, svgBar{X0: float64(v.Lag) - 0.4, X1: float64(v.Lag) + 0.4, Y: y})
+	}
+	label := fmt.Sprintf("±%.3g (α = %g)", threshold, serialAlpha)
+	c.HLines = []svgRef{{Y: 0, Color: "#888"}, {Y: threshold, Color: "#c0392b", Label: label}, {Y: -threshold, Color: "#c0392b"}}
+	return c
+}
+
+// spectrumChart draws the periodogram with the flagging threshold.
+func spectrumChart(s *spectrumReport) *svgChart {
+	line := svgLine{Color: "#1f5fa8"}
+	for _, b := range s.Bins {
+		line.X = append(line.X, b.Frequency)
+		line.Y = append(line.Y, b.Power)
+	}
+	return &svgChart{
+		Title:     fmt.Sprintf("Periodogram of ones per sample (%d segment(s) of %d)", s.Segments, s.SegmentLength),
+		XLabel:    "Frequency (cycles per sample)",
+		YLabel:    "Power (1 = white noise)",
+		YFromZero: true,
+		Lines:     []svgLine{line},
+		HLines:    []svgRef{{Y: 1, Color: "#888"}, {Y: s.Threshold, Color: "#c0392b", Label: fmt.Sprintf("%.3g (α = %g)", s.Threshold, serialAlpha)}},
+	}
+}
+
+// eventMarks returns r's mark events as vertical lines and its segments as
+// shaded spans, on the x scale of the charts (Unix seconds or sample
+// numbers); xs are the chart's x values, the last of which ends segments
+// that were never stopped.
+func eventMarks(r *fileReport, xs []float64) ([]svgMark, []svgSpan) {
+	x := func(e events.Event) float64 {
+		if r.Start != nil {
+			return float64(e.Time.UnixNano()) / 1e9
+		}
+		return float64(e.Sample)
+	}
+	var marks []svgMark
+	for _, e := range r.Events {
+		if e.Kind == events.Mark {
+			marks = append(marks, svgMark{X: x(e), Color: eventColor, Label: e.Label})
+		}
+	}
+	var spans []svgSpan
+	for _, s := range events.Segments(r.Events) {
+		end := xs[len(xs)-1]
+		if !s.Open {
+			end = x(s.Stop)
+		}
+		spans = append(spans, svgSpan{X0: x(s.Start), X1: end, Color: eventColor, Label: s.Label})
+	}
+	return marks, spans
+}
+
+// histogramChart plots the ones-count histogram against the binomial curve,
+// grouping adjacent counts when the observed range is wide.
+func histogramChart(r *fileReport) *svgChart {
+	c := &svgChart{
+		Title:     "Ones per sample",
+		XLabel:    fmt.Sprintf("Ones in %d bits", r.Bits),
+		YLabel:    "Samples",
+		YFromZero: true,
+	}
+	if len(r.Histogram) == 0 {
+		return c
+	}
+	lo := min(r.Histogram[0].Ones, int(math.Floor(r.ExpectedMean-4*r.ExpectedSD)))
+	hi := max(r.Histogram[len(r.Histogram)-1].Ones, int(math.Ceil(r.ExpectedMean+4*r.ExpectedSD)))
+	lo, hi = max(lo, 0), min(hi, r.Bits)
+	width := max(1, (hi-lo+1+79)/80)
+	nbins := (hi-lo)/width + 1
+	counts := make([]float64, nbins)
+	for _, h := range r.Histogram {
+		if h.Ones >= lo && h.Ones <= hi {
+			counts[(h.Ones-lo)/width] += float64(h.Count)
+		}
+	}
+	curve := svgLine{Color: "#c0392b", Label: "binomial expectation"}
+	for i := range counts {
+		x0 := float64(lo + i*width)
+		c.Bars = append(c.Bars, svgBar{X0: x0 - 0.5, X1: x0 + float64(width) - 0.5, Y: counts[i]})
+		exp := 0.0
+		for k := lo + i*width; k < lo+(i+1)*width; k++ {
+			exp += float64(r.Samples) * binomialPMF(r.Bits, k)
+		}
+		curve.X = append(curve.X, x0+float64(width-1)/2)
+		curve.Y = append(curve.Y, exp)
+	}
+	c.Lines = []svgLine{curve}
+	if width > 1 {
+		c.XLabel += fmt.Sprintf(" (bins of %d)", width)
+	}
+	return c
+}
+
+// netvarChart plots the cumulative deviation of the network variance with
+// its significance envelopes, ±k·√(2n).
+func netvarChart(corr *correlationReport) *svgChart {
+	nv := corr.NetworkVariance
+	var xs, ys []float64
+	for _, p := range nv.Series {
+		xs = append(xs, float64(p.Time.UnixNano())/1e9)
+		ys = append(ys, p.CumulativeDeviation)
+	}
+	lines := []svgLine{{X: xs, Y: ys, Color: "#1f5fa8"}}
+	for i, k := range envelopeZ {
+		var ex, hi, lo []float64
+		step := max(1, len(xs)/maxEnvelopePoints)
+		for j := 0; j < len(xs); j += step {
+			if j+step >= len(xs) {
+				j = len(xs) - 1
+			}
+			// The series has a point every nv.Step samples, plus the last.
+			n := float64(min(j*nv.Step+1, nv.Seconds))
+			bound := k * math.Sqrt(2*n)
+			ex, hi, lo = append(ex, xs[j]), append(hi, bound), append(lo, -bound)
+		}
+		color := envelopeColors[i]
+		lines = append(lines,
+			svgLine{X: ex, Y: hi, Color: color, Dashed: true, Label: fmt.Sprintf("±%.2f", k)},
+			svgLine{X: ex, Y: lo, Color: color, Dashed: true})
+	}
+	var loc *time.Location
+	if len(nv.Series) > 0 {
+		loc = nv.Series[0].Time.Location()
+	}
+	return &svgChart{
+		Title:  "Network variance: cumulative Σ(Z² − 1)",
+		XLabel: fmt.Sprintf("Time - one sample every %d second(s)", corr.IntervalSeconds),
+		YLabel: "Σ(Z² − 1)",
+		XTime:  true, Loc: loc,
+		Lines:  lines,
+		HLines: []svgRef{{Y: 0, Color: "#888"}},
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"f":   func(prec int, v float64) string { return fmt.Sprintf("%.*f", prec, v) },
+	"sub": func(a, b int) int { return a - b },
+	"g":   func(v float64) string { return fmt.Sprintf("%.4g", v) },
+	"ts": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #222; margin: 24px auto; max-width: 960px; padding: 0 16px; }
+table { border-collapse: collapse; margin: 8px 0 16px; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: right; }
+th { background: #f3f3f3; }
+.l { text-align: left; }
+.err { color: #b00; }
+.note { color: #666; font-size: 90%; }
+section { margin: 32px 0; }
+svg { display: block; margin: 8px 0 20px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="note">Generated {{.Generated}} by filetoexcel.</p>
+{{if gt (len .Files) 1}}
+<h2>Summary</h2>
+<table>
+<tr><th class="l">File</th><th class="l">Device</th><th>Bits</th><th>Interval (s)</th><th>Samples</th><th>Final z</th><th>p-value</th><th>χ²</th><th>χ² p</th><th>Skipped rows</th></tr>
+{{range .Files}}<tr><td class="l"><a href="#{{.Anchor}}">{{.File}}</a></td><td class="l">{{.Device}}</td><td>{{.Bits}}</td><td>{{.IntervalSeconds}}</td>{{if .Error}}<td class="l err" colspan="6">{{.Error}}</td>{{else}}<td>{{.Samples}}</td><td>{{f 3 .FinalZ}}</td><td>{{f 4 .PValue}}</td><td>{{f 1 .ChiSquare}}</td><td>{{f 4 .ChiSquareP}}</td><td>{{.SkippedRows}}</td>{{end}}</tr>
+{{end}}{{if gt .StoufferFiles 1}}<tr><th class="l" colspan="5">Combined (Stouffer Z of {{.StoufferFiles}} files)</th><td>{{f 3 .StoufferZ}}</td><td>{{f 4 .StoufferP}}</td><td colspan="3"></td></tr>
+{{end}}</table>
+{{end}}
+{{with .Correlation}}
+<h2>Simultaneous captures</h2>
+<p class="note">Samples are aligned by timestamp ({{.IntervalSeconds}} s interval). Bit agreement is the share of bits equal in both captures (0.5 for independent devices), for .bin captures of the same sample size.</p>
+<table>
+<tr><th class="l">Capture A</th><th class="l">Capture B</th><th>Common samples</th><th>r</th><th>p</th><th>Bits compared</th><th>Agreement</th><th>z</th><th>p</th></tr>
+{{range .Pairs}}<tr><td class="l">{{.A}}</td><td class="l">{{.B}}</td><td>{{.Samples}}</td><td>{{f 4 .Correlation}}</td><td>{{f 4 .CorrelationP}}</td>{{if .Bits}}<td>{{.Bits}}</td><td>{{f 6 .AgreementRate}}</td><td>{{f 3 .AgreementZ}}</td><td>{{f 4 .AgreementP}}</td>{{else}}<td colspan="4"></td>{{end}}</tr>
+{{end}}</table>
+{{with .NetworkVariance}}<table>
+<tr><th class="l">Network variance (Σ Stouffer Z² per aligned sample)</th><th>Samples</th><th>χ²</th><th>z</th><th>p</th></tr>
+<tr><td class="l"></td><td>{{.Seconds}}</td><td>{{f 1 .ChiSquare}}</td><td>{{f 3 .Z}}</td><td>{{f 4 .P}}</td></tr>
+</table>{{end}}
+<table>
+<tr><th class="l">Capture</th><th>Samples</th><th>Matched</th><th>Skipped</th></tr>
+{{range .Captures}}<tr><td class="l">{{.File}}</td><td>{{.Samples}}</td><td>{{.Matched}}</td><td>{{.Skipped}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Correlation}}{{.NetvarChart}}{{end}}
+{{range .Files}}
+<section id="{{.Anchor}}">
+<h2>{{.File}}</h2>
+{{if .Error}}<p class="err">{{.Error}}</p>{{else}}
+<table>
+<tr><th class="l">Device</th><td>{{.Device}}</td></tr>
+<tr><th class="l">Bits per sample</th><td>{{.Bits}}</td></tr>
+<tr><th class="l">Interval (s)</th><td>{{.IntervalSeconds}}</td></tr>
+<tr><th class="l">Samples</th><td>{{.Samples}}</td></tr>
+{{if .Start}}<tr><th class="l">Start</th><td>{{ts .Start}}</td></tr>
+<tr><th class="l">End</th><td>{{ts .End}}</td></tr>{{end}}
+<tr><th class="l">Mean ones (expected)</th><td>{{f 3 .MeanOnes}} ({{f 1 .ExpectedMean}})</td></tr>
+<tr><th class="l">SD of ones (expected)</th><td>{{f 3 .SDOnes}} ({{f 3 .ExpectedSD}})</td></tr>
+<tr><th class="l">Cumulative deviation</th><td>{{f 1 .CumulativeDeviation}}</td></tr>
+<tr><th class="l">Final z</th><td>{{f 3 .FinalZ}}</td></tr>
+<tr><th class="l">p-value (two-sided)</th><td>{{f 4 .PValue}}</td></tr>
+<tr><th class="l">χ² of sample z-scores (df)</th><td>{{f 1 .ChiSquare}} ({{.ChiSquareDF}})</td></tr>
+<tr><th class="l">χ² p-value</th><td>{{f 4 .ChiSquareP}}</td></tr>
+<tr><th class="l">Skipped rows</th><td>{{.SkippedRows}}</td></tr>
+</table>
+{{if .Events}}<h3>Events</h3>
+<table>
+<tr><th class="l">Time</th><th>After sample</th><th class="l">Kind</th><th class="l">Label</th></tr>
+{{range .Events}}<tr><td class="l">{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Sample}}</td><td class="l">{{.Kind}}</td><td class="l">{{.Label}}</td></tr>
+{{end}}</table>
+{{if .Segments}}<table>
+<tr><th class="l">Segment</th><th>Start sample</th><th>Stop sample</th><th>Samples</th></tr>
+{{range .Segments}}<tr><td class="l">{{.Label}}</td><td>{{.Start.Sample}}</td>{{if .Open}}<td class="l" colspan="2">not stopped</td>{{else}}<td>{{.Stop.Sample}}</td><td>{{sub .Stop.Sample .Start.Sample}}</td>{{end}}</tr>
+{{end}}</table>{{end}}{{end}}
+{{if or .Autocorrelation .Spectrum .BitAutocorrelation}}<h3>Serial dependence</h3>
+<table>
+{{with .Autocorrelation}}<tr><th class="l">Ljung-Box Q, lags 1–{{.Lags}} (p)</th><td>{{f 2 .LjungBox}} ({{f 4 .LjungBoxP}})</td></tr>
+<tr><th class="l">Flagged lags (|r| &gt; {{g .Threshold}})</th><td>{{if .Significant}}{{range $i, $k := .Significant}}{{if $i}}, {{end}}{{$k}}{{end}}{{else}}none{{end}}</td></tr>
+{{end}}{{with .Spectrum}}<tr><th class="l">Strongest period (power, p)</th><td>{{f 1 .Strongest.PeriodSamples}} samples{{if .Strongest.PeriodSeconds}} = {{f 0 .Strongest.PeriodSeconds}} s{{end}} ({{f 2 .Strongest.Power}}, {{g .Strongest.P}})</td></tr>
+<tr><th class="l">Flagged peaks (power &gt; {{f 2 .Threshold}})</th><td>{{len .Peaks}}</td></tr>
+{{end}}{{with .BitAutocorrelation}}<tr><th class="l">Flagged bit lags (|z| &gt; {{f 2 .Threshold}}, {{.Bits}} bits)</th><td>{{if .Significant}}{{range $i, $k := .Significant}}{{if $i}}, {{end}}{{$k}}{{end}}{{else}}none{{end}}</td></tr>
+{{end}}</table>
+{{with .Spectrum}}{{if .Peaks}}<table>
+<tr><th>Period (samples)</th><th>Period (s)</th><th>Frequency</th><th>Power</th><th>p</th></tr>
+{{range .Peaks}}<tr><td>{{f 1 .PeriodSamples}}</td><td>{{if .PeriodSeconds}}{{f 0 .PeriodSeconds}}{{end}}</td><td>{{f 4 .Frequency}}</td><td>{{f 2 .Power}}</td><td>{{g .P}}</td></tr>
+{{end}}</table>{{end}}{{end}}
+<p class="note">Lags and peaks are flagged at a family-wise level of 1% (Bonferroni). A flagged peak at a period matching a known cycle, such as a temperature or mains cycle, suggests the device is sensitive to it.</p>
+{{end}}
+{{range .Charts}}{{.}}
+{{end}}{{if gt .Series.Step 1}}<p class="note">Line charts show every {{.Series.Step}}th sample and the last.</p>{{end}}
+{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
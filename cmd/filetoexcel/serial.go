@@ -0,0 +1,469 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// serialOptions configures the serial dependence analyses: autocorrelation
+// and periodogram of the ones counts, and autocorrelation of the bits.
+type serialOptions struct {
+	// Lags is the largest lag of the ones-count autocorrelation; 0 skips it.
+	Lags int
+	// FFT is the periodogram segment length, a power of two; 0 skips it.
+	FFT int
+	// BitLags is the largest lag of the bit autocorrelation, at most
+	// maxBitLags; 0 skips it.
+	BitLags int
+}
+
+// validate checks the options against their limits.
+func (o serialOptions) validate() error {
+	switch {
+	case o.Lags < 0:
+		return errors.New("-lags must be >= 0")
+	case o.FFT != 0 && (o.FFT < minFFT || o.FFT&(o.FFT-1) != 0):
+		return fmt.Errorf("-fft must be 0 or a power of two >= %d", minFFT)
+	case o.BitLags < 0 || o.BitLags > maxBitLags:
+		return fmt.Errorf("-bit-lags must be between 0 and %d", maxBitLags)
+	}
+	return nil
+}
+
+const (
+	// serialAlpha is the family-wise significance level at which lags and
+	// spectral peaks are flagged, Bonferroni-corrected for the number
+	// tested.
+	serialAlpha = 0.01
+	// maxBitLags is the largest bit lag: each lag compares 64-bit words.
+	maxBitLags = 64
+	// minFFT is the shortest periodogram segment worth computing.
+	minFFT = 16
+	// maxSpectrumPeaks bounds the flagged peaks listed.
+	maxSpectrumPeaks = 10
+)
+
+// lagValue is the autocorrelation at one lag.
+type lagValue struct {
+	Lag int     `json:"lag"`
+	R   float64 `json:"r"`
+	// Z is R scaled by its standard error under independence.
+	Z           float64 `json:"z"`
+	Significant bool    `json:"significant,omitempty"`
+}
+
+// autocorrReport is the autocorrelation of the ones-count series.
+type autocorrReport struct {
+	Lags int `json:"lags"`
+	// Threshold is the |r| beyond which a lag is flagged.
+	Threshold float64    `json:"threshold"`
+	Values    []lagValue `json:"values"`
+	// LjungBox tests all lags jointly; it is chi-square with LjungBoxDF
+	// degrees of freedom when the samples are independent.
+	LjungBox    float64 `json:"ljung_box"`
+	LjungBoxDF  int     `json:"ljung_box_df"`
+	LjungBoxP   float64 `json:"ljung_box_p"`
+	Significant []int   `json:"significant_lags,omitempty"`
+}
+
+// spectrumBin is one frequency of the periodogram.
+type spectrumBin struct {
+	// Frequency is in cycles per sample; PeriodSamples is its inverse and
+	// PeriodSeconds the same in seconds, when the interval is known.
+	Frequency     float64 `json:"frequency"`
+	PeriodSamples float64 `json:"period_samples"`
+	PeriodSeconds float64 `json:"period_seconds,omitempty"`
+	// Power is normalised so that independent samples average 1.
+	Power       float64 `json:"power"`
+	P           float64 `json:"p"`
+	Significant bool    `json:"significant,omitempty"`
+}
+
+// spectrumReport is the averaged periodogram (Welch's method with
+// rectangular, non-overlapping segments) of the ones-count series.
+type spectrumReport struct {
+	SegmentLength int `json:"segment_length"`
+	Segments      int `json:"segments"`
+	// Threshold is the power beyond which a bin is flagged.
+	Threshold float64       `json:"threshold"`
+	Bins      []spectrumBin `json:"bins"`
+	Strongest spectrumBin   `json:"strongest"`
+	// Peaks are the flagged bins, strongest first, at most
+	// maxSpectrumPeaks.
+	Peaks []spectrumBin `json:"peaks,omitempty"`
+}
+
+// bitAutocorrReport is the autocorrelation of the bit stream of a .bin
+// capture, with the padding bits left out.
+type bitAutocorrReport struct {
+	Bits int64 `json:"bits"`
+	Lags int   `json:"lags"`
+	// Threshold is the |z| beyond which a lag is flagged.
+	Threshold   float64    `json:"threshold"`
+	Values      []lagValue `json:"values"`
+	Significant []int      `json:"significant_lags,omitempty"`
+}
+
+// bonferroniZ returns the |z| beyond which one of n two-sided tests is
+// flagged at family-wise level serialAlpha.
+func bonferroniZ(n int) float64 {
+	return math.Sqrt2 * math.Erfinv(1-serialAlpha/float64(max(n, 1)))
+}
+
+// serialAccumulator computes the ones-count autocorrelation and periodogram
+// in one streaming pass, in memory bounded by the lag and segment length.
+// Counts are centred on their expected mean to keep the sums small.
+type serialAccumulator struct {
+	opts     serialOptions
+	center   float64
+	variance float64
+
+	n          int
+	sum, sumSq float64
+	// first holds the first Lags values and ring the last Lags, value i at
+	// ring[i%Lags]; prods[k-1] is the sum of y[i]·y[i+k].
+	first []float64
+	ring  []float64
+	prods []float64
+
+	// seg collects the current periodogram segment and power sums |X_j|²
+	// over the completed ones.
+	seg      []complex128
+	power    []float64
+	segments int
+}
+
+func newSerialAccumulator(blockSize int, opts serialOptions) *serialAccumulator {
+	s := &serialAccumulator{opts: opts, center: 0.5 * float64(blockSize), variance: 0.25 * float64(blockSize)}
+	if opts.Lags > 0 {
+		s.ring = make([]float64, opts.Lags)
+		s.prods = make([]float64, opts.Lags)
+	}
+	if opts.FFT > 0 {
+		s.seg = make([]complex128, 0, opts.FFT)
+		s.power = make([]float64, opts.FFT/2)
+	}
+	return s
+}
+
+// add appends one sample's ones count.
+func (s *serialAccumulator) add(ones int) {
+	y := float64(ones) - s.center
+	if lags := s.opts.Lags; lags > 0 {
+		for k := 1; k <= min(lags, s.n); k++ {
+			s.prods[k-1] += y * s.ring[(s.n-k)%lags]
+		}
+		s.ring[s.n%lags] = y
+		if s.n < lags {
+			s.first = append(s.first, y)
+		}
+	}
+	s.n++
+	s.sum += y
+	s.sumSq += y * y
+	if s.opts.FFT > 0 {
+		s.seg = append(s.seg, complex(y, 0))
+		if len(s.seg) == s.opts.FFT {
+			s.addSegment(s.seg)
+			s.seg = s.seg[:0]
+		}
+	}
+}
+
+// addSegment adds the power of one segment to the sums.
+func (s *serialAccumulator) addSegment(seg []complex128) {
+	fft(seg)
+	for j := 1; j < len(seg)/2; j++ {
+		s.power[j] += real(seg[j])*real(seg[j]) + imag(seg[j])*imag(seg[j])
+	}
+	s.segments++
+}
+
+// autocorrelation returns the autocorrelation report, or nil if there are
+// too few samples or they do not vary.
+func (s *serialAccumulator) autocorrelation() *autocorrReport {
+	lags := min(s.opts.Lags, s.n/4)
+	if lags < 1 {
+		return nil
+	}
+	n := float64(s.n)
+	m := s.sum / n
+	c0 := s.sumSq - n*m*m
+	if c0 <= 0 {
+		return nil
+	}
+	r := &autocorrReport{Lags: lags, LjungBoxDF: lags}
+	zc := bonferroniZ(lags)
+	r.Threshold = zc / math.Sqrt(n)
+	// headSum and tailSum are the sums of the first and last k values.
+	headSum, tailSum := 0.0, 0.0
+	for k := 1; k <= lags; k++ {
+		headSum += s.first[k-1]
+		tailSum += s.ring[(s.n-k)%s.opts.Lags]
+		ck := s.prods[k-1] - m*((s.sum-tailSum)+(s.sum-headSum)) + (n-float64(k))*m*m
+		rk := ck / c0
+		v := lagValue{Lag: k, R: rk, Z: rk * math.Sqrt(n)}
+		if math.Abs(v.Z) > zc {
+			v.Significant = true
+			r.Significant = append(r.Significant, k)
+		}
+		r.Values = append(r.Values, v)
+		r.LjungBox += rk * rk / (n - float64(k))
+	}
+	r.LjungBox *= n * (n + 2)
+	r.LjungBoxP = stats.ChiSquareP(r.LjungBox, lags)
+	return r
+}
+
+// spectrum returns the periodogram report for a capture sampled every
+// interval seconds, or nil if there are too few samples. A capture shorter
+// than one segment is analysed as a single segment of the largest power of
+// two that fits.
+func (s *serialAccumulator) spectrum(interval int) *spectrumReport {
+	if s.opts.FFT == 0 || s.variance == 0 {
+		return nil
+	}
+	length := s.opts.FFT
+	if s.segments == 0 {
+		if len(s.seg) < minFFT {
+			return nil
+		}
+		length = 1 << (bits.Len(uint(len(s.seg))) - 1)
+		s.power = make([]float64, length/2)
+		s.addSegment(append([]complex128(nil), s.seg[:length]...))
+	}
+	r := &spectrumReport{SegmentLength: length, Segments: s.segments}
+	// Under independence each normalised bin is chi-square with 2·segments
+	// degrees of freedom, divided by that.
+	df := 2 * s.segments
+	nbins := length/2 - 1
+	r.Threshold = chiSquareQuantile(serialAlpha/float64(nbins), df) / float64(df)
+	for j := 1; j <= nbins; j++ {
+		b := spectrumBin{
+			Frequency:     float64(j) / float64(length),
+			PeriodSamples: float64(length) / float64(j),
+			Power:         s.power[j] / (float64(s.segments) * float64(length) * s.variance),
+		}
+		if interval > 0 {
+			b.PeriodSeconds = b.PeriodSamples * float64(interval)
+		}
+		b.P = stats.ChiSquareP(b.Power*float64(df), df)
+		b.Significant = b.Power > r.Threshold
+		r.Bins = append(r.Bins, b)
+		if b.Power > r.Strongest.Power {
+			r.Strongest = b
+		}
+		if b.Significant {
+			r.Peaks = append(r.Peaks, b)
+		}
+	}
+	sort.Slice(r.Peaks, func(i, j int) bool { return r.Peaks[i].Power > r.Peaks[j].Power })
+	if len(r.Peaks) > maxSpectrumPeaks {
+		r.Peaks = r.Peaks[:maxSpectrumPeaks]
+	}
+	return r
+}
+
+// chiSquareQuantile returns x with stats.ChiSquareP(x, df) = p, by bisection.
+func chiSquareQuantile(p float64, df int) float64 {
+	lo, hi := 0.0, float64(df)
+	for stats.ChiSquareP(hi, df) > p {
+		hi *= 2
+	}
+	for i := 0; i < 100 && hi-lo > 1e-9*hi; i++ {
+		mid := (lo + hi) / 2
+		if stats.ChiSquareP(mid, df) > p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// fft transforms x in place; len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// bitAutocorr computes the autocorrelation of a bit stream at lags up to
+// maxBitLags, comparing 64 bits at a time: each complete word is XORed
+// with the stream shifted by each lag, which needs the next word too.
+type bitAutocorr struct {
+	lags int
+	// word collects nword incoming bits; prev is the last complete word,
+	// waiting for its successor.
+	word    uint64
+	nword   int
+	prev    uint64
+	hasPrev bool
+	// differ[k-1] counts pairs k bits apart that differ, out of pairs[k-1].
+	differ []int64
+	pairs  []int64
+	ones   int64
+	total  int64
+}
+
+func newBitAutocorr(lags int) *bitAutocorr {
+	lags = min(lags, maxBitLags)
+	return &bitAutocorr{lags: lags, differ: make([]int64, lags), pairs: make([]int64, lags)}
+}
+
+// addBlock adds the data bits of one blockSize-bit sample, skipping the
+// padding at the end of its final byte.
+func (b *bitAutocorr) addBlock(block []byte, blockSize int) {
+	for i, c := range block {
+		n := min(8, blockSize-8*i)
+		if n <= 0 {
+			break
+		}
+		b.addBits(uint64(c>>(8-n)), n)
+	}
+}
+
+// addBits appends the low n (at most 8) bits of v, most significant first.
+func (b *bitAutocorr) addBits(v uint64, n int) {
+	b.total += int64(n)
+	b.ones += int64(bits.OnesCount64(v))
+	if free := 64 - b.nword; n >= free {
+		rest := n - free
+		b.word = b.word<<free | v>>rest
+		b.addWord(b.word)
+		b.word, b.nword = v&(1<<rest-1), rest
+		return
+	}
+	b.word = b.word<<n | v
+	b.nword += n
+}
+
+// addWord counts the pairs starting in the previous word, now that w
+// follows it.
+func (b *bitAutocorr) addWord(w uint64) {
+	if b.hasPrev {
+		for k := 1; k <= b.lags; k++ {
+			shifted := b.prev<<k | w>>(64-k)
+			b.differ[k-1] += int64(bits.OnesCount64(b.prev ^ shifted))
+			b.pairs[k-1] += 64
+		}
+	}
+	b.prev, b.hasPrev = w, true
+}
+
+// report returns the bit autocorrelation, or nil if there are too few
+// bits. The correlation is taken about the observed proportion of ones, so
+// a biased stream is not reported as correlated.
+func (b *bitAutocorr) report() *bitAutocorrReport {
+	if b.lags < 1 || b.total <= int64(4*b.lags) {
+		return nil
+	}
+	// Count the pairs that start in the uncounted tail: prev and the
+	// partial word.
+	var tail []uint8
+	if b.hasPrev {
+		for i := 63; i >= 0; i-- {
+			tail = append(tail, uint8(b.prev>>i&1))
+		}
+	}
+	for i := b.nword - 1; i >= 0; i-- {
+		tail = append(tail, uint8(b.word>>i&1))
+	}
+	differ := append([]int64(nil), b.differ...)
+	pairs := append([]int64(nil), b.pairs...)
+	for k := 1; k <= b.lags; k++ {
+		for i := 0; i+k < len(tail); i++ {
+			differ[k-1] += int64(tail[i] ^ tail[i+k])
+			pairs[k-1]++
+		}
+	}
+
+	mu := 2*float64(b.ones)/float64(b.total) - 1
+	if 1-mu*mu <= 0 {
+		return nil
+	}
+	r := &bitAutocorrReport{Bits: b.total, Lags: b.lags, Threshold: bonferroniZ(b.lags)}
+	for k := 1; k <= b.lags; k++ {
+		// With bits mapped to ±1, the mean product is 1 - 2·differ/pairs.
+		prod := 1 - 2*float64(differ[k-1])/float64(pairs[k-1])
+		rk := (prod - mu*mu) / (1 - mu*mu)
+		v := lagValue{Lag: k, R: rk, Z: rk * math.Sqrt(float64(pairs[k-1]))}
+		if math.Abs(v.Z) > r.Threshold {
+			v.Significant = true
+			r.Significant = append(r.Significant, k)
+		}
+		r.Values = append(r.Values, v)
+	}
+	return r
+}
+
+// blockFunc returns a scanBinFile block callback feeding b; a partial
+// block at the end of the file is left out.
+func (b *bitAutocorr) blockFunc(blockSize int) func([]byte) {
+	return func(block []byte) {
+		if len(block) == bitpack.BytesFor(blockSize) {
+			b.addBlock(block, blockSize)
+		}
+	}
+}
+
+// serialNotes describes the flagged lags and peaks of a, for the progress
+// output.
+func serialNotes(a *analysis) []string {
+	var notes []string
+	if ac := a.Autocorrelation; ac != nil && len(ac.Significant) > 0 {
+		notes = append(notes, fmt.Sprintf("autocorrelation flagged at lag(s) %s (Ljung-Box p=%.4g)", joinInts(ac.Significant), ac.LjungBoxP))
+	}
+	if s := a.Spectrum; s != nil && len(s.Peaks) > 0 {
+		notes = append(notes, fmt.Sprintf("%d periodogram peak(s) flagged, strongest at a period of %s", len(s.Peaks), describePeriod(s.Peaks[0])))
+	}
+	if bac := a.BitAutocorrelation; bac != nil && len(bac.Significant) > 0 {
+		notes = append(notes, fmt.Sprintf("bit autocorrelation flagged at lag(s) %s", joinInts(bac.Significant)))
+	}
+	return notes
+}
+
+// describePeriod formats the period of b in samples and, if known, seconds.
+func describePeriod(b spectrumBin) string {
+	if b.PeriodSeconds > 0 {
+		return fmt.Sprintf("%.1f samples (%.0f s)", b.PeriodSamples, b.PeriodSeconds)
+	}
+	return fmt.Sprintf("%.1f samples", b.PeriodSamples)
+}
+
+// joinInts formats v as a comma-separated list.
+func joinInts(v []int) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ", ")
+}
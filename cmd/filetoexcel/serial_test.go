@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// accumulate feeds ones counts of blockSize-bit samples to a new
+// serialAccumulator.
+func accumulate(blockSize int, opts serialOptions, ones ...int) *serialAccumulator {
+	s := newSerialAccumulator(blockSize, opts)
+	for _, v := range ones {
+		s.add(v)
+	}
+	return s
+}
+
+// repeat returns pattern repeated to n values.
+func repeat(n int, pattern ...int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = pattern[i%len(pattern)]
+	}
+	return out
+}
+
+func TestAutocorrelation(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockSize int
+		ones      []int
+		want      []float64 // r at lags 1, 2, ...
+		ljungBox  float64
+	}{
+		// Centred values -1, 1, -1, ...: c0 = 8, the lag 1 products sum to
+		// -7 and the lag 2 ones to 6. Q = 8·10·(49/64/7 + 36/64/6).
+		{"alternating", 2, repeat(8, 0, 2), []float64{-7.0 / 8, 6.0 / 8}, 16.25},
+		// Mean 2.5: deviations -1.5, -0.5, 0.5, 1.5 repeated, c0 = 10. The
+		// lag 1 products sum to 0.25 and the lag 2 ones to -4.5.
+		// Q = 8·10·(0.025²/7 + 0.45²/6).
+		{"ramp", 4, repeat(8, 1, 2, 3, 4), []float64{0.025, -0.45}, 80 * (0.025*0.025/7 + 0.45*0.45/6)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := accumulate(tt.blockSize, serialOptions{Lags: 10}, tt.ones...).autocorrelation()
+			if r == nil {
+				t.Fatal("autocorrelation = nil")
+			}
+			// Lags are capped at a quarter of the samples.
+			if r.Lags != len(tt.want) || len(r.Values) != len(tt.want) {
+				t.Fatalf("%d lags, want %d", r.Lags, len(tt.want))
+			}
+			for i, v := range r.Values {
+				if v.Lag != i+1 || !near(v.R, tt.want[i]) || !near(v.Z, tt.want[i]*math.Sqrt(8)) {
+					t.Errorf("lag %d: r = %v, z = %v, want %v and %v", v.Lag, v.R, v.Z, tt.want[i], tt.want[i]*math.Sqrt(8))
+				}
+			}
+			if !near(r.LjungBox, tt.ljungBox) || r.LjungBoxDF != 2 {
+				t.Errorf("Ljung-Box = %v on %d df, want %v on 2", r.LjungBox, r.LjungBoxDF, tt.ljungBox)
+			}
+			if want := math.Exp(-tt.ljungBox / 2); math.Abs(r.LjungBoxP/want-1) > 1e-9 {
+				t.Errorf("Ljung-Box p = %v, want %v", r.LjungBoxP, want)
+			}
+		})
+	}
+}
+
+func TestAutocorrelationNone(t *testing.T) {
+	tests := []struct {
+		name string
+		ones []int
+	}{
+		{"too few samples", []int{0, 2, 0}},
+		{"constant", repeat(20, 1)},
+	}
+	for _, tt := range tests {
+		if r := accumulate(2, serialOptions{Lags: 10}, tt.ones...).autocorrelation(); r != nil {
+			t.Errorf("%s: autocorrelation = %+v, want nil", tt.name, r)
+		}
+	}
+}
+
+func TestSpectrum(t *testing.T) {
+	// Ones 2, 1, 0, 1 of 2-bit samples centre to cos(2πi/4): in a segment
+	// of 16 all the power is in bin 4, |X_4|² = (16/2)² = 64, normalised
+	// by 16 · variance 0.5 to 8 per segment.
+	tests := []struct {
+		name     string
+		samples  int
+		fft      int
+		segments int
+		p        float64 // of power 8 on 2·segments df
+	}{
+		{"one segment", 16, 16, 1, math.Exp(-8)},
+		{"two segments", 32, 16, 2, 17 * math.Exp(-16)},
+		{"short capture", 20, 32, 1, math.Exp(-8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := accumulate(2, serialOptions{FFT: tt.fft}, repeat(tt.samples, 2, 1, 0, 1)...).spectrum(2)
+			if r == nil {
+				t.Fatal("spectrum = nil")
+			}
+			if r.SegmentLength != 16 || r.Segments != tt.segments || len(r.Bins) != 7 {
+				t.Fatalf("%d segments of %d, %d bins, want %d of 16, 7 bins", r.Segments, r.SegmentLength, len(r.Bins), tt.segments)
+			}
+			for _, b := range r.Bins {
+				want := 0.0
+				if b.Frequency == 0.25 {
+					want = 8
+				}
+				if !near(b.Power, want) {
+					t.Errorf("frequency %v: power %v, want %v", b.Frequency, b.Power, want)
+				}
+			}
+			s := r.Strongest
+			if s.Frequency != 0.25 || s.PeriodSamples != 4 || s.PeriodSeconds != 8 || math.Abs(s.P/tt.p-1) > 1e-9 {
+				t.Errorf("strongest = %+v, want frequency 0.25, period 4 samples (8 s), p %v", s, tt.p)
+			}
+			if len(r.Peaks) != 1 || r.Peaks[0].Frequency != 0.25 {
+				t.Errorf("peaks = %+v, want the bin at 0.25", r.Peaks)
+			}
+		})
+	}
+	// For one segment the threshold solves exp(-x) = 0.01/7.
+	r := accumulate(2, serialOptions{FFT: 16}, repeat(16, 2, 1, 0, 1)...).spectrum(0)
+	if want := math.Log(700); math.Abs(r.Threshold-want) > 1e-6 {
+		t.Errorf("threshold = %v, want %v", r.Threshold, want)
+	}
+	if r.Strongest.PeriodSeconds != 0 {
+		t.Errorf("period %v s with no interval, want 0", r.Strongest.PeriodSeconds)
+	}
+	if r := accumulate(2, serialOptions{FFT: 16}, repeat(15, 2, 1, 0, 1)...).spectrum(1); r != nil {
+		t.Errorf("spectrum of 15 samples = %+v, want nil", r)
+	}
+}
+
+func TestBitAutocorrelation(t *testing.T) {
+	// Alternating bits: neighbours always differ, bits two apart never.
+	// 4-bit samples 1010 padded to 0xa0 give the same stream as bytes 0xaa.
+	tests := []struct {
+		name      string
+		blockSize int
+		block     byte
+	}{
+		{"bytes", 8, 0xaa},
+		{"padded", 4, 0xa0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBitAutocorr(4)
+			for range 64 {
+				b.addBlock([]byte{tt.block}, tt.blockSize)
+			}
+			r := b.report()
+			if r == nil {
+				t.Fatal("report = nil")
+			}
+			if r.Bits != int64(64*tt.blockSize) {
+				t.Errorf("%d bits, want %d", r.Bits, 64*tt.blockSize)
+			}
+			for i, v := range r.Values {
+				want := 1.0
+				if v.Lag%2 == 1 {
+					want = -1
+				}
+				if !near(v.R, want) {
+					t.Errorf("lag %d: r = %v, want %v", i+1, v.R, want)
+				}
+			}
+			if len(r.Significant) != 4 {
+				t.Errorf("significant lags %v, want all 4", r.Significant)
+			}
+		})
+	}
+}
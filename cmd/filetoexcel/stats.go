@@ -0,0 +1,36 @@
+package main
+
+import "math"
+
+// envelopeZ are the significance envelopes drawn on the charts, at
+// two-sided p = .05 and p = .01, and envelopeColors their colours.
+var (
+	envelopeZ      = []float64{1.96, 2.58}
+	envelopeColors = []string{"#ED7D31", "#7F7F7F"}
+)
+
+// eventColor is the colour of event lines and segments on the charts.
+const eventColor = "#D4A000"
+
+// stoufferZ combines independent z-scores: sum(z) / sqrt(len(z)).
+func stoufferZ(zs []float64) float64 {
+	if len(zs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, z := range zs {
+		sum += z
+	}
+	return sum / math.Sqrt(float64(len(zs)))
+}
+
+// binomialPMF returns P(X = k) for X ~ Binomial(n, 1/2).
+func binomialPMF(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	ln, _ := math.Lgamma(float64(n + 1))
+	lk, _ := math.Lgamma(float64(k + 1))
+	lnk, _ := math.Lgamma(float64(n - k + 1))
+	return math.Exp(ln - lk - lnk - float64(n)*math.Ln2)
+}
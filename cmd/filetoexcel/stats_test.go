@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// near reports whether got is within 1e-9 of want.
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9
+}
+
+func TestZScorer(t *testing.T) {
+	// 16-bit blocks: mean 8, standard deviation 2.
+	tests := []struct {
+		ones                                int
+		sampleZ, chi, cumMean, z, deviation float64
+	}{
+		{10, 1, 1, 10, 1, 2},
+		{6, -1, 2, 8, 0, 0},
+		// Mean 28/3 against 8, over 2/sqrt(3).
+		{12, 2, 6, 28.0 / 3, 2 / math.Sqrt(3), 4},
+		{8, 0, 6, 9, 1, 4},
+	}
+	z := newZScorer(16)
+	for i, tt := range tests {
+		r := DataRow{Ones: tt.ones}
+		z.add(&r)
+		if r.Index != i+1 {
+			t.Errorf("row %d: Index = %d", i+1, r.Index)
+		}
+		for _, c := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"SampleZ", r.SampleZ, tt.sampleZ},
+			{"ChiSquare", r.ChiSquare, tt.chi},
+			{"CumulativeMean", r.CumulativeMean, tt.cumMean},
+			{"ZScore", r.ZScore, tt.z},
+			{"CumulativeDeviation", r.CumulativeDeviation, tt.deviation},
+		} {
+			if !near(c.got, c.want) {
+				t.Errorf("row %d (ones %d): %s = %v, want %v", i+1, tt.ones, c.name, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestAnalyzeChiSquare(t *testing.T) {
+	// The rows of TestZScorer: chi-square 6 on 4 degrees of freedom, whose
+	// upper tail is e^-3 * (1 + 3).
+	path := filepath.Join(t.TempDir(), "20261001T130000_trng_s16_i1.csv")
+	rows := "20261001T13:00:00,10\n20261001T13:00:01,6\n20261001T13:00:02,12\n20261001T13:00:03,8\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := analyze(path, time.UTC, serialOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Samples != 4 || !near(a.ChiSquare, 6) || !near(a.ChiSquareP, 4*math.Exp(-3)) {
+		t.Errorf("analyze: %d samples, chi-square %v p %v, want 4, 6 and %v", a.Samples, a.ChiSquare, a.ChiSquareP, 4*math.Exp(-3))
+	}
+	if !near(a.FinalZ, 1) || !near(a.FinalDeviation, 4) || !near(a.MeanOnes, 9) {
+		t.Errorf("analyze: final z %v, deviation %v, mean %v, want 1, 4 and 9", a.FinalZ, a.FinalDeviation, a.MeanOnes)
+	}
+	// Sample variance of 10, 6, 12, 8 about 9: (1+9+9+1)/3.
+	if want := math.Sqrt(20.0 / 3); !near(a.SDOnes, want) {
+		t.Errorf("analyze: SD %v, want %v", a.SDOnes, want)
+	}
+}
+
+func TestStoufferZ(t *testing.T) {
+	tests := []struct {
+		zs   []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{1.96}, 1.96},
+		{[]float64{1, 1, 1, 1}, 2},
+		{[]float64{2, -2}, 0},
+		{[]float64{3, 0, 0}, math.Sqrt(3)},
+	}
+	for _, tt := range tests {
+		if got := stoufferZ(tt.zs); !near(got, tt.want) {
+			t.Errorf("stoufferZ(%v) = %v, want %v", tt.zs, got, tt.want)
+		}
+	}
+}
+
+func TestStoufferOfReports(t *testing.T) {
+	reports := []*fileReport{
+		{Samples: 5, FinalZ: 2},
+		{Error: "unreadable", FinalZ: 10},
+		{Samples: 0, FinalZ: 10},
+		{Samples: 3, FinalZ: 1},
+	}
+	z, n := stoufferOfReports(reports)
+	if want := 3 / math.Sqrt2; n != 2 || !near(z, want) {
+		t.Errorf("stoufferOfReports = %v over %d files, want %v over 2", z, n, want)
+	}
+}
+
+func TestBinomialPMF(t *testing.T) {
+	tests := []struct {
+		n, k int
+		want float64
+	}{
+		{1, 0, 0.5},
+		{4, 2, 6.0 / 16},
+		{4, 1, 4.0 / 16},
+		{10, 0, 1.0 / 1024},
+		{4, 5, 0},
+		{4, -1, 0},
+	}
+	for _, tt := range tests {
+		if got := binomialPMF(tt.n, tt.k); !near(got, tt.want) {
+			t.Errorf("binomialPMF(%d, %d) = %v, want %v", tt.n, tt.k, got, tt.want)
+		}
+	}
+	sum := 0.0
+	for k := 0; k <= 2048; k++ {
+		sum += binomialPMF(2048, k)
+	}
+	if !near(sum, 1) {
+		t.Errorf("binomialPMF(2048, k) sums to %v, want 1", sum)
+	}
+}
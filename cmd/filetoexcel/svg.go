@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+// Chart geometry in SVG user units.
+const (
+	svgWidth        = 860
+	svgHeight       = 320
+	svgMarginLeft   = 70
+	svgMarginRight  = 20
+	svgMarginTop    = 32
+	svgMarginBottom = 48
+	// svgMaxPoints bounds the vertices of a plotted line; longer series are
+	// thinned since there are only a few hundred pixels across anyway.
+	svgMaxPoints = 4000
+)
+
+// svgChart is a minimal chart renderer producing standalone SVG markup, so
+// reports need no scripts or network resources.
+type svgChart struct {
+	Title, XLabel, YLabel string
+	// XTime formats x values (Unix seconds) as times in Loc.
+	XTime bool
+	Loc   *time.Location
+	Lines []svgLine
+	Bars  []svgBar
+	// HLines are horizontal reference lines.
+	HLines []svgRef
+	// VLines mark points on the x axis and Spans shade ranges of it; both are
+	// clipped to the data's x range.
+	VLines []svgMark
+	Spans  []svgSpan
+	// YFromZero makes the y range include zero.
+	YFromZero bool
+}
+
+// svgLine is a polyline series.
+type svgLine struct {
+	X, Y   []float64
+	Color  string
+	Dashed bool
+	Label  string
+}
+
+// svgBar is one histogram bar spanning [X0, X1).
+type svgBar struct {
+	X0, X1, Y float64
+}
+
+// svgRef is a horizontal reference line.
+type svgRef struct {
+	Y     float64
+	Color string
+	Label string
+}
+
+// svgMark is a vertical marker line at X.
+type svgMark struct {
+	X     float64
+	Color string
+	Label string
+}
+
+// svgSpan shades the x range [X0, X1].
+type svgSpan struct {
+	X0, X1 float64
+	Color  string
+	Label  string
+}
+
+// svgMaxMarkLabels is the number of marks and spans above which their
+// labels are left out, since they would only overlap.
+const svgMaxMarkLabels = 30
+
+// render returns the chart as an <svg> element.
+func (c *svgChart) render() string {
+	x0, x1, y0, y1 := c.bounds()
+	pw := float64(svgWidth - svgMarginLeft - svgMarginRight)
+	ph := float64(svgHeight - svgMarginTop - svgMarginBottom)
+	px := func(x float64) float64 { return svgMarginLeft + (x-x0)/(x1-x0)*pw }
+	py := func(y float64) float64 { return svgMarginTop + (y1-y)/(y1-y0)*ph }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img" font-family="sans-serif" font-size="11">`, svgWidth, svgHeight)
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(c.Title))
+	fmt.Fprintf(&b, `<text x="%d" y="18" font-size="13" font-weight="bold">%s</text>`, svgMarginLeft, html.EscapeString(c.Title))
+
+	// Grid and ticks.
+	for _, t := range niceTicks(y0, y1, 6) {
+		y := py(t)
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#e4e4e4"/>`, svgMarginLeft, svgWidth-svgMarginRight, y, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, svgMarginLeft-6, y, formatTick(t))
+	}
+	var xt []float64
+	if c.XTime {
+		xt = timeTicks(x0, x1, 7, c.Loc)
+	} else {
+		xt = niceTicks(x0, x1, 8)
+	}
+	for _, t := range xt {
+		x := px(t)
+		label := formatTick(t)
+		if c.XTime {
+			label = formatTimeTick(t, x1-x0, c.Loc)
+		}
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%d" stroke="#999"/>`, x, x, svgHeight-svgMarginBottom, svgHeight-svgMarginBottom+4)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, svgHeight-svgMarginBottom+16, html.EscapeString(label))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#999"/>`, svgMarginLeft, svgMarginTop, pw, ph)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, svgMarginLeft+pw/2, svgHeight-8, html.EscapeString(c.XLabel))
+	fmt.Fprintf(&b, `<text transform="translate(14 %.1f) rotate(-90)" text-anchor="middle">%s</text>`, svgMarginTop+ph/2, html.EscapeString(c.YLabel))
+
+	label := len(c.VLines)+len(c.Spans) <= svgMaxMarkLabels
+	for _, s := range c.Spans {
+		xa, xb := px(math.Max(s.X0, x0)), px(math.Min(s.X1, x1))
+		if xb <= xa {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%.0f" fill="%s" fill-opacity="0.15"/>`, xa, svgMarginTop, xb-xa, ph, s.Color)
+		if label && s.Label != "" {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="10" fill="#555">%s</text>`, xa+3, svgHeight-svgMarginBottom-4, html.EscapeString(s.Label))
+		}
+	}
+	for _, m := range c.VLines {
+		if m.X < x0 || m.X > x1 {
+			continue
+		}
+		x := px(m.X)
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%d" stroke="%s" stroke-dasharray="2 3"/>`, x, x, svgMarginTop, svgHeight-svgMarginBottom, m.Color)
+		if label && m.Label != "" {
+			fmt.Fprintf(&b, `<text transform="translate(%.1f %d) rotate(-90)" text-anchor="end" font-size="10" fill="#555">%s</text>`, x-3, svgMarginTop+4, html.EscapeString(m.Label))
+		}
+	}
+	for _, bar := range c.Bars {
+		// Bars grow from zero, or from the frame when zero is out of range.
+		xa, xb := px(bar.X0), px(bar.X1)
+		ya, base := py(bar.Y), py(math.Min(math.Max(0, y0), y1))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.2f" height="%.1f" fill="#9cc3e6" stroke="#6a9fcf" stroke-width="0.5"/>`, xa, math.Min(ya, base), math.Max(xb-xa, 0.5), math.Abs(base-ya))
+	}
+	for _, r := range c.HLines {
+		if r.Y < y0 || r.Y > y1 {
+			continue
+		}
+		y := py(r.Y)
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="%s" stroke-dasharray="5 4"/>`, svgMarginLeft, svgWidth-svgMarginRight, y, y, r.Color)
+		if r.Label != "" {
+			fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" fill="%s">%s</text>`, svgWidth-svgMarginRight-4, y-3, r.Color, html.EscapeString(r.Label))
+		}
+	}
+	for _, l := range c.Lines {
+		b.WriteString(`<polyline fill="none" stroke-width="1.2" stroke-linejoin="round"`)
+		fmt.Fprintf(&b, ` stroke="%s"`, l.Color)
+		if l.Dashed {
+			b.WriteString(` stroke-dasharray="5 4"`)
+		}
+		b.WriteString(` points="`)
+		step := (len(l.X) + svgMaxPoints - 1) / svgMaxPoints
+		for i := 0; i < len(l.X); i += max(step, 1) {
+			fmt.Fprintf(&b, "%.1f,%.1f ", px(l.X[i]), py(l.Y[i]))
+		}
+		if n := len(l.X); n > 0 && (n-1)%max(step, 1) != 0 {
+			fmt.Fprintf(&b, "%.1f,%.1f", px(l.X[n-1]), py(l.Y[n-1]))
+		}
+		b.WriteString(`"/>`)
+	}
+
+	// Legend for labelled lines.
+	lx := float64(svgMarginLeft + 10)
+	for _, l := range c.Lines {
+		if l.Label == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%d" stroke="%s" stroke-width="2"`, lx, lx+18, svgMarginTop+12, svgMarginTop+12, l.Color)
+		if l.Dashed {
+			b.WriteString(` stroke-dasharray="4 3"`)
+		}
+		b.WriteString(`/>`)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" dominant-baseline="middle">%s</text>`, lx+22, svgMarginTop+12, html.EscapeString(l.Label))
+		lx += 30 + 6.5*float64(len(l.Label))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// bounds returns the data range, padded so nothing sits on the frame.
+func (c *svgChart) bounds() (x0, x1, y0, y1 float64) {
+	x0, y0 = math.Inf(1), math.Inf(1)
+	x1, y1 = math.Inf(-1), math.Inf(-1)
+	see := func(x, y float64) {
+		x0, x1 = math.Min(x0, x), math.Max(x1, x)
+		y0, y1 = math.Min(y0, y), math.Max(y1, y)
+	}
+	for _, l := range c.Lines {
+		for i := range l.X {
+			see(l.X[i], l.Y[i])
+		}
+	}
+	for _, bar := range c.Bars {
+		see(bar.X0, bar.Y)
+		see(bar.X1, bar.Y)
+	}
+	for _, r := range c.HLines {
+		y0, y1 = math.Min(y0, r.Y), math.Max(y1, r.Y)
+	}
+	if c.YFromZero {
+		y0, y1 = math.Min(y0, 0), math.Max(y1, 0)
+	}
+	if math.IsInf(x0, 0) {
+		x0, x1, y0, y1 = 0, 1, 0, 1
+	}
+	if x1 == x0 {
+		x0, x1 = x0-1, x1+1
+	}
+	if y1 == y0 {
+		y0, y1 = y0-1, y1+1
+	}
+	pad := (y1 - y0) * 0.05
+	if !(c.YFromZero && y0 == 0) {
+		y0 -= pad
+	}
+	y1 += pad
+	return x0, x1, y0, y1
+}
+
+// niceTicks returns about n round tick values within [lo, hi].
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi <= lo || n < 2 {
+		return nil
+	}
+	raw := (hi - lo) / float64(n-1)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	var ticks []float64
+	for t := math.Ceil(lo/step) * step; t <= hi+step*1e-9; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// timeTicks returns about n tick values (Unix seconds) within [lo, hi],
+// aligned to round wall-clock steps in loc.
+func timeTicks(lo, hi float64, n int, loc *time.Location) []float64 {
+	steps := []float64{1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 900, 1800, 3600, 7200, 10800, 21600, 43200, 86400, 172800, 604800}
+	span := hi - lo
+	step := steps[len(steps)-1]
+	for _, s := range steps {
+		if span/s <= float64(n) {
+			step = s
+			break
+		}
+	}
+	_, off := time.Unix(int64(lo), 0).In(loc).Zone()
+	var ticks []float64
+	for t := math.Ceil((lo+float64(off))/step)*step - float64(off); t <= hi; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// formatTick formats a numeric tick compactly.
+func formatTick(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	if math.Abs(v) >= 1e6 || math.Abs(v) < 1e-3 {
+		return fmt.Sprintf("%.3g", v)
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+}
+
+// formatTimeTick formats a Unix-seconds tick for a time axis spanning span
+// seconds.
+func formatTimeTick(v, span float64, loc *time.Location) string {
+	t := time.Unix(int64(math.Round(v)), 0).In(loc)
+	if span >= 2*86400 {
+		return t.Format("01-02 15:04")
+	}
+	return t.Format("15:04:05")
+}
@@ -17,6 +17,8 @@ import (
 func main() {
 	bits := flag.Int("bits", 1024, "number of bits to read per batch")
 	interval := flag.Duration("interval", 0, "interval between reads (e.g. 2s). 0 for one-shot")
+	algoFlag := flag.String("algo", string(pseudorng.AlgoChaCha8), "algorithm: chacha8|pcg|legacy")
+	seed := flag.Uint64("seed", 0, "seed; 0 draws a random seed (printed so the run can be replayed)")
 	flag.Parse()
 
 	present, err := pseudorng.Detect()
@@ -27,8 +29,18 @@ func main() {
 		log.Fatal("pseudorng not available")
 	}
 
+	algo, err := pseudorng.ParseAlgorithm(*algoFlag)
+	if err != nil {
+		log.Fatalf("-algo: %v", err)
+	}
+	g, err := pseudorng.NewGeneratorWithAlgorithm(algo, *seed)
+	if err != nil {
+		log.Fatalf("generator: %v", err)
+	}
+	log.Printf("algo=%s seed=%d", g.Algorithm(), g.Seed())
+
 	if *interval == 0 {
-		data, err := pseudorng.ReadBits(*bits)
+		data, err := g.ReadBits(*bits)
 		if err != nil {
 			log.Fatalf("read error: %v", err)
 		}
@@ -40,7 +52,7 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 	log.Printf("reading %d bits every %s. press Ctrl+C to stop...", *bits, interval.String())
-	err = pseudorng.CollectBitsAtInterval(ctx, *bits, *interval, func(b []byte) {
+	err = g.CollectBitsAtInterval(ctx, *bits, *interval, func(b []byte) {
 		fmt.Printf("%s  %d bits  %s\n", time.Now().Format(time.RFC3339), *bits, hex.EncodeToString(b))
 	})
 	if err != nil && !errors.Is(err, context.Canceled) {
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// api serves random values from a pool.
+type api struct {
+	pool     *pool
+	desc     string
+	capacity int
+	// maxBytes bounds /bytes?n= (and /bits?n= at 8 bits per byte).
+	maxBytes int
+	// maxCount bounds count= on /int, /float and /uuid.
+	maxCount int
+	// timeout bounds the wait for the pool when it runs dry.
+	timeout time.Duration
+}
+
+// errBadRequest marks errors in query parameters.
+var errBadRequest = errors.New("bad request")
+
+// handler returns the API's routes:
+//
+//	/bytes?n=32&format=hex   n random bytes as hex, base64 (JSON) or raw
+//	/bits?n=64               n random bits as a string of 0 and 1
+//	/int?min=1&max=6         a uniform integer in [min, max]
+//	/float                   a uniform float64 in [0, 1)
+//	/uuid                    a random (version 4) UUID
+//	/status                  the device and pool state
+//
+// /int, /float and /uuid take count=N for N values in a "values" array
+// instead of a single "value".
+func (a *api) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bytes", a.serve(a.bytes))
+	mux.HandleFunc("/bits", a.serve(a.bits))
+	mux.HandleFunc("/int", a.serve(a.int))
+	mux.HandleFunc("/float", a.serve(a.float))
+	mux.HandleFunc("/uuid", a.serve(a.uuid))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		st := struct {
+			Device       string `json:"device"`
+			PoolBytes    int    `json:"pool_bytes"`
+			PoolCapacity int    `json:"pool_capacity"`
+			DeviceError  string `json:"device_error,omitempty"`
+		}{Device: a.desc, PoolBytes: a.pool.available(), PoolCapacity: a.capacity}
+		if err := a.pool.err(); err != nil {
+			st.DeviceError = err.Error()
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+	return mux
+}
+
+// serve adapts an endpoint returning a JSON value, turning its errors into
+// 400 for bad parameters and 503 when no random data could be had in time.
+func (a *api) serve(h func(ctx context.Context, q query) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+		defer cancel()
+		v, err := h(ctx, query{r.URL.Query()})
+		switch {
+		case errors.Is(err, errBadRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			msg := "random data unavailable"
+			if derr := a.pool.err(); derr != nil {
+				msg += ": " + derr.Error()
+			}
+			http.Error(w, msg, http.StatusServiceUnavailable)
+		default:
+			if raw, ok := v.([]byte); ok {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Header().Set("Cache-Control", "no-store")
+				_, _ = w.Write(raw)
+				return
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, http.StatusOK, v)
+		}
+	}
+}
+
+func (a *api) bytes(ctx context.Context, q query) (any, error) {
+	n, err := q.int("n", 32, 1, int64(a.maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.pool.read(ctx, int(n))
+	if err != nil {
+		return nil, err
+	}
+	switch f := q.Get("format"); f {
+	case "", "hex":
+		return map[string]string{"hex": hex.EncodeToString(b)}, nil
+	case "base64":
+		return map[string]string{"base64": base64.StdEncoding.EncodeToString(b)}, nil
+	case "raw":
+		return b, nil
+	default:
+		return nil, fmt.Errorf("%w: format must be hex, base64 or raw, not %q", errBadRequest, f)
+	}
+}
+
+func (a *api) bits(ctx context.Context, q query) (any, error) {
+	n, err := q.int("n", 64, 1, 8*int64(a.maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.pool.read(ctx, int(n+7)/8)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.Grow(int(n))
+	for i := 0; i < int(n); i++ {
+		sb.WriteByte('0' + b[i/8]>>(7-uint(i%8))&1)
+	}
+	return map[string]string{"bits": sb.String()}, nil
+}
+
+func (a *api) int(ctx context.Context, q query) (any, error) {
+	lo, err := q.int("min", 0, minInt, maxInt)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := q.int("max", 100, minInt, maxInt)
+	if err != nil {
+		return nil, err
+	}
+	if hi < lo {
+		return nil, fmt.Errorf("%w: max must be >= min", errBadRequest)
+	}
+	// span is the number of possible values; 0 stands for 2^64.
+	span := uint64(hi) - uint64(lo) + 1
+	return a.values(ctx, q, func(u func() (uint64, error)) (any, error) {
+		if span == 0 {
+			v, err := u()
+			return int64(v), err
+		}
+		// Reject the lowest 2^64 mod span values so every result is equally
+		// likely.
+		threshold := -span % span
+		for {
+			v, err := u()
+			if err != nil {
+				return nil, err
+			}
+			if v >= threshold {
+				return lo + int64(v%span), nil
+			}
+		}
+	})
+}
+
+func (a *api) float(ctx context.Context, q query) (any, error) {
+	return a.values(ctx, q, func(u func() (uint64, error)) (any, error) {
+		v, err := u()
+		// 53 random bits fill the mantissa exactly.
+		return float64(v>>11) / (1 << 53), err
+	})
+}
+
+func (a *api) uuid(ctx context.Context, q query) (any, error) {
+	return a.values(ctx, q, func(u func() (uint64, error)) (any, error) {
+		var b [16]byte
+		for i := 0; i < 16; i += 8 {
+			v, err := u()
+			if err != nil {
+				return nil, err
+			}
+			binary.BigEndian.PutUint64(b[i:], v)
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		h := hex.EncodeToString(b[:])
+		return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+	})
+}
+
+// values calls gen once, or count times if count= is given, with a source
+// of uniform 64-bit values, and returns {"value": v} or {"values": [...]}.
+func (a *api) values(ctx context.Context, q query, gen func(u func() (uint64, error)) (any, error)) (any, error) {
+	count, err := q.int("count", 1, 1, int64(a.maxCount))
+	if err != nil {
+		return nil, err
+	}
+	// Read enough for the usual case up front; rejections read more.
+	var buf []byte
+	u := func() (uint64, error) {
+		if len(buf) < 8 {
+			b, err := a.pool.read(ctx, 8*int(count))
+			if err != nil {
+				return 0, err
+			}
+			buf = b
+		}
+		v := binary.BigEndian.Uint64(buf)
+		buf = buf[8:]
+		return v, nil
+	}
+	if !q.Has("count") {
+		v, err := gen(u)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"value": v}, nil
+	}
+	vs := make([]any, count)
+	for i := range vs {
+		if vs[i], err = gen(u); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]any{"values": vs}, nil
+}
+
+const (
+	minInt = -1 << 63
+	maxInt = 1<<63 - 1
+)
+
+// query reads typed query parameters.
+type query struct {
+	values map[string][]string
+}
+
+// Get returns the first value of name, or "".
+func (q query) Get(name string) string {
+	if v := q.values[name]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Has reports whether name was given.
+func (q query) Has(name string) bool {
+	_, ok := q.values[name]
+	return ok
+}
+
+// int parses name as an integer in [lo, hi], defaulting to def.
+func (q query) int(name string, def, lo, hi int64) (int64, error) {
+	s := q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("%w: %s must be an integer in [%d, %d]", errBadRequest, name, lo, hi)
+	}
+	return v, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// withLimit rejects requests beyond the client's rate with 429.
+func withLimit(l *limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(clientIP(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withLog logs every request: client, method, URL, status, response size
+// and duration.
+func withLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %s %d %dB %s", clientIP(r), r.Method, r.URL.RequestURI(), rec.status, rec.size, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// statusRecorder captures the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Thiagojm/rng_go_cli/device"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/pseudorng"
+)
+
+// errShortRead is returned when the device delivers no bytes.
+var errShortRead = errors.New("device returned no data")
+
+// openSource opens dev. trngRules optionally names extra TrueRNG match
+// rules; seed seeds the pseudo generator (0 draws one). The pseudo
+// generator's seed is left out of the description, which /status serves.
+func openSource(dev naming.Device, trngRules string, seed uint64) (*device.Source, error) {
+	src, err := device.Open(dev, device.Options{TrueRNGRules: trngRules, Seed: seed})
+	if err != nil {
+		return nil, err
+	}
+	if g, ok := src.ReadCloser.(*pseudorng.Generator); ok {
+		src.Desc = fmt.Sprintf("pseudo (%s, not for production use)", g.Algorithm())
+	}
+	return src, nil
+}
+
+// readFunc adapts src to the pool: each call reads n bytes.
+func readFunc(src io.Reader) func(ctx context.Context, n int) ([]byte, error) {
+	return func(ctx context.Context, n int) ([]byte, error) {
+		buf := make([]byte, n)
+		k, err := io.ReadFull(src, buf)
+		if k > 0 && errors.Is(err, io.ErrUnexpectedEOF) {
+			err = nil
+		}
+		return buf[:k], err
+	}
+}
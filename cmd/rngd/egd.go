@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+)
+
+// egdSource serves the pool to EGD clients.
+type egdSource struct {
+	p *pool
+}
+
+func (s egdSource) Available() int {
+	return s.p.available()
+}
+
+func (s egdSource) Read(ctx context.Context, n int) ([]byte, error) {
+	return s.p.read(ctx, n)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/kernelfeed"
+)
+
+// kernelOptions are the kernel feeder flags.
+type kernelOptions struct {
+	fake    bool
+	credit  float64
+	low     int
+	refresh time.Duration
+}
+
+// fakeCapacity and fakeDrain shape the -kernel-fake pool after a pre-5.18
+// Linux pool: 4096 bits, drained at a modest rate.
+const (
+	fakeCapacity = 4096
+	fakeDrain    = 512
+)
+
+// startKernelFeed feeds the kernel's entropy pool (or a fake one) from p
+// until ctx is done. It calls stop if the feeder fails, and logs its
+// counts every ten minutes and when it ends, after which done is closed.
+func startKernelFeed(ctx context.Context, p *pool, opts kernelOptions, stop func()) (done <-chan struct{}, err error) {
+	var sink kernelfeed.Sink
+	low := opts.low
+	if opts.fake {
+		sink = kernelfeed.NewFake(fakeCapacity, fakeDrain)
+		if low <= 0 {
+			low = fakeCapacity / 2
+		}
+		log.Printf("kernel feed: using an in-memory pool (%d bits, drained at %d bits/s)", fakeCapacity, fakeDrain)
+	} else {
+		k, err := kernelfeed.OpenKernel("/dev/random")
+		if err != nil {
+			return nil, err
+		}
+		if low <= 0 {
+			if low, err = k.WakeupThreshold(); err != nil {
+				k.Close()
+				return nil, err
+			}
+		}
+		sink = k
+	}
+	f := &kernelfeed.Feeder{
+		Sink:     sink,
+		Read:     p.read,
+		Credit:   opts.credit,
+		LowWater: low,
+		Refresh:  opts.refresh,
+	}
+	log.Printf("kernel feed: adding SHA-256-conditioned data below %d bits, crediting %g bits per byte", low, opts.credit)
+
+	report := func() {
+		s := f.Stats()
+		level, _ := sink.EntropyAvail()
+		log.Printf("kernel feed: %d writes, %d bytes, %d bits credited, %d blocks discarded; pool at %d bits",
+			s.Writes, s.Bytes, s.Credited, s.Discarded, level)
+	}
+	go func() {
+		t := time.NewTicker(10 * time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				report()
+			}
+		}
+	}()
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		if err := f.Run(ctx); err != nil {
+			log.Printf("kernel feed: %v", err)
+			stop()
+		}
+		report()
+		if k, ok := sink.(*kernelfeed.Kernel); ok {
+			k.Close()
+		}
+	}()
+	return ch, nil
+}
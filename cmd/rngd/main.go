@@ -0,0 +1,130 @@
+// Command rngd serves random numbers from a TrueRNG, BitBabbler or the
+// pseudo generator over HTTP, so other services can use the hardware
+// attached to one host. Bytes are read ahead into a pool and each is served
+// once; clients are rate limited by IP address and every request is logged.
+// With -egd the pool is also served over the Entropy Gathering Daemon
+// protocol, for OpenSSL, GnuPG and other EGD clients, and with -kernel it
+// feeds the Linux kernel's entropy pool, replacing rng-tools.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/egd"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8700", `HTTP listen address; "" disables HTTP`)
+	deviceFlag := flag.String("device", "trng", "device to read from: trng|bitb|pseudo")
+	trngRules := flag.String("trng-rules", "", "optional JSON file with extra TrueRNG match rules")
+	seedFlag := flag.Uint64("seed", 0, "pseudo seed; 0 draws a random seed")
+	poolSize := flag.Int("pool", 1<<20, "bytes to keep read ahead from the device")
+	chunk := flag.Int("chunk", 4096, "bytes per device read")
+	maxBytes := flag.Int("max-bytes", 1<<16, "largest /bytes request (and /bits, in bytes)")
+	maxCount := flag.Int("max-count", 1000, "largest count= on /int, /float and /uuid")
+	rate := flag.Float64("rate", 10, "requests per second allowed per client IP on average; 0 disables the limit")
+	burst := flag.Int("burst", 20, "requests a client may make at once")
+	egdAddr := flag.String("egd", "", "also serve EGD on a Unix socket or TCP address, e.g. unix:/var/run/egd-pool or tcp:127.0.0.1:8701")
+	kernelFlag := flag.Bool("kernel", false, "feed the Linux kernel entropy pool through /dev/random (needs root)")
+	kernelFake := flag.Bool("kernel-fake", false, "feed an in-memory pool instead of the kernel's, to try -kernel without root")
+	kernelCredit := flag.Float64("kernel-credit", 4, "entropy credited per conditioned byte, in bits (0-8)")
+	kernelLow := flag.Int("kernel-low", 0, "feed while the kernel's estimate is below this many bits (0 = its write_wakeup_threshold)")
+	kernelRefresh := flag.Duration("kernel-refresh", time.Minute, "feed at least this often even when the pool is not low (0 = only when low)")
+	timeout := flag.Duration("timeout", 10*time.Second, "how long a request waits for random data before failing with 503")
+	flag.Parse()
+
+	var dev naming.Device
+	switch *deviceFlag {
+	case string(naming.DevicePseudo), string(naming.DeviceTrueRNG), string(naming.DeviceBitBabbler):
+		dev = naming.Device(*deviceFlag)
+	default:
+		log.Fatalf("invalid -device: %s (allowed: trng, bitb, pseudo)", *deviceFlag)
+	}
+	if *poolSize <= 0 || *chunk <= 0 || *maxBytes <= 0 || *maxCount <= 0 {
+		log.Fatal("-pool, -chunk, -max-bytes and -max-count must be > 0")
+	}
+	if *rate < 0 {
+		log.Fatal("-rate must be >= 0")
+	}
+	if *kernelCredit < 0 || *kernelCredit > 8 {
+		log.Fatal("-kernel-credit must be 0-8")
+	}
+	if *addr == "" && *egdAddr == "" && !*kernelFlag && !*kernelFake {
+		log.Fatal(`nothing to serve: -addr is "" and neither -egd nor -kernel is set`)
+	}
+
+	src, err := openSource(dev, *trngRules, *seedFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &api{
+		pool:     newPool(ctx, readFunc(src), *poolSize, *chunk),
+		desc:     src.Desc,
+		capacity: max(1, *poolSize / *chunk) * *chunk,
+		maxBytes: *maxBytes,
+		maxCount: *maxCount,
+		timeout:  *timeout,
+	}
+	log.Printf("device: %s", src.Desc)
+
+	if *kernelFlag || *kernelFake {
+		opts := kernelOptions{fake: *kernelFake, credit: *kernelCredit, low: *kernelLow, refresh: *kernelRefresh}
+		done, err := startKernelFeed(ctx, a.pool, opts, stop)
+		if err != nil {
+			log.Fatalf("kernel feed: %v", err)
+		}
+		defer func() { <-done }()
+	}
+
+	if *egdAddr != "" {
+		eln, err := egd.Listen(*egdAddr)
+		if err != nil {
+			log.Fatalf("egd: %v", err)
+		}
+		es := &egd.Server{Source: egdSource{a.pool}, IdleTimeout: 10 * time.Minute}
+		go func() {
+			if err := es.Serve(eln); err != nil {
+				log.Printf("egd: %v", err)
+			}
+		}()
+		defer es.Close()
+		log.Printf("serving EGD at %s", eln.Addr())
+	}
+
+	if *addr == "" {
+		<-ctx.Done()
+		return
+	}
+	srv := &http.Server{
+		Handler:           withLog(withLimit(newLimiter(*rate, *burst), a.handler())),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving HTTP at http://%s/", ln.Addr())
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// pool keeps random bytes read ahead from the device so requests are served
+// without waiting for it. A filler goroutine reads chunks into a buffered
+// channel whenever it has room; every byte is handed out once.
+type pool struct {
+	chunks chan []byte
+	chunk  int
+
+	mu   sync.Mutex
+	left []byte // unused tail of the last chunk taken
+
+	// lastErr is the device's latest error, nil while it works.
+	failMu  sync.Mutex
+	lastErr error
+}
+
+// newPool returns a pool holding up to size bytes in chunks of chunk bytes,
+// filled from read until ctx is cancelled.
+func newPool(ctx context.Context, read func(ctx context.Context, n int) ([]byte, error), size, chunk int) *pool {
+	n := size / chunk
+	if n < 1 {
+		n = 1
+	}
+	p := &pool{chunks: make(chan []byte, n), chunk: chunk}
+	go p.fill(ctx, read)
+	return p
+}
+
+// fill reads chunks until ctx is cancelled, backing off while the device
+// fails.
+func (p *pool) fill(ctx context.Context, read func(ctx context.Context, n int) ([]byte, error)) {
+	backoff := time.Second
+	for {
+		b, err := read(ctx, p.chunk)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && len(b) == 0 {
+			err = errShortRead
+		}
+		p.failMu.Lock()
+		wasFailing := p.lastErr != nil
+		p.lastErr = err
+		p.failMu.Unlock()
+		if err != nil {
+			log.Printf("device read: %v; retrying in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, 30*time.Second)
+			continue
+		}
+		if wasFailing {
+			log.Print("device read: recovered")
+		}
+		backoff = time.Second
+		select {
+		case p.chunks <- b:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// read returns n random bytes, waiting for the device if the pool runs dry.
+// Concurrent reads are served one at a time, so each gets contiguous bytes.
+func (p *pool) read(ctx context.Context, n int) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if len(p.left) == 0 {
+			select {
+			case <-ctx.Done():
+				// Keep what was taken for the next request.
+				p.left = out
+				return nil, ctx.Err()
+			case p.left = <-p.chunks:
+			}
+		}
+		k := copy(out[len(out):n], p.left)
+		out = out[:len(out)+k]
+		p.left = p.left[k:]
+	}
+	return out, nil
+}
+
+// available returns the number of bytes ready to serve.
+func (p *pool) available() int {
+	n := len(p.chunks) * p.chunk
+	if p.mu.TryLock() {
+		n += len(p.left)
+		p.mu.Unlock()
+	}
+	return n
+}
+
+// err returns the device's latest error, or nil while it is working.
+func (p *pool) err() error {
+	p.failMu.Lock()
+	defer p.failMu.Unlock()
+	return p.lastErr
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket per client: each client may make burst
+// requests at once and rate requests per second on average.
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter; a rate of 0 disables it.
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{rate: rate, burst: math.Max(1, float64(burst)), buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token for client. If none is left it returns false and how
+// long until one is.
+func (l *limiter) allow(client string, now time.Time) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b := l.buckets[client]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops, at most once a minute, the buckets that have refilled, since
+// a new bucket starts full anyway. l.mu must be held.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
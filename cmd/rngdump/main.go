@@ -0,0 +1,200 @@
+// Command rngdump streams raw bytes from a TrueRNG, BitBabbler or the pseudo
+// generator to stdout or a file as fast as the device delivers them, for
+// piping into test suites:
+//
+//	rngdump -device bitb -infinite | dieharder -a -g 200
+//	rngdump -device trng -infinite | RNG_test stdin8
+//	rngdump -device trng -bytes 10M -o sample.bin && ent sample.bin
+//
+// Throughput is reported on stderr, so stdout carries only the data.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/device"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/pseudorng"
+)
+
+func main() {
+	deviceFlag := flag.String("device", "trng", "device to read from: trng|bitb|pseudo")
+	bytesFlag := flag.String("bytes", "", "bytes to write, with an optional K, M, G or T suffix (powers of 1024)")
+	infinite := flag.Bool("infinite", false, "write until interrupted or the reader goes away")
+	out := flag.String("o", "-", `output file; "-" is stdout`)
+	block := flag.Int("block", 64*1024, "bytes per device read")
+	trngRules := flag.String("trng-rules", "", "optional JSON file with extra TrueRNG match rules")
+	algoFlag := flag.String("algo", string(pseudorng.AlgoChaCha8), "pseudo algorithm: chacha8|pcg|legacy")
+	seedFlag := flag.Uint64("seed", 0, "pseudo seed; 0 draws a random seed (logged so the stream can be replayed)")
+	progress := flag.Duration("progress", 5*time.Second, "how often to report throughput on stderr (0 = only at the end)")
+	flag.Parse()
+
+	var dev naming.Device
+	switch *deviceFlag {
+	case string(naming.DevicePseudo), string(naming.DeviceTrueRNG), string(naming.DeviceBitBabbler):
+		dev = naming.Device(*deviceFlag)
+	default:
+		log.Fatalf("invalid -device: %s (allowed: trng, bitb, pseudo)", *deviceFlag)
+	}
+	algo, err := pseudorng.ParseAlgorithm(*algoFlag)
+	if err != nil {
+		log.Fatalf("invalid -algo: %v", err)
+	}
+	var limit int64 = -1
+	switch {
+	case *infinite && *bytesFlag != "":
+		log.Fatal("use either -bytes or -infinite, not both")
+	case *bytesFlag != "":
+		if limit, err = parseSize(*bytesFlag); err != nil || limit <= 0 {
+			log.Fatalf("invalid -bytes: %q (e.g. 4096, 10M, 1G)", *bytesFlag)
+		}
+	case !*infinite:
+		log.Fatal("set -bytes N or -infinite")
+	}
+	if *block <= 0 {
+		log.Fatal("-block must be > 0")
+	}
+
+	src, err := device.Open(dev, device.Options{TrueRNGRules: *trngRules, Algorithm: algo, Seed: *seedFlag})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+
+	var w io.Writer = os.Stdout
+	name := "stdout"
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w, name = f, *out
+	}
+
+	// Report a closed pipe (the test suite has read enough) as an error from
+	// Write instead of being killed by SIGPIPE.
+	signal.Ignore(syscall.SIGPIPE)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("device: %s", src.Desc)
+	log.Printf("writing %s to %s", describeLimit(limit), name)
+	d := &dumper{src: src, w: w, buf: make([]byte, *block), progress: *progress}
+	err = d.run(ctx, limit)
+	log.Printf("wrote %s in %s (%s/s)", formatBytes(float64(d.written)), d.elapsed().Round(time.Millisecond), formatBytes(d.rate()))
+	switch {
+	case err == nil || ctx.Err() != nil:
+	case errors.Is(err, syscall.EPIPE):
+		log.Print("output closed by reader")
+	default:
+		log.Fatal(err)
+	}
+}
+
+// dumper copies from a source to a writer, reporting throughput.
+type dumper struct {
+	src      io.Reader
+	w        io.Writer
+	buf      []byte
+	progress time.Duration
+
+	start   time.Time
+	written int64
+}
+
+// run copies limit bytes, or until ctx is done if limit is negative.
+func (d *dumper) run(ctx context.Context, limit int64) error {
+	d.start = time.Now()
+	lastReport, lastWritten := d.start, int64(0)
+	for ctx.Err() == nil && (limit < 0 || d.written < limit) {
+		p := d.buf
+		if limit >= 0 && limit-d.written < int64(len(p)) {
+			p = p[:limit-d.written]
+		}
+		n, rerr := d.src.Read(p)
+		if n > 0 {
+			if _, err := d.w.Write(p[:n]); err != nil {
+				return err
+			}
+			d.written += int64(n)
+		}
+		if rerr != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("read: %w", rerr)
+		}
+		if now := time.Now(); d.progress > 0 && now.Sub(lastReport) >= d.progress {
+			rate := float64(d.written-lastWritten) / now.Sub(lastReport).Seconds()
+			log.Printf("%s written, %s/s (average %s/s)", formatBytes(float64(d.written)), formatBytes(rate), formatBytes(d.rate()))
+			lastReport, lastWritten = now, d.written
+		}
+	}
+	return nil
+}
+
+func (d *dumper) elapsed() time.Duration { return time.Since(d.start) }
+
+// rate returns the average throughput in bytes per second.
+func (d *dumper) rate() float64 {
+	s := d.elapsed().Seconds()
+	if s <= 0 {
+		return 0
+	}
+	return float64(d.written) / s
+}
+
+// parseSize parses a byte count such as "4096", "10M" or "1G"; suffixes are
+// powers of 1024 and an optional trailing "B" or "iB" is accepted.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > (1<<63-1)/mult {
+		return 0, errors.New("size out of range")
+	}
+	return n * mult, nil
+}
+
+func describeLimit(limit int64) string {
+	if limit < 0 {
+		return "until interrupted"
+	}
+	return fmt.Sprintf("%d bytes", limit)
+}
+
+// formatBytes formats n bytes with a binary unit.
+func formatBytes(n float64) string {
+	for _, unit := range []string{"B", "KiB", "MiB", "GiB"} {
+		if n < 1024 || unit == "GiB" {
+			if unit == "B" {
+				return fmt.Sprintf("%.0f %s", n, unit)
+			}
+			return fmt.Sprintf("%.2f %s", n, unit)
+		}
+		n /= 1024
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+// Command rngent prints ent-style quality statistics for captures: Shannon
+// entropy, optimum compression, chi-square with its p-value, arithmetic
+// mean, a Monte Carlo estimate of pi and the serial correlation coefficient.
+// It is a quick sanity check before running dieharder or PractRand.
+//
+// Usage:
+//
+//	rngent [flags] file.bin ...
+//	rngdump -device trng -bytes 1M | rngent
+//
+// With no files, or "-", it reads stdin. The sample size of a capture named
+// by the naming convention is taken from its name, so the zero padding of
+// samples that are not a whole number of bytes is skipped.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/Thiagojm/rng_go_cli/ent"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// fileResult is the result for one input.
+type fileResult struct {
+	File       string `json:"file"`
+	SampleBits int    `json:"sample_bits,omitempty"`
+	ent.Result
+}
+
+func main() {
+	bitMode := flag.Bool("b", false, "analyse bits rather than bytes, like ent -b")
+	format := flag.String("format", "text", "output format: text|csv|json")
+	out := flag.String("o", "-", `output file; "-" is stdout`)
+	sampleBits := flag.Int("bits", 0, "sample size in bits, overriding the one in the file name (0 = from the name)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: rngent [flags] [file.bin ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch *format {
+	case "text", "csv", "json":
+	default:
+		log.Fatalf("invalid -format: %s (allowed: text, csv, json)", *format)
+	}
+	if *sampleBits < 0 {
+		log.Fatal("-bits must be >= 0")
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var results []fileResult
+	failed := false
+	for _, path := range files {
+		r, err := analyzeFile(path, *bitMode, *sampleBits)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			failed = true
+			continue
+		}
+		results = append(results, r)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	var err error
+	switch *format {
+	case "text":
+		err = writeText(bw, results)
+	case "csv":
+		err = writeCSV(bw, results)
+	case "json":
+		err = writeJSON(bw, results)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// analyzeFile analyses path, or stdin for "-". sampleBits overrides the
+// sample size from the file name.
+func analyzeFile(path string, bits bool, sampleBits int) (fileResult, error) {
+	var r io.Reader = os.Stdin
+	name := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fileResult{}, err
+		}
+		defer f.Close()
+		r, name = f, path
+		if sampleBits == 0 {
+			if info, err := naming.ParseBaseName(path); err == nil {
+				sampleBits = info.Bits
+			}
+		}
+	}
+	a := ent.New(ent.Options{Bits: bits, SampleBits: sampleBits})
+	if _, err := io.Copy(a, bufio.NewReaderSize(r, 1<<16)); err != nil {
+		return fileResult{}, err
+	}
+	res, err := a.Result()
+	if err != nil {
+		return fileResult{}, err
+	}
+	return fileResult{File: name, SampleBits: sampleBits, Result: res}, nil
+}
+
+// writeText prints each result in ent's wording.
+func writeText(w io.Writer, results []fileResult) error {
+	for i, r := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if len(results) > 1 {
+			fmt.Fprintf(w, "File: %s\n\n", r.File)
+		}
+		unit, random := "byte", "127.5"
+		if r.Mode == ent.ModeBit {
+			unit, random = "bit", "0.5"
+		}
+		fmt.Fprintf(w, "Entropy = %f bits per %s.\n\n", r.Entropy, unit)
+		fmt.Fprintf(w, "Optimum compression would reduce the size\nof this %d %s file by %.0f percent.\n\n", r.Count, unit, math.Floor(r.Compression))
+		fmt.Fprintf(w, "Chi square distribution for %d samples is %.2f, and randomly\nwould exceed this value %s of the times.\n\n", r.Count, r.ChiSquare, chiPercent(r.ChiSquareP))
+		fmt.Fprintf(w, "Arithmetic mean value of data %ss is %.4f (%s = random).\n", unit, r.Mean, random)
+		if r.MonteCarloPi != nil {
+			fmt.Fprintf(w, "Monte Carlo value for Pi is %.9f (error %.2f percent).\n", *r.MonteCarloPi, *r.MonteCarloError)
+		} else {
+			fmt.Fprintln(w, "Monte Carlo value for Pi is undefined (fewer than 6 bytes).")
+		}
+		if r.SerialCorrelation != nil {
+			fmt.Fprintf(w, "Serial correlation coefficient is %f (totally uncorrelated = 0.0).\n", *r.SerialCorrelation)
+		} else {
+			fmt.Fprintln(w, "Serial correlation coefficient is undefined (all values equal!).")
+		}
+	}
+	return nil
+}
+
+// chiPercent formats the chi-square p-value as ent does.
+func chiPercent(p float64) string {
+	switch pct := 100 * p; {
+	case pct < 0.01:
+		return "less than 0.01 percent"
+	case pct > 99.99:
+		return "more than 99.99 percent"
+	default:
+		return fmt.Sprintf("%.2f percent", pct)
+	}
+}
+
+func writeCSV(w io.Writer, results []fileResult) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"file", "mode", "count", "entropy", "compression_percent", "chi_square", "chi_square_p", "mean", "monte_carlo_pi", "monte_carlo_error_percent", "serial_correlation"})
+	for _, r := range results {
+		_ = cw.Write([]string{
+			r.File, r.Mode, strconv.FormatInt(r.Count, 10),
+			formatFloat(r.Entropy), formatFloat(r.Compression),
+			formatFloat(r.ChiSquare), formatFloat(r.ChiSquareP), formatFloat(r.Mean),
+			formatOptional(r.MonteCarloPi), formatOptional(r.MonteCarloError),
+			formatOptional(r.SerialCorrelation),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, results []fileResult) error {
+	if results == nil {
+		results = []fileResult{}
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatOptional formats v, or "" when it is undefined.
+func formatOptional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
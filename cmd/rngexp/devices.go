@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/Thiagojm/rng_go_cli/device"
+	"github.com/Thiagojm/rng_go_cli/pseudorng"
+)
+
+// intn returns a uniform integer in [0, n) drawn from src, rejecting 32-bit
+// values above the largest multiple of n to avoid modulo bias.
+func intn(src *device.Source, n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("intn: n must be > 0")
+	}
+	limit := (1 << 32) / uint64(n) * uint64(n)
+	for {
+		b, err := src.ReadBits(32)
+		if err != nil {
+			return 0, err
+		}
+		if v := uint64(binary.BigEndian.Uint32(b)); v < limit {
+			return int(v % uint64(n)), nil
+		}
+	}
+}
+
+// pseudoSeed returns the seed of src if it is the pseudo generator, so the
+// session can be replayed, and 0 otherwise.
+func pseudoSeed(src *device.Source) uint64 {
+	if g, ok := src.ReadCloser.(*pseudorng.Generator); ok {
+		return g.Seed()
+	}
+	return 0
+}
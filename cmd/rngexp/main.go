@@ -0,0 +1,290 @@
+// Command rngexp runs an RNG intention experiment described by a protocol
+// file: it draws the order of the aims from the RNG, prompts the participant
+// before each run, records every trial with its aim and reports per-run,
+// per-aim and directional statistics for the session.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/device"
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] protocol.json\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	deviceFlag := flag.String("device", "", "override the protocol's device: trng|bitb|pseudo")
+	outDir := flag.String("outdir", "data", "output directory for files")
+	trngRules := flag.String("trng-rules", "", "optional JSON file with extra TrueRNG match rules")
+	seedFlag := flag.Uint64("seed", 0, "pseudo seed; 0 draws a random seed (recorded in the .session.json file)")
+	participant := flag.String("participant", "", "participant identifier recorded in the .session.json file")
+	dryRun := flag.Bool("dry-run", false, "print the drawn schedule and exit without running trials")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	protoPath := flag.Arg(0)
+
+	p, err := loadProtocol(protoPath)
+	if err != nil {
+		log.Fatalf("protocol: %v", err)
+	}
+	if *deviceFlag != "" {
+		p.Device = *deviceFlag
+	}
+	if err := p.validate(); err != nil {
+		log.Fatalf("protocol %s: %v", protoPath, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	src, err := device.Open(naming.Device(p.Device), device.Options{TrueRNGRules: *trngRules, Seed: *seedFlag})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+	log.Printf("device: %s", src.Desc)
+
+	// The aim order comes from the same device as the trials.
+	sched, err := p.schedule(func(n int) (int, error) { return intn(src, n) })
+	if err != nil {
+		log.Fatalf("schedule: %v", err)
+	}
+	printSchedule(p, sched)
+	if *dryRun {
+		return
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("creating outdir: %v", err)
+	}
+	start := time.Now()
+	binPath, csvPath, err := naming.BuildBinCSVPaths(*outDir, start, naming.Device(p.Device), p.Bits, p.IntervalSeconds)
+	if err != nil {
+		log.Fatalf("build filenames: %v", err)
+	}
+	rec, err := newRecorder(binPath, csvPath, p.Bits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Close()
+
+	sess := sessionRecord{
+		Protocol:     p,
+		ProtocolFile: protoPath,
+		Participant:  *participant,
+		Source:       src.Desc,
+		Seed:         pseudoSeed(src),
+		Start:        start,
+		Capture:      filepath.Base(binPath),
+		Schedule:     sched,
+	}
+	// The session file is written however the session ends.
+	sessionPath := naming.SidecarPath(binPath, "session.json")
+	defer func() {
+		sess.End = time.Now()
+		sess.Stats = summarize(sess.Runs, rec.trials, p.Bits)
+		if err := writeJSON(sessionPath, sess); err != nil {
+			log.Printf("write session: %v", err)
+			return
+		}
+		printStats(sess)
+		log.Printf("session written to %s", sessionPath)
+	}()
+
+	enter := readLines(os.Stdin)
+	var runErr error
+	for _, pr := range sched {
+		b := p.Blocks[pr.Block]
+		if runErr = prepareRun(ctx, p, pr, len(sched), enter); runErr != nil {
+			break
+		}
+		res, err := runTrials(ctx, p, pr, src, rec)
+		sess.Runs = append(sess.Runs, res)
+		if err != nil {
+			runErr = err
+			break
+		}
+		if !b.Feedback {
+			fmt.Printf("Run %d done.\n", pr.Number)
+		}
+	}
+	switch {
+	case runErr == nil:
+		sess.Completed = true
+		fmt.Println("Session complete. Thank you.")
+	case errors.Is(runErr, context.Canceled):
+		log.Print("session interrupted")
+	default:
+		log.Printf("session stopped: %v", runErr)
+	}
+}
+
+// blockName names a protocol block for output.
+func blockName(p *protocol, i int) string {
+	if p.Blocks[i].Name != "" {
+		return p.Blocks[i].Name
+	}
+	return fmt.Sprintf("block %d", i+1)
+}
+
+// printSchedule logs the aims drawn for each block.
+func printSchedule(p *protocol, sched []plannedRun) {
+	byBlock := make([][]string, len(p.Blocks))
+	for _, r := range sched {
+		byBlock[r.Block] = append(byBlock[r.Block], r.Aim)
+	}
+	for i, b := range p.Blocks {
+		order := b.Order
+		if order == "" {
+			order = orderAlternate
+		}
+		log.Printf("%s (%s, %d trials of %d bits per run): %s",
+			blockName(p, i), order, b.Trials, p.Bits, strings.Join(byBlock[i], " "))
+	}
+}
+
+// readLines sends a value for every line read from r and closes the channel
+// at end of input.
+func readLines(r *os.File) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			ch <- struct{}{}
+		}
+	}()
+	return ch
+}
+
+// prepareRun rests, then shows the run's prompt and, if the block asks for
+// it, waits for Enter. Without input to read, it does not wait.
+func prepareRun(ctx context.Context, p *protocol, pr plannedRun, total int, enter <-chan struct{}) error {
+	b := p.Blocks[pr.Block]
+	if rest := time.Duration(b.Rest); rest > 0 {
+		fmt.Printf("\nRest for %s...\n", rest)
+		t := time.NewTimer(rest)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	fmt.Printf("\nRun %d of %d (%s): %s\n", pr.Number, total, blockName(p, pr.Block), strings.ToUpper(pr.Aim))
+	if prompt := p.Aims[pr.Aim].Prompt; prompt != "" {
+		fmt.Println(prompt)
+	}
+	if !b.WaitForKey {
+		return nil
+	}
+	// Ignore Enter presses made before the prompt.
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-enter:
+			if !ok {
+				return nil
+			}
+		default:
+			drained = true
+		}
+	}
+	fmt.Print("Press Enter to start.")
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-enter:
+	}
+	return nil
+}
+
+// runTrials collects the trials of one run, one every IntervalSeconds, and
+// records them. The run is returned even when it is cut short.
+func runTrials(ctx context.Context, p *protocol, pr plannedRun, src *device.Source, rec *recorder) (runResult, error) {
+	b := p.Blocks[pr.Block]
+	res := runResult{
+		Run:       pr.Number,
+		Block:     blockName(p, pr.Block),
+		Aim:       pr.Aim,
+		Direction: p.Aims[pr.Aim].Direction,
+		Start:     time.Now(),
+	}
+	if _, err := rec.evlog.Add(events.Start, pr.Aim); err != nil {
+		log.Printf("event: %v", err)
+	}
+	defer func() {
+		if _, err := rec.evlog.Add(events.Stop, pr.Aim); err != nil {
+			log.Printf("event: %v", err)
+		}
+	}()
+
+	ticker := time.NewTicker(time.Duration(p.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for n := 1; n <= b.Trials; n++ {
+		if n > 1 {
+			select {
+			case <-ctx.Done():
+				res.End = time.Now()
+				res.finish(p.Bits)
+				return res, ctx.Err()
+			case <-ticker.C:
+			}
+		}
+		batch, err := src.ReadBits(p.Bits)
+		if err == nil {
+			ones := bitpack.OnesCount(batch, p.Bits)
+			_, err = rec.add(res, n, batch, ones)
+			res.add(ones)
+			if err == nil && b.Feedback {
+				fmt.Printf("  trial %d: %d/%d ones, z=%+.2f\n", n, ones, p.Bits, trialZ(ones, p.Bits))
+			}
+		}
+		if err != nil {
+			res.End = time.Now()
+			res.finish(p.Bits)
+			return res, err
+		}
+	}
+	res.End = time.Now()
+	res.Completed = true
+	res.finish(p.Bits)
+	if b.Feedback {
+		fmt.Printf("Run %d: z=%+.2f over %d trials\n", res.Run, res.Z, res.Trials)
+	}
+	return res, nil
+}
+
+// printStats prints the session statistics.
+func printStats(s sessionRecord) {
+	st := s.Stats
+	fmt.Printf("\n%-12s %5s %7s %10s %8s %8s\n", "aim", "runs", "trials", "mean ones", "z", "p")
+	for _, a := range st.Aims {
+		fmt.Printf("%-12s %5d %7d %10.3f %+8.3f %8.4f\n", a.Aim, a.Runs, a.Trials, a.MeanOnes, a.Z, a.P)
+	}
+	fmt.Printf("%-12s %5s %7d %10.3f %+8.3f %8.4f\n", "all", "", st.Overall.Trials, st.Overall.MeanOnes, st.Overall.Z, st.Overall.P)
+	if d := st.Directional; d != nil {
+		fmt.Printf("directional (in the intended direction): %d trials, z=%+.3f, one-sided p=%.4f\n", d.Trials, d.Z, d.P)
+	}
+	if !s.Completed {
+		fmt.Println("(session incomplete: statistics cover completed runs only)")
+	}
+}
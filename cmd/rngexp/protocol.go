@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// protocol is a declarative session description, read from a JSON file.
+type protocol struct {
+	Name string `json:"name"`
+	// Device is the source of both the trials and the randomised aim order:
+	// trng, bitb or pseudo. The -device flag overrides it.
+	Device string `json:"device"`
+	// Bits is the trial length: the bits drawn and counted per trial.
+	Bits int `json:"bits"`
+	// IntervalSeconds is the time between trials.
+	IntervalSeconds int `json:"interval_seconds"`
+	// Aims defines the intentions that blocks refer to by name.
+	Aims map[string]aim `json:"aims"`
+	// Blocks run in order.
+	Blocks []block `json:"blocks"`
+}
+
+// aim is one intention condition.
+type aim struct {
+	// Prompt is shown to the participant before each run with this aim.
+	Prompt string `json:"prompt"`
+	// Direction is +1 if the aim is for more ones than chance, -1 for fewer
+	// and 0 for a baseline without intention.
+	Direction int `json:"direction"`
+}
+
+// block is a group of runs sharing the trial count and the aims drawn from.
+type block struct {
+	Name string `json:"name"`
+	// Runs is the number of runs; each run has one aim.
+	Runs int `json:"runs"`
+	// Trials is the number of trials per run.
+	Trials int `json:"trials"`
+	// Aims lists the aims of the block's runs.
+	Aims []string `json:"aims"`
+	// Order assigns aims to runs:
+	//   - "alternate" (default) cycles through Aims in order;
+	//   - "random" shuffles a balanced sequence (each aim equally often,
+	//     any remainder drawn at random) using bits from the device;
+	//   - "random-each" draws every run's aim independently from the device.
+	Order string `json:"order"`
+	// Rest is the pause before each run, e.g. "30s".
+	Rest duration `json:"rest"`
+	// WaitForKey makes each run start only when the participant presses
+	// Enter, after the rest.
+	WaitForKey bool `json:"wait_for_key"`
+	// Feedback prints every trial's result as it happens and the run's score
+	// at its end; otherwise the participant is only told the run is over.
+	Feedback bool `json:"feedback"`
+}
+
+// Aim orders accepted in block.Order.
+const (
+	orderAlternate  = "alternate"
+	orderRandom     = "random"
+	orderRandomEach = "random-each"
+)
+
+// duration is a time.Duration written as a string such as "30s" in JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// loadProtocol reads a protocol file, defaulting IntervalSeconds to 1. It
+// is checked with validate once flags are applied.
+func loadProtocol(path string) (*protocol, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p protocol
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.IntervalSeconds == 0 {
+		p.IntervalSeconds = 1
+	}
+	return &p, nil
+}
+
+// validate checks p once command-line overrides have been applied.
+func (p *protocol) validate() error {
+	switch naming.Device(p.Device) {
+	case "":
+		return errors.New("no device: set it in the protocol or with -device")
+	case naming.DeviceTrueRNG, naming.DeviceBitBabbler, naming.DevicePseudo:
+	default:
+		return fmt.Errorf("invalid device %q (allowed: trng, bitb, pseudo)", p.Device)
+	}
+	if p.Bits <= 0 {
+		return errors.New("bits must be > 0")
+	}
+	if p.IntervalSeconds <= 0 {
+		return errors.New("interval_seconds must be > 0")
+	}
+	for name, a := range p.Aims {
+		if a.Direction < -1 || a.Direction > 1 {
+			return fmt.Errorf("aim %q: direction must be -1, 0 or 1", name)
+		}
+	}
+	if len(p.Blocks) == 0 {
+		return errors.New("no blocks")
+	}
+	for i, b := range p.Blocks {
+		where := fmt.Sprintf("block %d", i+1)
+		if b.Name != "" {
+			where = fmt.Sprintf("block %q", b.Name)
+		}
+		if b.Runs <= 0 || b.Trials <= 0 {
+			return fmt.Errorf("%s: runs and trials must be > 0", where)
+		}
+		if len(b.Aims) == 0 {
+			return fmt.Errorf("%s: no aims", where)
+		}
+		for _, name := range b.Aims {
+			if _, ok := p.Aims[name]; !ok {
+				return fmt.Errorf("%s: undefined aim %q", where, name)
+			}
+		}
+		switch b.Order {
+		case "", orderAlternate, orderRandom, orderRandomEach:
+		default:
+			return fmt.Errorf("%s: invalid order %q (allowed: alternate, random, random-each)", where, b.Order)
+		}
+		if b.Rest < 0 {
+			return fmt.Errorf("%s: negative rest", where)
+		}
+	}
+	return nil
+}
+
+// plannedRun is one run of the session schedule.
+type plannedRun struct {
+	// Number is the 1-based run number within the session.
+	Number int `json:"run"`
+	// Block is the index of the run's block in the protocol.
+	Block int    `json:"block"`
+	Aim   string `json:"aim"`
+}
+
+// schedule assigns an aim to every run of p, drawing random orders with
+// intn, which returns a uniform integer in [0, n).
+func (p *protocol) schedule(intn func(n int) (int, error)) ([]plannedRun, error) {
+	var runs []plannedRun
+	for bi, b := range p.Blocks {
+		aims := make([]string, b.Runs)
+		switch b.Order {
+		case "", orderAlternate:
+			for i := range aims {
+				aims[i] = b.Aims[i%len(b.Aims)]
+			}
+		case orderRandom:
+			// Balanced: every aim equally often, the remainder drawn at random
+			// without repeats, then the whole sequence shuffled.
+			full := b.Runs / len(b.Aims) * len(b.Aims)
+			for i := 0; i < full; i++ {
+				aims[i] = b.Aims[i%len(b.Aims)]
+			}
+			rest := append([]string(nil), b.Aims...)
+			if err := shuffle(rest, intn); err != nil {
+				return nil, err
+			}
+			copy(aims[full:], rest)
+			if err := shuffle(aims, intn); err != nil {
+				return nil, err
+			}
+		case orderRandomEach:
+			for i := range aims {
+				k, err := intn(len(b.Aims))
+				if err != nil {
+					return nil, err
+				}
+				aims[i] = b.Aims[k]
+			}
+		}
+		for _, a := range aims {
+			runs = append(runs, plannedRun{Number: len(runs) + 1, Block: bi, Aim: a})
+		}
+	}
+	return runs, nil
+}
+
+// shuffle permutes s uniformly (Fisher-Yates) using intn.
+func shuffle(s []string, intn func(n int) (int, error)) error {
+	for i := len(s) - 1; i > 0; i-- {
+		j, err := intn(i + 1)
+		if err != nil {
+			return err
+		}
+		s[i], s[j] = s[j], s[i]
+	}
+	return nil
+}
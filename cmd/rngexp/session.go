@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/events"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// sessionRecord is written to <base>.session.json when a session ends,
+// including after an interrupt.
+type sessionRecord struct {
+	Protocol     *protocol `json:"protocol"`
+	ProtocolFile string    `json:"protocol_file"`
+	Participant  string    `json:"participant,omitempty"`
+	// Source describes the device the trials and aim orders came from.
+	Source string `json:"source"`
+	// Seed is set for the pseudo device, so the session can be reproduced.
+	Seed      uint64    `json:"seed,omitempty,string"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Completed bool      `json:"completed"`
+	// Capture is the .bin file holding every trial's bits in order.
+	Capture  string       `json:"capture"`
+	Schedule []plannedRun `json:"schedule"`
+	Runs     []runResult  `json:"runs"`
+	Stats    sessionStats `json:"stats"`
+}
+
+// writeJSON writes v as indented JSON to path.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// recorder writes a session's trials: the standard .bin and .csv capture,
+// so the session can be analysed like any collect run, the aim-labelled
+// <base>.trials.csv, and one events segment per run named by its aim.
+type recorder struct {
+	bits   int
+	bin    *os.File
+	binBuf *bufio.Writer
+	csv    *os.File
+	csvBuf *bufio.Writer
+	trl    *os.File
+	trlCSV *csv.Writer
+	evlog  *events.Log
+	trials []trial
+}
+
+// trialsHeader is the first row of a .trials.csv file.
+var trialsHeader = []string{"timestamp", "run", "block", "aim", "direction", "trial", "ones", "z"}
+
+// newRecorder creates the output files for binPath and csvPath.
+func newRecorder(binPath, csvPath string, bits int) (*recorder, error) {
+	r := &recorder{bits: bits, evlog: events.NewLog(events.Path(binPath))}
+	var err error
+	if r.bin, err = os.OpenFile(binPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644); err != nil {
+		return nil, fmt.Errorf("open bin file: %w", err)
+	}
+	if r.csv, err = os.OpenFile(csvPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("open csv file: %w", err)
+	}
+	if r.trl, err = os.OpenFile(naming.SidecarPath(binPath, "trials.csv"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("open trials file: %w", err)
+	}
+	r.binBuf = bufio.NewWriter(r.bin)
+	r.csvBuf = bufio.NewWriter(r.csv)
+	r.trlCSV = csv.NewWriter(r.trl)
+	_ = r.trlCSV.Write(trialsHeader)
+	return r, nil
+}
+
+// add records one trial's bits, packed MSB-first, and returns the trial.
+func (r *recorder) add(run runResult, n int, batch []byte, ones int) (trial, error) {
+	t := trial{Run: run.Run, Block: run.Block, Aim: run.Aim, Direction: run.Direction, Trial: n, Time: time.Now(), Ones: ones}
+	ts := naming.FormatTimestamp(t.Time)
+	if _, err := r.binBuf.Write(batch); err != nil {
+		return t, fmt.Errorf("write bin: %w", err)
+	}
+	if _, err := fmt.Fprintf(r.csvBuf, "%s,%d\n", ts, ones); err != nil {
+		return t, fmt.Errorf("write csv: %w", err)
+	}
+	_ = r.trlCSV.Write([]string{ts, strconv.Itoa(t.Run), t.Block, t.Aim, strconv.Itoa(t.Direction),
+		strconv.Itoa(t.Trial), strconv.Itoa(ones), strconv.FormatFloat(trialZ(ones, r.bits), 'f', 4, 64)})
+	r.trlCSV.Flush()
+	if err := r.trlCSV.Error(); err != nil {
+		return t, fmt.Errorf("write trials: %w", err)
+	}
+	for _, w := range []*bufio.Writer{r.binBuf, r.csvBuf} {
+		if err := w.Flush(); err != nil {
+			return t, err
+		}
+	}
+	r.trials = append(r.trials, t)
+	r.evlog.SetSample(len(r.trials))
+	return t, nil
+}
+
+// Close flushes and closes the files.
+func (r *recorder) Close() {
+	for _, w := range []*bufio.Writer{r.binBuf, r.csvBuf} {
+		if w != nil {
+			_ = w.Flush()
+		}
+	}
+	if r.trlCSV != nil {
+		r.trlCSV.Flush()
+	}
+	for _, f := range []*os.File{r.bin, r.csv, r.trl} {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+	_ = r.evlog.Close()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// trial is one recorded trial.
+type trial struct {
+	Run       int
+	Block     string
+	Aim       string
+	Direction int
+	// Trial is the 1-based trial number within the run.
+	Trial int
+	Time  time.Time
+	Ones  int
+}
+
+// groupStats summarises a set of trials of bits bits each.
+type groupStats struct {
+	Trials   int     `json:"trials"`
+	Ones     int     `json:"ones"`
+	MeanOnes float64 `json:"mean_ones"`
+	// Z is the z-score of the total ones count against chance,
+	// (ones - trials*bits/2) / sqrt(trials*bits/4), and P its two-sided
+	// p-value.
+	Z float64 `json:"z"`
+	P float64 `json:"p"`
+}
+
+// add counts one trial with the given number of ones.
+func (g *groupStats) add(ones int) {
+	g.Trials++
+	g.Ones += ones
+}
+
+// finish computes the derived fields for trials of bits bits.
+func (g *groupStats) finish(bits int) {
+	if g.Trials == 0 {
+		return
+	}
+	n := float64(g.Trials) * float64(bits)
+	g.MeanOnes = float64(g.Ones) / float64(g.Trials)
+	g.Z = (float64(g.Ones) - n/2) / math.Sqrt(n/4)
+	g.P = stats.TwoSidedP(g.Z)
+}
+
+// runResult is the outcome of one run.
+type runResult struct {
+	Run       int       `json:"run"`
+	Block     string    `json:"block"`
+	Aim       string    `json:"aim"`
+	Direction int       `json:"direction"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	// Completed is false for a run cut short by an interrupt; its trials
+	// are then left out of the per-aim and directional statistics.
+	Completed bool `json:"completed"`
+	groupStats
+}
+
+// aimResult pools the completed runs with one aim.
+type aimResult struct {
+	Aim       string `json:"aim"`
+	Direction int    `json:"direction"`
+	Runs      int    `json:"runs"`
+	groupStats
+}
+
+// directionalStats pools the trials of all aims with a direction, each
+// trial's deviation from chance signed by its aim's direction: a positive Z
+// means the results went the intended way.
+type directionalStats struct {
+	Trials int     `json:"trials"`
+	Z      float64 `json:"z"`
+	// P is one-sided, for a deviation in the intended direction.
+	P float64 `json:"p"`
+}
+
+// sessionStats holds the statistics of a session's completed runs.
+type sessionStats struct {
+	Overall     groupStats        `json:"overall"`
+	Aims        []aimResult       `json:"aims"`
+	Directional *directionalStats `json:"directional,omitempty"`
+}
+
+// summarize computes the statistics of the completed runs in runs from
+// their trials.
+func summarize(runs []runResult, trials []trial, bits int) sessionStats {
+	var st sessionStats
+	completed := make(map[int]bool)
+	byAim := make(map[string]*aimResult)
+	for _, r := range runs {
+		if !r.Completed {
+			continue
+		}
+		completed[r.Run] = true
+		a := byAim[r.Aim]
+		if a == nil {
+			a = &aimResult{Aim: r.Aim, Direction: r.Direction}
+			byAim[r.Aim] = a
+		}
+		a.Runs++
+	}
+
+	var dirTrials int
+	var dirDev float64
+	for _, t := range trials {
+		if !completed[t.Run] {
+			continue
+		}
+		st.Overall.add(t.Ones)
+		byAim[t.Aim].add(t.Ones)
+		if t.Direction != 0 {
+			dirTrials++
+			dirDev += float64(t.Direction) * (float64(t.Ones) - float64(bits)/2)
+		}
+	}
+	st.Overall.finish(bits)
+
+	st.Aims = make([]aimResult, 0, len(byAim))
+	for _, a := range byAim {
+		a.finish(bits)
+		st.Aims = append(st.Aims, *a)
+	}
+	sort.Slice(st.Aims, func(i, j int) bool { return st.Aims[i].Aim < st.Aims[j].Aim })
+
+	if dirTrials > 0 {
+		z := dirDev / math.Sqrt(float64(dirTrials)*float64(bits)/4)
+		st.Directional = &directionalStats{Trials: dirTrials, Z: z, P: stats.UpperP(z)}
+	}
+	return st
+}
+
+// trialZ is the z-score of one trial's ones count against chance.
+func trialZ(ones, bits int) float64 {
+	return (float64(ones) - float64(bits)/2) / math.Sqrt(float64(bits)/4)
+}
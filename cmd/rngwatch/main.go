@@ -0,0 +1,45 @@
+// rngwatch logs BitBabbler and TrueRNG attach/detach events with timestamps
+// until interrupted.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/Thiagojm/rng_go_cli/hotplug"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+func main() {
+	deviceFlag := flag.String("device", "all", "device to watch: all|trng|bitb")
+	interval := flag.Duration("interval", hotplug.DefaultInterval, "polling interval (e.g. 500ms)")
+	flag.Parse()
+
+	var kinds []naming.Device
+	switch *deviceFlag {
+	case "all":
+		kinds = []naming.Device{naming.DeviceTrueRNG, naming.DeviceBitBabbler}
+	case string(naming.DeviceTrueRNG):
+		kinds = []naming.Device{naming.DeviceTrueRNG}
+	case string(naming.DeviceBitBabbler):
+		kinds = []naming.Device{naming.DeviceBitBabbler}
+	default:
+		log.Fatalf("invalid -device: %s (allowed: all, trng, bitb)", *deviceFlag)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	events, err := hotplug.Watch(ctx, *interval, kinds...)
+	if err != nil {
+		log.Fatalf("watch: %v", err)
+	}
+	log.Printf("watching every %s. press Ctrl+C to stop...", interval.String())
+	for ev := range events {
+		fmt.Println(ev.String())
+	}
+}
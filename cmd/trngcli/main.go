@@ -19,15 +19,19 @@ import (
 func main() {
 	bits := flag.Int("bits", 1024, "number of bits to read per batch")
 	interval := flag.Duration("interval", 0, "interval between reads (e.g. 2s). 0 for one-shot")
+	rules := flag.String("rules", "", "optional JSON file with extra TrueRNG match rules")
 	flag.Parse()
 
-	present, err := truerng.Detect()
+	if *rules != "" {
+		if err := truerng.LoadRules(*rules); err != nil {
+			log.Fatalf("load rules: %v", err)
+		}
+	}
+	port, err := truerng.FindDevice()
 	if err != nil {
 		log.Fatalf("detect error: %v", err)
 	}
-	if !present {
-		log.Fatal("TrueRNG device not found")
-	}
+	log.Printf("using %s on %s", port.Model, port.Name)
 
 	if *interval == 0 {
 		data, err := truerng.ReadBits(*bits)
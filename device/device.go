@@ -0,0 +1,195 @@
+// Package device opens the random number sources shared by the commands: a
+// TrueRNG, a BitBabbler or the seeded pseudo generator, each read as an
+// io.ReadCloser.
+//
+//	src, err := device.Open(naming.DeviceTrueRNG, device.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	defer src.Close()
+//	sample, err := src.ReadBits(2048)
+package device
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Thiagojm/rng_go_cli/bbusb"
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/pseudorng"
+	"github.com/Thiagojm/rng_go_cli/truerng"
+)
+
+// Options configures Open.
+type Options struct {
+	// TrueRNGRules optionally names a JSON file of extra TrueRNG match rules
+	// (see truerng.LoadRules).
+	TrueRNGRules string
+	// Algorithm and Seed configure the pseudo generator. The zero Algorithm
+	// means pseudorng.AlgoChaCha8; seed 0 draws one.
+	Algorithm pseudorng.Algorithm
+	Seed      uint64
+}
+
+// Source is an open device. For naming.DevicePseudo the ReadCloser is the
+// *pseudorng.Generator, which reports its algorithm and seed.
+type Source struct {
+	io.ReadCloser
+	// Desc describes the device for logs and reports, e.g.
+	// "TrueRNGpro on /dev/ttyACM0".
+	Desc string
+	// ID identifies the device instance: the serial port of a TrueRNG, the
+	// USB device path of a BitBabbler (as in package hotplug) or the
+	// algorithm of the pseudo generator.
+	ID string
+}
+
+// flusher is implemented by readers that buffer device output between reads.
+type flusher interface {
+	Flush() error
+}
+
+// ReadBits reads one sample of bitCount bits, zero-padded to whole bytes.
+// Output the device buffered since the previous read is discarded first, so
+// the sample holds bits generated when it was taken. A short read is an
+// error: a truncated sample would shift every later one off its byte
+// boundary.
+func (s *Source) ReadBits(bitCount int) ([]byte, error) {
+	if bitCount <= 0 {
+		return nil, errors.New("bitCount must be positive")
+	}
+	if f, ok := s.ReadCloser.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, bitpack.BytesFor(bitCount))
+	if n, err := io.ReadFull(s, buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("short read (%d of %d bytes)", n, len(buf))
+		}
+		return nil, err
+	}
+	bitpack.Mask(buf, bitCount)
+	return buf, nil
+}
+
+// Open opens dev, which must be naming.DeviceTrueRNG,
+// naming.DeviceBitBabbler or naming.DevicePseudo.
+func Open(dev naming.Device, opts Options) (*Source, error) {
+	switch dev {
+	case naming.DevicePseudo:
+		algo := opts.Algorithm
+		if algo == "" {
+			algo = pseudorng.AlgoChaCha8
+		}
+		g, err := pseudorng.NewGeneratorWithAlgorithm(algo, opts.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("pseudo generator: %w", err)
+		}
+		return &Source{g, fmt.Sprintf("pseudo (%s, seed %d)", g.Algorithm(), g.Seed()), string(g.Algorithm())}, nil
+
+	case naming.DeviceTrueRNG:
+		if opts.TrueRNGRules != "" {
+			if err := truerng.LoadRules(opts.TrueRNGRules); err != nil {
+				return nil, fmt.Errorf("trng rules: %w", err)
+			}
+		}
+		port, err := truerng.FindDevice()
+		if err != nil {
+			return nil, fmt.Errorf("trng detect: %w", err)
+		}
+		r, err := truerng.Open(port.Name)
+		if err != nil {
+			return nil, fmt.Errorf("trng open: %w", err)
+		}
+		return &Source{&trueRNG{r: r}, fmt.Sprintf("%s on %s", port.Model, port.Name), port.Name}, nil
+
+	case naming.DeviceBitBabbler:
+		ok, devices, err := bbusb.IsBitBabblerConnected()
+		if err != nil {
+			return nil, fmt.Errorf("bitb detect: %w", err)
+		}
+		if !ok {
+			return nil, errors.New("no BitBabbler devices found (VID 0x0403 PID 0x7840)")
+		}
+		sess, err := bbusb.OpenBitBabbler(2_500_000, 1)
+		if err != nil {
+			return nil, fmt.Errorf("bitb open: %w (ensure libusb-1.0.dll is available)", err)
+		}
+		src := &Source{ReadCloser: sess, Desc: "BitBabbler"}
+		if len(devices) > 0 {
+			if devices[0].FriendlyName != "" {
+				src.Desc = devices[0].FriendlyName
+			}
+			src.ID = devices[0].DevicePath
+		}
+		return src, nil
+	}
+	return nil, fmt.Errorf("unsupported device %q", dev)
+}
+
+// trueRNG reads a TrueRNG through a truerng.Reader, reopening the port on
+// the next read after an error so that a replugged device is picked up,
+// possibly on another port.
+type trueRNG struct {
+	r      *truerng.Reader
+	closed bool
+}
+
+func (t *trueRNG) Read(p []byte) (int, error) {
+	if err := t.open(); err != nil {
+		return 0, err
+	}
+	n, err := t.r.Read(p)
+	if err != nil {
+		t.drop()
+	}
+	return n, err
+}
+
+// Flush discards buffered input; a port that is not open has none.
+func (t *trueRNG) Flush() error {
+	if t.r == nil {
+		return nil
+	}
+	err := t.r.Flush()
+	if err != nil {
+		t.drop()
+	}
+	return err
+}
+
+func (t *trueRNG) Close() error {
+	t.closed = true
+	if t.r == nil {
+		return nil
+	}
+	err := t.r.Close()
+	t.r = nil
+	return err
+}
+
+// open opens the first detected TrueRNG if no port is open.
+func (t *trueRNG) open() error {
+	if t.closed {
+		return errors.New("truerng: reader is closed")
+	}
+	if t.r != nil {
+		return nil
+	}
+	r, err := truerng.Open("")
+	if err != nil {
+		return err
+	}
+	t.r = r
+	return nil
+}
+
+// drop closes a port that failed, so that the next read reopens it.
+func (t *trueRNG) drop() {
+	_ = t.r.Close()
+	t.r = nil
+}
@@ -0,0 +1,134 @@
+package drbg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// AES-256 parameters for CTR_DRBG, per SP 800-90A Table 3.
+const (
+	ctrKeyLen  = 32
+	ctrOutLen  = aes.BlockSize
+	ctrSeedLen = ctrKeyLen + ctrOutLen
+)
+
+// ctrDRBG is CTR_DRBG (SP 800-90A section 10.2.1) with AES-256 and the
+// block cipher derivation function.
+type ctrDRBG struct {
+	block   cipher.Block
+	v       [ctrOutLen]byte
+	counter uint64
+}
+
+func newCTRDRBG(entropy, nonce, personalization []byte) *ctrDRBG {
+	d := &ctrDRBG{}
+	d.setKey(make([]byte, ctrKeyLen))
+	d.update(blockCipherDF(ctrSeedLen, entropy, nonce, personalization))
+	d.counter = 1
+	return d
+}
+
+func (d *ctrDRBG) setKey(key []byte) {
+	// aes.NewCipher only fails on invalid key sizes; ctrKeyLen is valid.
+	d.block, _ = aes.NewCipher(key)
+}
+
+// update is CTR_DRBG_Update; provided is exactly ctrSeedLen bytes.
+func (d *ctrDRBG) update(provided []byte) {
+	var temp [ctrSeedLen]byte
+	for n := 0; n < ctrSeedLen; n += ctrOutLen {
+		addMod(d.v[:], []byte{0x01})
+		d.block.Encrypt(temp[n:], d.v[:])
+	}
+	for i := range temp {
+		temp[i] ^= provided[i]
+	}
+	d.setKey(temp[:ctrKeyLen])
+	copy(d.v[:], temp[ctrKeyLen:])
+}
+
+func (d *ctrDRBG) Reseed(entropy, additional []byte) error {
+	if err := checkReseed(entropy, additional); err != nil {
+		return err
+	}
+	d.update(blockCipherDF(ctrSeedLen, entropy, additional))
+	d.counter = 1
+	return nil
+}
+
+func (d *ctrDRBG) Generate(out, additional []byte) error {
+	if err := checkGenerate(out, additional, d.counter); err != nil {
+		return err
+	}
+	add := make([]byte, ctrSeedLen)
+	if len(additional) > 0 {
+		add = blockCipherDF(ctrSeedLen, additional)
+		d.update(add)
+	}
+	var block [ctrOutLen]byte
+	for n := 0; n < len(out); {
+		addMod(d.v[:], []byte{0x01})
+		d.block.Encrypt(block[:], d.v[:])
+		n += copy(out[n:], block[:])
+	}
+	d.update(add)
+	d.counter++
+	return nil
+}
+
+// blockCipherDF is Block_Cipher_df (section 10.3.2) with AES-256: it derives
+// n bytes from the concatenated inputs.
+func blockCipherDF(n int, parts ...[]byte) []byte {
+	inputLen := 0
+	for _, p := range parts {
+		inputLen += len(p)
+	}
+	// S = L || N || input || 0x80, zero-padded to a multiple of the block size.
+	s := make([]byte, 8, 8+inputLen+1+ctrOutLen)
+	binary.BigEndian.PutUint32(s[0:4], uint32(inputLen))
+	binary.BigEndian.PutUint32(s[4:8], uint32(n))
+	for _, p := range parts {
+		s = append(s, p...)
+	}
+	s = append(s, 0x80)
+	for len(s)%ctrOutLen != 0 {
+		s = append(s, 0x00)
+	}
+
+	key := make([]byte, ctrKeyLen)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	k, _ := aes.NewCipher(key)
+	temp := make([]byte, 0, ctrSeedLen+ctrOutLen)
+	var iv [ctrOutLen]byte
+	for i := uint32(0); len(temp) < ctrSeedLen; i++ {
+		binary.BigEndian.PutUint32(iv[:4], i)
+		temp = append(temp, bcc(k, iv[:], s)...)
+	}
+
+	k, _ = aes.NewCipher(temp[:ctrKeyLen])
+	x := temp[ctrKeyLen:ctrSeedLen]
+	out := make([]byte, 0, n+ctrOutLen)
+	for len(out) < n {
+		k.Encrypt(x, x)
+		out = append(out, x...)
+	}
+	return out[:n]
+}
+
+// bcc is the BCC function (section 10.3.3): CBC-MAC with a zero IV over the
+// concatenation of the given block-aligned data.
+func bcc(k cipher.Block, parts ...[]byte) []byte {
+	chain := make([]byte, ctrOutLen)
+	for _, p := range parts {
+		for off := 0; off < len(p); off += ctrOutLen {
+			for i := 0; i < ctrOutLen; i++ {
+				chain[i] ^= p[off+i]
+			}
+			k.Encrypt(chain, chain)
+		}
+	}
+	return chain
+}
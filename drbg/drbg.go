@@ -0,0 +1,129 @@
+// Package drbg implements the NIST SP 800-90A Rev. 1 deterministic random bit
+// generators Hash_DRBG (SHA-256), HMAC_DRBG (SHA-256) and CTR_DRBG (AES-256
+// with derivation function), for use with the hardware sources as entropy
+// input.
+//
+// All three mechanisms provide 256-bit security strength. Prediction
+// resistance is not supported; callers that need fresh entropy reseed
+// explicitly (see Source, which reseeds on a timer).
+package drbg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Mechanism selects a DRBG construction.
+type Mechanism string
+
+const (
+	MechHash Mechanism = "hash" // Hash_DRBG with SHA-256
+	MechHMAC Mechanism = "hmac" // HMAC_DRBG with SHA-256
+	MechCTR  Mechanism = "ctr"  // CTR_DRBG with AES-256 and derivation function
+)
+
+// Mechanisms lists the supported mechanisms.
+var Mechanisms = []Mechanism{MechHash, MechHMAC, MechCTR}
+
+// ParseMechanism validates a mechanism name.
+func ParseMechanism(s string) (Mechanism, error) {
+	for _, m := range Mechanisms {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("invalid mechanism: %q (allowed: hash, hmac, ctr)", s)
+}
+
+const (
+	// SecurityStrength is the security strength in bits of every mechanism.
+	SecurityStrength = 256
+	// MinEntropyBytes is the minimum entropy input length for instantiate and
+	// reseed: the security strength in bytes.
+	MinEntropyBytes = SecurityStrength / 8
+	// MinNonceBytes is the minimum nonce length: half the security strength.
+	MinNonceBytes = SecurityStrength / 16
+	// MaxEntropyBytes bounds entropy input, personalization string and
+	// additional input (2^35 bits in the standard; far less is practical).
+	MaxEntropyBytes = 1 << 16
+	// MaxBytesPerRequest is the largest Generate request (2^19 bits).
+	MaxBytesPerRequest = 1 << 16
+	// ReseedInterval is the number of Generate calls allowed between reseeds.
+	ReseedInterval = 1 << 48
+)
+
+var (
+	// ErrReseedRequired is returned by Generate once ReseedInterval requests
+	// have been served since the last (re)seed.
+	ErrReseedRequired = errors.New("drbg: reseed required")
+	// ErrRequestTooLarge is returned for requests above MaxBytesPerRequest.
+	ErrRequestTooLarge = errors.New("drbg: request exceeds MaxBytesPerRequest")
+)
+
+// DRBG is an instantiated SP 800-90A generator. Instances are not safe for
+// concurrent use.
+type DRBG interface {
+	// Reseed mixes fresh entropy (at least MinEntropyBytes) and optional
+	// additional input into the state and resets the reseed counter.
+	Reseed(entropy, additional []byte) error
+	// Generate fills out (at most MaxBytesPerRequest bytes) with
+	// pseudorandom bytes, mixing in optional additional input.
+	Generate(out, additional []byte) error
+}
+
+// New instantiates mechanism m from entropy input, a nonce and an optional
+// personalization string.
+func New(m Mechanism, entropy, nonce, personalization []byte) (DRBG, error) {
+	if err := checkInput(entropy, MinEntropyBytes, "entropy"); err != nil {
+		return nil, err
+	}
+	if err := checkInput(nonce, MinNonceBytes, "nonce"); err != nil {
+		return nil, err
+	}
+	if err := checkInput(personalization, 0, "personalization"); err != nil {
+		return nil, err
+	}
+	switch m {
+	case MechHash:
+		return newHashDRBG(entropy, nonce, personalization), nil
+	case MechHMAC:
+		return newHMACDRBG(entropy, nonce, personalization), nil
+	case MechCTR:
+		return newCTRDRBG(entropy, nonce, personalization), nil
+	default:
+		return nil, fmt.Errorf("invalid mechanism: %q", string(m))
+	}
+}
+
+// checkInput validates the length of an input string.
+func checkInput(b []byte, min int, what string) error {
+	if len(b) < min {
+		return fmt.Errorf("drbg: %s must be at least %d bytes, got %d", what, min, len(b))
+	}
+	if len(b) > MaxEntropyBytes {
+		return fmt.Errorf("drbg: %s must be at most %d bytes, got %d", what, MaxEntropyBytes, len(b))
+	}
+	return nil
+}
+
+// checkGenerate validates a Generate call against the request and reseed limits.
+func checkGenerate(out, additional []byte, counter uint64) error {
+	if len(out) > MaxBytesPerRequest {
+		return ErrRequestTooLarge
+	}
+	if err := checkInput(additional, 0, "additional input"); err != nil {
+		return err
+	}
+	if counter > ReseedInterval {
+		return ErrReseedRequired
+	}
+	return nil
+}
+
+// checkReseed validates Reseed inputs.
+func checkReseed(entropy, additional []byte) error {
+	if err := checkInput(entropy, MinEntropyBytes, "entropy"); err != nil {
+		return err
+	}
+	return checkInput(additional, 0, "additional input")
+}
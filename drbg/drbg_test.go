@@ -0,0 +1,194 @@
+package drbg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// katVectors are known-answer tests following the CAVP DRBG procedure:
+// instantiate, reseed if reseedEntropy is set, then generate twice,
+// checking the second output. The "no reseed" vectors are COUNT 0 of the
+// SHA-256 and AES-256 (with derivation function) sections of the CAVP
+// drbgvectors_no_reseed files, without prediction resistance. The others
+// exercise personalization, additional input and reseeding with inputs
+// derived from SHA-256 of fixed labels; their outputs were computed with
+// OpenSSL 3.0's HASH-DRBG, HMAC-DRBG and CTR-DRBG, which reproduce the CAVP
+// vectors above.
+var katVectors = []struct {
+	name                            string
+	mech                            Mechanism
+	entropy, nonce, personalization string
+	reseedEntropy, reseedAdditional string
+	additional1, additional2        string
+	want                            string
+}{
+	{
+		name:    "hash no reseed",
+		mech:    MechHash,
+		entropy: "a65ad0f345db4e0effe875c3a2e71f42c7129d620ff5c119a9ef55f05185e0fb",
+		nonce:   "8581f9317517276e06e9607ddbcbcc2e",
+		want: "d3e160c35b99f340b2628264d1751060e0045da383ff57a57d73a673d2b8d80d" +
+			"aaf6a6c35a91bb4579d73fd0c8fed111b0391306828adfed528f018121b3febd" +
+			"c343e797b87dbb63db1333ded9d1ece177cfa6b71fe8ab1da46624ed6415e51c" +
+			"cde2c7ca86e283990eeaeb91120415528b2295910281b02dd431f4c9f70427df",
+	},
+	{
+		name:            "hash personalization and additional input",
+		mech:            MechHash,
+		entropy:         "0d5d0b667e67d0e258bfc043a6146ede7053aa28a879b43d8e783f8ad83f8f90",
+		nonce:           "f5b766664de48e2191d2627f83a4ade2",
+		personalization: "febe9c6c8dc67741ebf2972396c013a664c2b04503a74ec0aade02500fde1ae8",
+		additional1:     "5d203dc8eda84891fe37515827200c31e591e0d7162510efe27e5f441500c089",
+		additional2:     "38c7c24f6d50c12c8766443bb58c9c1e816e0ca045e3a24e56acc1f6c5b13f52",
+		want: "443de3587b130034cd557942bf719e43dc625412f40a2921f9691a8b61a9620b" +
+			"09b71df68d45bdf323e4736116a542f38f79461f708a8b0ec410abe71bce85cb" +
+			"36feba42311596da76a081517c4e88ef70bb8e3b702b023d4a1604658ba7e056" +
+			"da179580a731e78912add40bb169cfb8c9e6a355ca7045659f6f80e40e8a3fc1",
+	},
+	{
+		name:             "hash reseed with additional input",
+		mech:             MechHash,
+		entropy:          "e98ba1116d78bafba838079d160ddaa254f48462e86416c4cf3065b865793e70",
+		nonce:            "d83a3fc34e0b05192cc7eff419e7f591",
+		reseedEntropy:    "49baebae8dd85c0c8dfc275fc1c7f4e104d07d2a2353447a1c50e187e8ec03fd",
+		reseedAdditional: "b500104c4f87e7cf82d7ef97b56e829c30334ebb7cf8a5b7bc3f6fbfa8d97f02",
+		additional1:      "61393a1f5e48627f82b4a8e26362d3d9087563c522ee59c9f720b4730bfacb55",
+		additional2:      "2a28c349db953e783166de529453d91436c7d08dac5f73b430c9c644555f63e4",
+		want: "8c5408ae42ca9de3b64b16648338ec8105d695a453fac0e0a3c293dc7a590ec7" +
+			"c2da25ffbb256d01a95bf2402b5b8e09ebb925d3fb2b821f1e0cfcaf45264004" +
+			"cfd526f43b9ffcf4280e2bb446b849a35d1d93e06779ca8a29c289f8c127d6d9" +
+			"6ba31858b6b5b0dad2deafc37d685c05513788684a8820413c7bc87a171b5641",
+	},
+	{
+		name:    "hmac no reseed",
+		mech:    MechHMAC,
+		entropy: "ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488",
+		nonce:   "659ba96c601dc69fc902940805ec0ca8",
+		want: "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89" +
+			"d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc1" +
+			"07694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668" +
+			"961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8",
+	},
+	{
+		name:            "hmac personalization and additional input",
+		mech:            MechHMAC,
+		entropy:         "8a785ef7804a162a277b7497c0f8e271ac3f61a735c3d40c36f92675e245858e",
+		nonce:           "54f86051b2d32baa946c40f56e8cebf2",
+		personalization: "1c2d1e8b6cf23b6cc84caa45cb3484caabc7ac57f2db0a807590c461467a5959",
+		additional1:     "121b7392788eb968f75a415e4d264a5b05d66e83917651a121380b0777241f46",
+		additional2:     "d2e4034263ef7eaad93c842abfb83c2fca5f2694c0e96925e6e4435344fc5c02",
+		want: "ba3bde9eb643dd540ae9d5a8027a9328be84a89fa9a8d2bdc268d3f9245e84bb" +
+			"111798a0a8cf79edd76513faa66cb49c1e68f2a23b3ee88e0c2600e9ad208e2e" +
+			"fc3244ca8785e56709f3fb9a3c834dcedc3baed867720a93a324ef07f71f1365" +
+			"7b8df9b0abf22554c8f890a81ca505a76d052145fe56709dbdf9408fba97a281",
+	},
+	{
+		name:             "hmac reseed with additional input",
+		mech:             MechHMAC,
+		entropy:          "2415db3358b86bcfa92fd3a83730ea6b8a450812a71f77766189858b13f9f9a1",
+		nonce:            "4440ac55ca7a1bd7f1b914db959ee4d7",
+		reseedEntropy:    "d75ef1597ebdad2d43904ec285bf12d1165e2757606172067cab068d7669e0e6",
+		reseedAdditional: "12ef4c92c858e96d268358e77b2f4bf2ed0c18aaff722292df2c06d41f900de1",
+		additional1:      "539d103572154c68deb62bb7d3e3b73430588da28d82c2d05c05ef05cf5f63b5",
+		additional2:      "652496f7dc456c7c5480d22d6bba1c16e94aef24d5749e5c068624b1e217a9cb",
+		want: "1ab77937122b49459c04561b29c4ed5ce1839b13d31f5837ea037f1f75559a60" +
+			"9594226c0339259db24311df8ea5633c4815bbb4e592c003ae955bc4318dc379" +
+			"720c2e447606ad009de52b1d555c3779a353ea96c419d631123a3f472b64148f" +
+			"973bc48426f92d9ff9785d94a35338632d88e48154e2043e1ec87af8b27a429c",
+	},
+	{
+		name:    "ctr no reseed",
+		mech:    MechCTR,
+		entropy: "36401940fa8b1fba91a1661f211d78a0b9389a74e5bccfece8d766af1a6d3b14",
+		nonce:   "496f25b0f1301b4f501be30380a137eb",
+		want: "5862eb38bd558dd978a696e6df164782ddd887e7e9a6c9f3f1fbafb78941b535" +
+			"a64912dfd224c6dc7454e5250b3d97165e16260c2faf1cc7735cb75fb4f07e1d",
+	},
+	{
+		name:            "ctr personalization and additional input",
+		mech:            MechCTR,
+		entropy:         "4760d05159eafa232f328ad5f9a6261a9bfe34557e1c909f16255f0ff729684e",
+		nonce:           "b22d4a48fad76fc2e5501e9c8be910cd",
+		personalization: "fd30903736cffa6708d26a8f2abcce74f03384597fdd548104c7ec606ecb08dc",
+		additional1:     "3f085467b9accdb5ec75dc2fe5b87713a3a92a6a8a7107d312c8a42adf84a110",
+		additional2:     "a09baca4a0c4dc79a009e4a901f0fd858f95f9883a1a2a8d70b4518ccb7eac6d",
+		want: "1ba732662c329b1d0e38e807d7d651d754e6a6a1cd47f92a16cc68114be8f3a0" +
+			"298d4ac9dda6048dcb7e557fc9473920063fe4b310cc7ea279df7005f6e178c7",
+	},
+	{
+		name:             "ctr reseed with additional input",
+		mech:             MechCTR,
+		entropy:          "ff990afb855f653ed0885409845134c46f516d483c02eb9c5e84485a25817bc2",
+		nonce:            "e8288ae7c5be0d43f4f02f0f886321ed",
+		reseedEntropy:    "c1e1a0fe08c9ecfff8863f0851ed424bb33be0d5ad1cda464b9d93625ab929c0",
+		reseedAdditional: "b605d3e48f879ba076a75f98ce79ff03717db865160a5c789f3347a4915ea636",
+		additional1:      "a56eab2176cd10c971adced20a01a0df7816e43d17631e003548ce66dce19a18",
+		additional2:      "53e74c0d2f016a5fe0696d0d55d28b239d5892288e5e5b975c5eca161c728d99",
+		want: "0d276df0e473a7bb5a97d82c91eae3d674f34956d58ee1ae207497b9dd76428c" +
+			"8c680e2f0a1c808a913eeabe9fb9a7aa343f861970ef3b53c54ff4a96c3faa75",
+	},
+}
+
+func TestKnownAnswers(t *testing.T) {
+	for _, tt := range katVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(tt.mech, unhex(t, tt.entropy), unhex(t, tt.nonce), unhex(t, tt.personalization))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.reseedEntropy != "" {
+				if err := d.Reseed(unhex(t, tt.reseedEntropy), unhex(t, tt.reseedAdditional)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := unhex(t, tt.want)
+			got := make([]byte, len(want))
+			if err := d.Generate(got, unhex(t, tt.additional1)); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Generate(got, unhex(t, tt.additional2)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("second Generate =\n%x\nwant\n%x", got, want)
+			}
+		})
+	}
+}
+
+func TestInputChecks(t *testing.T) {
+	entropy := make([]byte, MinEntropyBytes)
+	nonce := make([]byte, MinNonceBytes)
+	for _, m := range Mechanisms {
+		if _, err := New(m, entropy[:MinEntropyBytes-1], nonce, nil); err == nil {
+			t.Errorf("%s: short entropy accepted", m)
+		}
+		if _, err := New(m, entropy, nonce[:MinNonceBytes-1], nil); err == nil {
+			t.Errorf("%s: short nonce accepted", m)
+		}
+		d, err := New(m, entropy, nonce, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Generate(make([]byte, MaxBytesPerRequest+1), nil); !errors.Is(err, ErrRequestTooLarge) {
+			t.Errorf("%s: oversized request: got %v, want ErrRequestTooLarge", m, err)
+		}
+		if err := d.Reseed(entropy[:MinEntropyBytes-1], nil); err == nil {
+			t.Errorf("%s: short reseed entropy accepted", m)
+		}
+	}
+	if _, err := New("sha1", entropy, nonce, nil); err == nil {
+		t.Error("unknown mechanism accepted")
+	}
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
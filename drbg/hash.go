@@ -0,0 +1,103 @@
+package drbg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// hashSeedLen is seedlen for SHA-256 (440 bits), per SP 800-90A Table 2.
+const hashSeedLen = 55
+
+// hashDRBG is Hash_DRBG (SP 800-90A section 10.1.1) with SHA-256.
+type hashDRBG struct {
+	v, c    [hashSeedLen]byte
+	counter uint64
+}
+
+func newHashDRBG(entropy, nonce, personalization []byte) *hashDRBG {
+	d := &hashDRBG{}
+	d.setSeed(hashDF(hashSeedLen, entropy, nonce, personalization))
+	return d
+}
+
+// setSeed sets V to seed, derives C from it and resets the reseed counter.
+func (d *hashDRBG) setSeed(seed []byte) {
+	copy(d.v[:], seed)
+	copy(d.c[:], hashDF(hashSeedLen, []byte{0x00}, d.v[:]))
+	d.counter = 1
+}
+
+func (d *hashDRBG) Reseed(entropy, additional []byte) error {
+	if err := checkReseed(entropy, additional); err != nil {
+		return err
+	}
+	d.setSeed(hashDF(hashSeedLen, []byte{0x01}, d.v[:], entropy, additional))
+	return nil
+}
+
+func (d *hashDRBG) Generate(out, additional []byte) error {
+	if err := checkGenerate(out, additional, d.counter); err != nil {
+		return err
+	}
+	if len(additional) > 0 {
+		w := hashOf([]byte{0x02}, d.v[:], additional)
+		addMod(d.v[:], w)
+	}
+
+	// Hashgen: hash successive values of V without disturbing it.
+	data := d.v
+	for n := 0; n < len(out); {
+		block := hashOf(data[:])
+		n += copy(out[n:], block)
+		addMod(data[:], []byte{0x01})
+	}
+
+	h := hashOf([]byte{0x03}, d.v[:])
+	addMod(d.v[:], h)
+	addMod(d.v[:], d.c[:])
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], d.counter)
+	addMod(d.v[:], ctr[:])
+	d.counter++
+	return nil
+}
+
+// hashOf returns SHA-256 of the concatenated inputs.
+func hashOf(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// hashDF is Hash_df (section 10.3.1): it derives n bytes from the
+// concatenated inputs.
+func hashDF(n int, parts ...[]byte) []byte {
+	var bitsToReturn [4]byte
+	binary.BigEndian.PutUint32(bitsToReturn[:], uint32(n*8))
+	out := make([]byte, 0, n+sha256.Size)
+	for counter := byte(1); len(out) < n; counter++ {
+		h := sha256.New()
+		h.Write([]byte{counter})
+		h.Write(bitsToReturn[:])
+		for _, p := range parts {
+			h.Write(p)
+		}
+		out = h.Sum(out)
+	}
+	return out[:n]
+}
+
+// addMod sets a = (a + b) mod 2^(8*len(a)), both big-endian; b may be shorter.
+func addMod(a, b []byte) {
+	carry := 0
+	for i, j := len(a)-1, len(b)-1; i >= 0; i, j = i-1, j-1 {
+		sum := int(a[i]) + carry
+		if j >= 0 {
+			sum += int(b[j])
+		}
+		a[i] = byte(sum)
+		carry = sum >> 8
+	}
+}
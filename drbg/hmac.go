@@ -0,0 +1,77 @@
+package drbg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// hmacDRBG is HMAC_DRBG (SP 800-90A section 10.1.2) with SHA-256.
+type hmacDRBG struct {
+	k, v    [sha256.Size]byte
+	counter uint64
+}
+
+func newHMACDRBG(entropy, nonce, personalization []byte) *hmacDRBG {
+	d := &hmacDRBG{}
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+	d.update(entropy, nonce, personalization)
+	d.counter = 1
+	return d
+}
+
+// update is HMAC_DRBG_Update with the concatenated inputs as provided_data.
+func (d *hmacDRBG) update(provided ...[]byte) {
+	empty := true
+	for _, p := range provided {
+		empty = empty && len(p) == 0
+	}
+	for _, sep := range []byte{0x00, 0x01} {
+		if sep == 0x01 && empty {
+			return
+		}
+		m := hmac.New(sha256.New, d.k[:])
+		m.Write(d.v[:])
+		m.Write([]byte{sep})
+		for _, p := range provided {
+			m.Write(p)
+		}
+		m.Sum(d.k[:0])
+		d.v = d.mac(d.v[:])
+	}
+}
+
+// mac returns HMAC(K, data).
+func (d *hmacDRBG) mac(data []byte) [sha256.Size]byte {
+	var out [sha256.Size]byte
+	m := hmac.New(sha256.New, d.k[:])
+	m.Write(data)
+	m.Sum(out[:0])
+	return out
+}
+
+func (d *hmacDRBG) Reseed(entropy, additional []byte) error {
+	if err := checkReseed(entropy, additional); err != nil {
+		return err
+	}
+	d.update(entropy, additional)
+	d.counter = 1
+	return nil
+}
+
+func (d *hmacDRBG) Generate(out, additional []byte) error {
+	if err := checkGenerate(out, additional, d.counter); err != nil {
+		return err
+	}
+	if len(additional) > 0 {
+		d.update(additional)
+	}
+	for n := 0; n < len(out); {
+		d.v = d.mac(d.v[:])
+		n += copy(out[n:], d.v[:])
+	}
+	d.update(additional)
+	d.counter++
+	return nil
+}
@@ -0,0 +1,110 @@
+package drbg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+)
+
+// EntropyFunc returns n bytes of entropy input, typically raw output from a
+// hardware source. The bytes are assumed to carry full entropy.
+type EntropyFunc func(ctx context.Context, n int) ([]byte, error)
+
+// Source is a DRBG that draws its seed from an EntropyFunc and reseeds from
+// it whenever the reseed interval has elapsed or the DRBG requires it.
+type Source struct {
+	mech     Mechanism
+	d        DRBG
+	entropy  EntropyFunc
+	interval time.Duration
+	seeded   time.Time
+	reseeds  int
+}
+
+// NewSource instantiates mechanism m with MinEntropyBytes of entropy and a
+// MinNonceBytes nonce from entropy. A positive interval reseeds with
+// MinEntropyBytes of fresh entropy once that long has passed since the last
+// (re)seed; 0 reseeds only when the DRBG demands it.
+func NewSource(ctx context.Context, m Mechanism, entropy EntropyFunc, interval time.Duration, personalization []byte) (*Source, error) {
+	if entropy == nil {
+		return nil, errors.New("entropy function must not be nil")
+	}
+	if interval < 0 {
+		return nil, errors.New("interval must be >= 0")
+	}
+	seed, err := readEntropy(ctx, entropy, MinEntropyBytes+MinNonceBytes)
+	if err != nil {
+		return nil, err
+	}
+	d, err := New(m, seed[:MinEntropyBytes], seed[MinEntropyBytes:], personalization)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{mech: m, d: d, entropy: entropy, interval: interval, seeded: time.Now()}, nil
+}
+
+// Mechanism returns the DRBG mechanism in use.
+func (s *Source) Mechanism() Mechanism { return s.mech }
+
+// Reseeds returns how many times the source has reseeded since instantiation.
+func (s *Source) Reseeds() int { return s.reseeds }
+
+// Reseed reseeds immediately with fresh entropy.
+func (s *Source) Reseed(ctx context.Context) error {
+	e, err := readEntropy(ctx, s.entropy, MinEntropyBytes)
+	if err != nil {
+		return err
+	}
+	if err := s.d.Reseed(e, nil); err != nil {
+		return err
+	}
+	s.seeded = time.Now()
+	s.reseeds++
+	return nil
+}
+
+// ReadBits returns bitCount bits in the bitpack layout. Requests larger
+// than MaxBytesPerRequest are split across several Generate calls.
+func (s *Source) ReadBits(ctx context.Context, bitCount int) ([]byte, error) {
+	if bitCount <= 0 {
+		return nil, errors.New("bitCount must be positive")
+	}
+	if s.interval > 0 && time.Since(s.seeded) >= s.interval {
+		if err := s.Reseed(ctx); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, bitpack.BytesFor(bitCount))
+	for off := 0; off < len(buf); {
+		end := off + MaxBytesPerRequest
+		if end > len(buf) {
+			end = len(buf)
+		}
+		err := s.d.Generate(buf[off:end], nil)
+		if errors.Is(err, ErrReseedRequired) {
+			if err = s.Reseed(ctx); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		off = end
+	}
+	bitpack.Mask(buf, bitCount)
+	return buf, nil
+}
+
+// readEntropy reads exactly n bytes from f.
+func readEntropy(ctx context.Context, f EntropyFunc, n int) ([]byte, error) {
+	b, err := f(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < n {
+		return nil, errors.New("drbg: short entropy read")
+	}
+	return b[:n], nil
+}
@@ -0,0 +1,108 @@
+package egd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// Client talks to an EGD server. It is not safe for concurrent use.
+type Client struct {
+	conn net.Conn
+	// Timeout bounds each request, including waits for entropy in Read;
+	// zero means no limit.
+	Timeout time.Duration
+}
+
+// Dial connects to the server at addr, given as for Listen.
+func Dial(addr string) (*Client, error) {
+	network, address := splitAddr(addr)
+	c, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: c}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// EntropyLevel returns the number of bits the server has available.
+func (c *Client) EntropyLevel() (int, error) {
+	var b [4]byte
+	if err := c.request([]byte{CmdEntropyLevel}, b[:]); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(b[:])), nil
+}
+
+// ReadNonblocking returns up to n (at most MaxRead) bytes the server has
+// available without waiting; it may return fewer, or none.
+func (c *Client) ReadNonblocking(n int) ([]byte, error) {
+	if n < 0 || n > MaxRead {
+		return nil, errors.New("egd: read size must be 0-255")
+	}
+	var count [1]byte
+	if err := c.request([]byte{CmdReadNonblock, byte(n)}, count[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, count[0])
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Read fills p, waiting for the server as needed; larger reads are split
+// into requests of MaxRead bytes. It makes Client an io.Reader.
+func (c *Client) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		k := min(len(p)-n, MaxRead)
+		if err := c.request([]byte{CmdReadBlock, byte(k)}, p[n:n+k]); err != nil {
+			return n, err
+		}
+		n += k
+	}
+	return n, nil
+}
+
+// AddEntropy writes data to the server, estimated to hold bits bits of
+// entropy. At most 255 bytes are sent per request.
+func (c *Client) AddEntropy(data []byte, bits int) error {
+	if len(data) > MaxRead || bits < 0 || bits > 0xffff {
+		return errors.New("egd: at most 255 bytes and 65535 bits per write")
+	}
+	req := append([]byte{CmdWrite, byte(bits >> 8), byte(bits), byte(len(data))}, data...)
+	return c.request(req, nil)
+}
+
+// PID returns the server's process id.
+func (c *Client) PID() (string, error) {
+	var n [1]byte
+	if err := c.request([]byte{CmdPID}, n[:]); err != nil {
+		return "", err
+	}
+	pid := make([]byte, n[0])
+	if _, err := io.ReadFull(c.conn, pid); err != nil {
+		return "", err
+	}
+	return string(pid), nil
+}
+
+// request sends req and reads len(reply) bytes of reply.
+func (c *Client) request(req, reply []byte) error {
+	if c.Timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.Timeout))
+		defer c.conn.SetDeadline(time.Time{})
+	}
+	if _, err := c.conn.Write(req); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(c.conn, reply)
+	return err
+}
@@ -0,0 +1,95 @@
+// Package egd implements the Entropy Gathering Daemon protocol, which tools
+// such as OpenSSL (RAND_egd) and GnuPG use to read entropy from a socket,
+// with a Server fed by any Source and a Client.
+//
+// Each request is a command byte, possibly followed by arguments:
+//
+//	0x00           entropy level: the reply is the number of bits
+//	               available as a 4-byte big-endian integer
+//	0x01 n         read up to n bytes without blocking: the reply is a
+//	               count byte followed by that many bytes
+//	0x02 n         read n bytes, blocking until they are available: the
+//	               reply is the n bytes
+//	0x03 b1 b2 n … write n bytes of entropy, estimated at b1<<8|b2 bits;
+//	               there is no reply
+//	0x04           the daemon's process id: a length byte followed by the
+//	               id as decimal text
+//
+// n is a single byte, so a read returns at most 255 bytes.
+package egd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Commands.
+const (
+	CmdEntropyLevel byte = 0x00
+	CmdReadNonblock byte = 0x01
+	CmdReadBlock    byte = 0x02
+	CmdWrite        byte = 0x03
+	CmdPID          byte = 0x04
+)
+
+// MaxRead is the most bytes one read command can ask for.
+const MaxRead = 255
+
+// ErrUnknownCommand is reported for a command byte outside 0x00-0x04; the
+// server closes the connection, as the request's length is unknown.
+var ErrUnknownCommand = errors.New("egd: unknown command")
+
+// Source supplies the server's entropy.
+type Source interface {
+	// Available returns the number of bytes that can be read without
+	// waiting.
+	Available() int
+	// Read returns n bytes, waiting for them if needed, until ctx is done.
+	Read(ctx context.Context, n int) ([]byte, error)
+}
+
+// Sink is implemented by sources that accept entropy written by clients.
+// Without it, written entropy is read and discarded.
+type Sink interface {
+	AddEntropy(data []byte, bits int)
+}
+
+// splitAddr splits an address of the form "unix:/path" or "tcp:host:port".
+// An address without a scheme is a Unix socket if it contains a path
+// separator, and a TCP address otherwise.
+func splitAddr(addr string) (network, address string) {
+	if n, a, ok := strings.Cut(addr, ":"); ok && (n == "unix" || n == "tcp") {
+		return n, a
+	}
+	if strings.ContainsAny(addr, `/\`) {
+		return "unix", addr
+	}
+	return "tcp", addr
+}
+
+// Listen listens on addr ("unix:/path", "tcp:host:port", a path or
+// host:port). A Unix socket left behind by a daemon that is no longer
+// running is removed first.
+func Listen(addr string) (net.Listener, error) {
+	network, address := splitAddr(addr)
+	ln, err := net.Listen(network, address)
+	if err == nil || network != "unix" || !errors.Is(err, syscall.EADDRINUSE) {
+		return ln, err
+	}
+	if c, derr := net.Dial("unix", address); derr == nil {
+		c.Close()
+		return nil, fmt.Errorf("%s is in use by a running daemon", address)
+	}
+	if fi, serr := os.Lstat(address); serr != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil, err
+	}
+	if rerr := os.Remove(address); rerr != nil {
+		return nil, err
+	}
+	return net.Listen(network, address)
+}
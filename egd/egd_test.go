@@ -0,0 +1,233 @@
+package egd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pool is a Source and Sink holding the bytes given to it.
+type pool struct {
+	mu      sync.Mutex
+	data    []byte
+	bits    int           // entropy credited by AddEntropy
+	changed chan struct{} // closed and replaced when data grows
+}
+
+func newPool(data []byte) *pool {
+	return &pool{data: data, changed: make(chan struct{})}
+}
+
+func (p *pool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.data)
+}
+
+func (p *pool) Read(ctx context.Context, n int) ([]byte, error) {
+	for {
+		p.mu.Lock()
+		if len(p.data) >= n {
+			out := p.data[:n:n]
+			p.data = p.data[n:]
+			p.mu.Unlock()
+			return out, nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *pool) AddEntropy(data []byte, bits int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = append(p.data, data...)
+	p.bits += bits
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// connect serves src over an in-memory connection and returns a client for
+// it, with a channel receiving the server's log lines.
+func connect(t *testing.T, src Source) (*Client, <-chan string) {
+	t.Helper()
+	logs := make(chan string, 10)
+	s := &Server{Source: src, Logf: func(format string, args ...any) {
+		logs <- fmt.Sprintf(format, args...)
+	}}
+	cc, sc := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeConn(sc)
+	}()
+	c := &Client{conn: cc, Timeout: 5 * time.Second}
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+		<-done
+	})
+	return c, logs
+}
+
+func TestEntropyLevel(t *testing.T) {
+	c, _ := connect(t, newPool(make([]byte, 10)))
+	bits, err := c.EntropyLevel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits != 80 {
+		t.Errorf("EntropyLevel = %d, want 80", bits)
+	}
+}
+
+func TestReadNonblocking(t *testing.T) {
+	tests := []struct {
+		name string
+		pool []byte
+		n    int
+		want []byte
+	}{
+		{"enough", []byte{1, 2, 3, 4, 5}, 3, []byte{1, 2, 3}},
+		{"fewer than asked", []byte{1, 2}, 200, []byte{1, 2}},
+		{"empty", nil, 10, []byte{}},
+		{"zero", []byte{1}, 0, []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := connect(t, newPool(tt.pool))
+			got, err := c.ReadNonblocking(tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadNonblocking(%d) = %x, want %x", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBlocks(t *testing.T) {
+	p := newPool([]byte{1, 2})
+	c, _ := connect(t, p)
+	type result struct {
+		b   []byte
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		b := make([]byte, 4)
+		_, err := io.ReadFull(c, b)
+		res <- result{b, err}
+	}()
+	select {
+	case r := <-res:
+		t.Fatalf("Read returned %x, %v before the pool held 4 bytes", r.b, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.AddEntropy([]byte{3, 4, 5}, 0)
+	r := <-res
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if want := []byte{1, 2, 3, 4}; !bytes.Equal(r.b, want) {
+		t.Errorf("Read = %x, want %x", r.b, want)
+	}
+	if n := p.Available(); n != 1 {
+		t.Errorf("%d bytes left in the pool, want 1", n)
+	}
+}
+
+func TestAddEntropy(t *testing.T) {
+	p := newPool(nil)
+	c, _ := connect(t, p)
+	if err := c.AddEntropy([]byte{0xaa, 0xbb, 0xcc}, 20); err != nil {
+		t.Fatal(err)
+	}
+	// A write has no reply; the next request is answered after it.
+	bits, err := c.EntropyLevel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits != 24 {
+		t.Errorf("EntropyLevel after the write = %d, want 24", bits)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bits != 20 {
+		t.Errorf("credited %d bits, want 20", p.bits)
+	}
+	if want := []byte{0xaa, 0xbb, 0xcc}; !bytes.Equal(p.data, want) {
+		t.Errorf("pool holds %x, want %x", p.data, want)
+	}
+	if err := c.AddEntropy(make([]byte, 256), 8); err == nil {
+		t.Error("AddEntropy accepted 256 bytes")
+	}
+}
+
+func TestPID(t *testing.T) {
+	c, _ := connect(t, newPool(nil))
+	pid, err := c.PID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(os.Getpid()); pid != want {
+		t.Errorf("PID = %q, want %q", pid, want)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	c, logs := connect(t, newPool(make([]byte, 4)))
+	if _, err := c.conn.Write([]byte{0x05}); err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	if _, err := c.conn.Read(b[:]); !errors.Is(err, io.EOF) {
+		t.Errorf("read after an unknown command: %v, want the connection closed", err)
+	}
+	if msg := <-logs; !strings.Contains(msg, ErrUnknownCommand.Error()) {
+		t.Errorf("logged %q, want %q", msg, ErrUnknownCommand)
+	}
+}
+
+func TestServeLoopback(t *testing.T) {
+	ln, err := Listen("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Skipf("loopback unavailable: %v", err)
+	}
+	s := &Server{Source: newPool([]byte{9, 8, 7})}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ln) }()
+	c, err := Dial("tcp:" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ReadNonblocking(2)
+	c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{9, 8}; !bytes.Equal(got, want) {
+		t.Errorf("ReadNonblocking(2) = %x, want %x", got, want)
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve after Close: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package egd
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Server answers EGD requests from Source.
+type Server struct {
+	Source Source
+	// IdleTimeout closes connections that send no request for this long;
+	// zero means no limit.
+	IdleTimeout time.Duration
+	// Logf receives connection errors; nil means log.Printf.
+	Logf func(format string, args ...any)
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Serve accepts connections on ln until Close is called, serving each in its
+// own goroutine. It returns nil after Close.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.init()
+	s.ln = ln
+	s.mu.Unlock()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+			}()
+			s.ServeConn(c)
+		}()
+	}
+}
+
+// Close stops the listener and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	s.cancel()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return err
+}
+
+// init sets up the server's state. s.mu must be held.
+func (s *Server) init() {
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		s.conns = make(map[net.Conn]struct{})
+	}
+}
+
+// ServeConn answers requests on c until the client closes it, then closes
+// it.
+func (s *Server) ServeConn(c net.Conn) {
+	defer c.Close()
+	s.mu.Lock()
+	s.init()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	r := bufio.NewReader(c)
+	for {
+		if s.IdleTimeout > 0 {
+			_ = c.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		cmd, err := r.ReadByte()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logf("egd: %s: %v", c.RemoteAddr(), err)
+			}
+			return
+		}
+		_ = c.SetReadDeadline(time.Time{})
+		if err := s.handle(ctx, cmd, r, c); err != nil {
+			if ctx.Err() == nil {
+				s.logf("egd: %s: command 0x%02x: %v", c.RemoteAddr(), cmd, err)
+			}
+			return
+		}
+	}
+}
+
+// handle answers one command whose arguments are read from r.
+func (s *Server) handle(ctx context.Context, cmd byte, r *bufio.Reader, w io.Writer) error {
+	switch cmd {
+	case CmdEntropyLevel:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(min(s.Source.Available(), 1<<28)*8))
+		_, err := w.Write(b[:])
+		return err
+
+	case CmdReadNonblock:
+		n, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		k := min(int(n), s.Source.Available())
+		var data []byte
+		if k > 0 {
+			if data, err = s.Source.Read(ctx, k); err != nil {
+				return err
+			}
+		}
+		_, err = w.Write(append([]byte{byte(len(data))}, data...))
+		return err
+
+	case CmdReadBlock:
+		n, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		data, err := s.Source.Read(ctx, int(n))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+
+	case CmdWrite:
+		var hdr [3]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return err
+		}
+		data := make([]byte, hdr[2])
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if sink, ok := s.Source.(Sink); ok {
+			sink.AddEntropy(data, int(hdr[0])<<8|int(hdr[1]))
+		}
+		return nil
+
+	case CmdPID:
+		pid := strconv.Itoa(os.Getpid())
+		_, err := w.Write(append([]byte{byte(len(pid))}, pid...))
+		return err
+	}
+	return ErrUnknownCommand
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
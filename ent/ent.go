@@ -0,0 +1,236 @@
+// Package ent computes the quick randomness summary of John Walker's ent
+// program: Shannon entropy, the optimum compression it implies, a
+// chi-square test of the value distribution, the arithmetic mean, a Monte
+// Carlo estimate of pi and the serial correlation coefficient. The results
+// match ent's for the same input, in byte mode and in bit mode (ent -b).
+//
+// An Analyzer is an io.Writer, so captures of any size are analysed in one
+// streaming pass:
+//
+//	a := ent.New(ent.Options{})
+//	io.Copy(a, f)
+//	r, err := a.Result()
+package ent
+
+import (
+	"errors"
+	"math"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/stats"
+)
+
+// Modes.
+const (
+	ModeByte = "byte"
+	ModeBit  = "bit"
+)
+
+// Options configure an Analyzer.
+type Options struct {
+	// Bits analyses the data as a stream of bits rather than bytes, like
+	// ent -b.
+	Bits bool
+	// SampleBits is the sample size of a capture whose samples are not a
+	// whole number of bytes: the input is read as BytesFor(SampleBits)-byte
+	// samples and the zero padding ending each one is skipped, so it does
+	// not count as data. Zero or a multiple of 8 analyses every bit.
+	SampleBits int
+}
+
+// monteN is the number of bytes per Monte Carlo point: 24 bits for each
+// coordinate.
+const monteN = 6
+
+// inCircle is the squared radius of the Monte Carlo circle, (2^24-1)^2.
+const inCircle = (1<<24 - 1) * (1<<24 - 1)
+
+// Analyzer accumulates the statistics of the data written to it. It is not
+// safe for concurrent use.
+type Analyzer struct {
+	bits bool
+	// counts has 256 entries in byte mode and 2 in bit mode.
+	counts []int64
+	total  int64
+
+	monte    [monteN]byte
+	mp       int
+	points   int64
+	inside   int64
+	sccFirst float64
+	sccLast  float64
+	sccT1    float64
+	sccT2    float64
+	sccT3    float64
+
+	// Unpadding state: sample is the sample size in bytes, pad its padding
+	// bits, pos the offset in the current sample; acc holds nacc pending
+	// data bits (MSB first) not yet forming a byte.
+	sample, pad, pos int
+	acc              uint16
+	nacc             int
+}
+
+// New returns an Analyzer.
+func New(opts Options) *Analyzer {
+	a := &Analyzer{bits: opts.Bits, counts: make([]int64, 256)}
+	if opts.Bits {
+		a.counts = make([]int64, 2)
+	}
+	if opts.SampleBits > 0 && bitpack.Padding(opts.SampleBits) > 0 {
+		a.sample = bitpack.BytesFor(opts.SampleBits)
+		a.pad = bitpack.Padding(opts.SampleBits)
+	}
+	return a
+}
+
+// Write adds p to the analysis. It never fails.
+func (a *Analyzer) Write(p []byte) (int, error) {
+	if a.pad == 0 {
+		for _, b := range p {
+			a.addByte(b)
+		}
+		return len(p), nil
+	}
+	for _, b := range p {
+		a.pos++
+		keep := 8
+		if a.pos == a.sample {
+			a.pos = 0
+			keep = 8 - a.pad
+		}
+		a.acc = a.acc<<keep | uint16(b>>(8-keep))
+		a.nacc += keep
+		if a.nacc >= 8 {
+			a.nacc -= 8
+			a.addByte(byte(a.acc >> a.nacc))
+			a.acc &= 1<<a.nacc - 1
+		}
+	}
+	return len(p), nil
+}
+
+// addByte adds one byte of data, or its eight bits in bit mode.
+func (a *Analyzer) addByte(b byte) {
+	a.monte[a.mp] = b
+	a.mp++
+	if a.mp == monteN {
+		a.mp = 0
+		a.points++
+		x := uint64(a.monte[0])<<16 | uint64(a.monte[1])<<8 | uint64(a.monte[2])
+		y := uint64(a.monte[3])<<16 | uint64(a.monte[4])<<8 | uint64(a.monte[5])
+		if x*x+y*y <= inCircle {
+			a.inside++
+		}
+	}
+	if !a.bits {
+		a.addValue(int(b))
+		return
+	}
+	for i := 7; i >= 0; i-- {
+		a.addValue(int(b >> i & 1))
+	}
+}
+
+// addValue counts one value and updates the serial correlation sums.
+func (a *Analyzer) addValue(v int) {
+	a.counts[v]++
+	c := float64(v)
+	if a.total == 0 {
+		a.sccFirst = c
+	} else {
+		a.sccT1 += a.sccLast * c
+	}
+	a.sccT2 += c
+	a.sccT3 += c * c
+	a.sccLast = c
+	a.total++
+}
+
+// Result holds the statistics of the data analysed.
+type Result struct {
+	// Mode is ModeByte or ModeBit.
+	Mode string `json:"mode"`
+	// Count is the number of values analysed: bytes, or bits in bit mode.
+	Count int64 `json:"count"`
+	// Entropy is the Shannon entropy in bits per value: at most 8 per byte
+	// or 1 per bit.
+	Entropy float64 `json:"entropy"`
+	// Compression is the percentage by which optimum compression would
+	// reduce the data, given its entropy.
+	Compression float64 `json:"compression_percent"`
+	// ChiSquare tests the value counts against a uniform distribution, with
+	// 255 degrees of freedom (1 in bit mode). ChiSquareP is the probability
+	// that random data would exceed it; ent reports it as a percentage, and
+	// values below 0.01 or above 0.99 suggest the data is not random.
+	ChiSquare  float64 `json:"chi_square"`
+	ChiSquareP float64 `json:"chi_square_p"`
+	// Mean is the arithmetic mean of the values; random data gives 127.5
+	// (0.5 in bit mode).
+	Mean float64 `json:"mean"`
+	// MonteCarloPi estimates pi from points made of 6 bytes each (in both
+	// modes), with MonteCarloError its error in percent. They are nil for
+	// fewer than 6 bytes.
+	MonteCarloPi    *float64 `json:"monte_carlo_pi"`
+	MonteCarloError *float64 `json:"monte_carlo_error_percent"`
+	// SerialCorrelation correlates each value with the next (the last with
+	// the first); random data gives values near 0. It is nil when every
+	// value is the same.
+	SerialCorrelation *float64 `json:"serial_correlation"`
+	// Counts holds the number of occurrences of each value.
+	Counts []int64 `json:"counts"`
+}
+
+// ErrNoData is returned by Result when nothing has been analysed.
+var ErrNoData = errors.New("ent: no data")
+
+// Result returns the statistics of the data written so far. In bit mode,
+// data bits left over from a partial byte at the end of an unpadded stream
+// are included.
+func (a *Analyzer) Result() (Result, error) {
+	b := *a
+	b.counts = append([]int64(nil), a.counts...)
+	if b.bits {
+		for i := b.nacc - 1; i >= 0; i-- {
+			b.addValue(int(b.acc >> i & 1))
+		}
+	}
+	if b.total == 0 {
+		return Result{}, ErrNoData
+	}
+
+	r := Result{Mode: ModeByte, Count: b.total, Counts: b.counts}
+	if b.bits {
+		r.Mode = ModeBit
+	}
+	n := float64(b.total)
+	expected := n / float64(len(b.counts))
+	sum := 0.0
+	for v, c := range b.counts {
+		d := float64(c) - expected
+		r.ChiSquare += d * d / expected
+		sum += float64(v) * float64(c)
+		if c > 0 {
+			p := float64(c) / n
+			r.Entropy -= p * math.Log2(p)
+		}
+	}
+	maxEntropy := math.Log2(float64(len(b.counts)))
+	r.Compression = 100 * (maxEntropy - r.Entropy) / maxEntropy
+	r.ChiSquareP = stats.ChiSquareP(r.ChiSquare, len(b.counts)-1)
+	r.Mean = sum / n
+
+	if b.points > 0 {
+		pi := 4 * float64(b.inside) / float64(b.points)
+		e := 100 * math.Abs(math.Pi-pi) / math.Pi
+		r.MonteCarloPi, r.MonteCarloError = &pi, &e
+	}
+
+	t1 := b.sccT1 + b.sccLast*b.sccFirst
+	t2 := b.sccT2 * b.sccT2
+	if den := n*b.sccT3 - t2; den != 0 {
+		scc := (n*t1 - t2) / den
+		r.SerialCorrelation = &scc
+	}
+	return r, nil
+}
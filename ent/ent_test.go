@@ -0,0 +1,119 @@
+package ent
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// counter returns the bytes 0-255 repeated n times.
+func counter(n int) []byte {
+	b := make([]byte, 0, 256*n)
+	for range n {
+		for v := range 256 {
+			b = append(b, byte(v))
+		}
+	}
+	return b
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func TestKnownAnswers(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		bits        bool
+		count       int64
+		entropy     float64
+		compression float64
+		chiSquare   float64
+		chiSquareP  float64
+		mean        float64
+		pi          *float64
+		scc         *float64
+	}{
+		// One value only: chi-square is count*(values-1) and the serial
+		// correlation is undefined. Every point is (0, 0), inside the
+		// circle.
+		{"zeros", make([]byte, 600), false, 600, 0, 100, 600 * 255, 0, 0, ptr(4), nil},
+		{"zeros bits", make([]byte, 600), true, 4800, 0, 100, 4800, 0, 0, ptr(4), nil},
+		// A counter is perfectly uniform. Each value is followed by the
+		// next: sum(x[i]*x[i+1]) over the cycle gives 251/257. 121 of its
+		// 170 points fall inside the circle.
+		{"counter", counter(4), false, 1024, 8, 0, 0, 1, 127.5, ptr(4 * 121.0 / 170), ptr(251.0 / 257)},
+		// As bits, the counter has as many ones as zeros and the pairs
+		// 00, 01, 10 and 11 balance exactly. 30 of its 42 points are inside.
+		{"counter bits", counter(1), true, 2048, 1, 0, 0, 1, 0.5, ptr(4 * 30.0 / 42), ptr(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(Options{Bits: tt.bits})
+			// Split writes must not change the result.
+			half := len(tt.data) / 2
+			a.Write(tt.data[:half])
+			a.Write(tt.data[half:])
+			r, err := a.Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Count != tt.count {
+				t.Errorf("Count = %d, want %d", r.Count, tt.count)
+			}
+			check := func(name string, got, want float64) {
+				t.Helper()
+				if math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			check("Entropy", r.Entropy, tt.entropy)
+			check("Compression", r.Compression, tt.compression)
+			check("ChiSquare", r.ChiSquare, tt.chiSquare)
+			check("ChiSquareP", r.ChiSquareP, tt.chiSquareP)
+			check("Mean", r.Mean, tt.mean)
+			if r.MonteCarloPi == nil {
+				t.Fatal("MonteCarloPi = nil")
+			}
+			check("MonteCarloPi", *r.MonteCarloPi, *tt.pi)
+			check("MonteCarloError", *r.MonteCarloError, 100*math.Abs(math.Pi-*tt.pi)/math.Pi)
+			switch {
+			case tt.scc == nil && r.SerialCorrelation != nil:
+				t.Errorf("SerialCorrelation = %v, want nil", *r.SerialCorrelation)
+			case tt.scc != nil && r.SerialCorrelation == nil:
+				t.Errorf("SerialCorrelation = nil, want %v", *tt.scc)
+			case tt.scc != nil:
+				check("SerialCorrelation", *r.SerialCorrelation, *tt.scc)
+			}
+		})
+	}
+}
+
+func TestShortInput(t *testing.T) {
+	a := New(Options{})
+	if _, err := a.Result(); !errors.Is(err, ErrNoData) {
+		t.Errorf("Result with no data: err = %v, want ErrNoData", err)
+	}
+	a.Write([]byte{1, 2, 3, 4, 5})
+	r, err := a.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MonteCarloPi != nil || r.MonteCarloError != nil {
+		t.Error("Monte Carlo estimate from 5 bytes, want none")
+	}
+}
+
+func TestSampleBitsSkipsPadding(t *testing.T) {
+	// 12-bit samples 0xabc and 0xdef pad to ab c0 de f0; without the
+	// padding the data is ab cd ef.
+	padded := New(Options{SampleBits: 12})
+	padded.Write([]byte{0xab, 0xc0, 0xde, 0xf0})
+	plain := New(Options{})
+	plain.Write([]byte{0xab, 0xcd, 0xef})
+	got, _ := padded.Result()
+	want, _ := plain.Result()
+	if got.Count != want.Count || !slices.Equal(got.Counts, want.Counts) {
+		t.Errorf("SampleBits 12 counted %v, want %v", got.Counts, want.Counts)
+	}
+}
@@ -0,0 +1,248 @@
+// Package events records operator event markers during a collection run,
+// such as "intention start" or "high aim", and reads them back for reports.
+//
+// Events are stored next to the capture in <base>.events.csv (see Path): a
+// header row followed by one event per line,
+//
+//	timestamp,sample,kind,label
+//	20250910T14:45:40-03:00,120,start,intention
+//
+// timestamp is written with naming.FormatTimestamp, like the capture's .csv.
+// sample is the number of samples collected when the event was recorded, so
+// the event falls after that sample. kind is "mark" for a single point in
+// time, or "start" and "stop" to open and close a segment named by label.
+package events
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// Kind is the type of an event.
+type Kind string
+
+const (
+	// Mark is a single point in time.
+	Mark Kind = "mark"
+	// Start opens the segment named by the event's label.
+	Start Kind = "start"
+	// Stop closes the segment named by the event's label.
+	Stop Kind = "stop"
+)
+
+// ParseKind validates s as a Kind; the empty string means Mark.
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(strings.ToLower(strings.TrimSpace(s))); k {
+	case "":
+		return Mark, nil
+	case Mark, Start, Stop:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown event kind %q (want mark|start|stop)", s)
+}
+
+// Event is one recorded marker.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Sample is the number of samples collected when the event was recorded.
+	Sample int    `json:"sample"`
+	Kind   Kind   `json:"kind"`
+	Label  string `json:"label"`
+}
+
+// String formats the event for log output.
+func (e Event) String() string {
+	return fmt.Sprintf("%s %q after sample %d", e.Kind, e.Label, e.Sample)
+}
+
+// header is the first row of an events file.
+var header = []string{"timestamp", "sample", "kind", "label"}
+
+// Path returns the events file that accompanies a capture:
+// Path("data/X.bin") is "data/X.events.csv".
+func Path(capturePath string) string {
+	return naming.SidecarPath(capturePath, "events.csv")
+}
+
+// Log appends events to an events file. The file is created with the first
+// event, so runs without markers leave none behind. A Log is safe for
+// concurrent use.
+type Log struct {
+	// OnAdd, if set, is called with each event after it is written.
+	OnAdd func(Event)
+
+	path   string
+	mu     sync.Mutex
+	f      *os.File
+	w      *csv.Writer
+	sample int
+	events []Event
+}
+
+// NewLog returns a Log writing to path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// SetSample records the number of samples collected so far; it is stored
+// with subsequent events.
+func (l *Log) SetSample(n int) {
+	l.mu.Lock()
+	l.sample = n
+	l.mu.Unlock()
+}
+
+// Add records an event of the given kind now. Start and stop events need a
+// label to name their segment.
+func (l *Log) Add(kind Kind, label string) (Event, error) {
+	label = strings.TrimSpace(label)
+	switch kind {
+	case Mark, Start, Stop:
+	default:
+		return Event{}, fmt.Errorf("unknown event kind %q", kind)
+	}
+	if label == "" {
+		if kind != Mark {
+			return Event{}, fmt.Errorf("%s event needs a label", kind)
+		}
+		label = string(Mark)
+	}
+
+	l.mu.Lock()
+	e := Event{Time: time.Now(), Sample: l.sample, Kind: kind, Label: label}
+	err := l.write(e)
+	if err == nil {
+		l.events = append(l.events, e)
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return Event{}, err
+	}
+	if l.OnAdd != nil {
+		l.OnAdd(e)
+	}
+	return e, nil
+}
+
+// write appends e to the file, creating it if needed. l.mu must be held.
+func (l *Log) write(e Event) error {
+	if l.w == nil {
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			_ = w.Write(header)
+		}
+		l.f, l.w = f, w
+	}
+	_ = l.w.Write([]string{naming.FormatTimestamp(e.Time), strconv.Itoa(e.Sample), string(e.Kind), e.Label})
+	l.w.Flush()
+	return l.w.Error()
+}
+
+// Events returns the events recorded so far.
+func (l *Log) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.events...)
+}
+
+// Close closes the file, if one was created.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f, l.w = nil, nil
+	return err
+}
+
+// Read reads an events file. Zone-less timestamps are interpreted in loc
+// (nil means time.Local). A missing file yields an error satisfying
+// errors.Is(err, fs.ErrNotExist).
+func Read(path string, loc *time.Location) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = -1
+	var evs []Event
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return evs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(rec) > 0 && rec[0] == header[0] {
+			continue
+		}
+		if len(rec) < 4 {
+			return nil, fmt.Errorf("%s:%d: expected %s", path, line, strings.Join(header, ","))
+		}
+		t, err := naming.ParseTimestamp(rec[0], loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		sample, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid sample %q", path, line, rec[1])
+		}
+		kind, err := ParseKind(rec[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		evs = append(evs, Event{Time: t, Sample: sample, Kind: kind, Label: rec[3]})
+	}
+}
+
+// Segment is the span between a start event and the matching stop event.
+type Segment struct {
+	Label       string
+	Start, Stop Event
+	// Open is set if the segment was never stopped; Stop is then zero.
+	Open bool
+}
+
+// Segments pairs start and stop events with the same label, in order. A
+// stop without an open segment of that label is ignored; a start while one
+// is open is treated as stopping it first.
+func Segments(evs []Event) []Segment {
+	var segs []Segment
+	open := make(map[string]int)
+	for _, e := range evs {
+		switch e.Kind {
+		case Start:
+			if i, ok := open[e.Label]; ok {
+				segs[i].Stop, segs[i].Open = e, false
+			}
+			open[e.Label] = len(segs)
+			segs = append(segs, Segment{Label: e.Label, Start: e, Open: true})
+		case Stop:
+			if i, ok := open[e.Label]; ok {
+				segs[i].Stop, segs[i].Open = e, false
+				delete(open, e.Label)
+			}
+		}
+	}
+	return segs
+}
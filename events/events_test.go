@@ -0,0 +1,134 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLogRead(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "20261001T130000_trng_s2048_i1.bin"))
+	if filepath.Base(path) != "20261001T130000_trng_s2048_i1.events.csv" {
+		t.Fatalf("Path = %s", path)
+	}
+	l := NewLog(path)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Log without events created %s", path)
+	}
+
+	var added []Event
+	l.OnAdd = func(e Event) { added = append(added, e) }
+	steps := []struct {
+		sample int
+		kind   Kind
+		label  string
+	}{
+		{0, Start, "intention"},
+		{120, Mark, ""},
+		{240, Stop, " intention "},
+		{300, Mark, `aim "high", left`},
+	}
+	for _, s := range steps {
+		l.SetSample(s.sample)
+		if _, err := l.Add(s.kind, s.label); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Add(Start, " "); err == nil {
+		t.Error("Add(Start) without a label: no error")
+	}
+	if _, err := l.Add("pause", "x"); err == nil {
+		t.Error("Add of an unknown kind: no error")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// A second Log appends without repeating the header.
+	l2 := NewLog(path)
+	l2.SetSample(400)
+	if _, err := l2.Add(Mark, "resumed"); err != nil {
+		t.Fatal(err)
+	}
+	l2.Close()
+
+	wantLog := l.Events()
+	if !slices.Equal(added, wantLog) || len(wantLog) != len(steps) {
+		t.Fatalf("OnAdd saw %v, Events = %v", added, wantLog)
+	}
+	got, err := Read(path, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(wantLog, l2.Events()...)
+	wantLabels := []string{"intention", "mark", "intention", `aim "high", left`, "resumed"}
+	if len(got) != len(want) {
+		t.Fatalf("Read %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		w := want[i]
+		// The file keeps whole seconds.
+		if !e.Time.Equal(w.Time.Truncate(time.Second)) || e.Sample != w.Sample || e.Kind != w.Kind || e.Label != wantLabels[i] || e.Label != w.Label {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data string
+	}{
+		{"short row", "timestamp,sample,kind,label\n20261001T13:00:00,1,mark\n"},
+		{"bad time", "yesterday,1,mark,x\n"},
+		{"bad sample", "20261001T13:00:00,one,mark,x\n"},
+		{"bad kind", "20261001T13:00:00,1,pause,x\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name+".events.csv")
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if evs, err := Read(path, time.UTC); err == nil {
+			t.Errorf("%s: Read = %v, want an error", tt.name, evs)
+		}
+	}
+	if _, err := Read(filepath.Join(dir, "missing.events.csv"), nil); !os.IsNotExist(err) {
+		t.Errorf("Read of a missing file: err = %v, want not-exist", err)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	var evs []Event
+	ev := func(kind Kind, label string) Event {
+		e := Event{Sample: len(evs), Kind: kind, Label: label}
+		evs = append(evs, e)
+		return e
+	}
+	a1 := ev(Start, "a")
+	ev(Stop, "b") // stray: b is not open
+	b := ev(Start, "b")
+	ev(Mark, "a")
+	a2 := ev(Start, "a") // restart: closes the first a
+	a2Stop := ev(Stop, "a")
+	ev(Stop, "a") // stray: a is already closed
+	bStop := ev(Stop, "b")
+	c := ev(Start, "c")
+
+	want := []Segment{
+		{Label: "a", Start: a1, Stop: a2},
+		{Label: "b", Start: b, Stop: bStop},
+		{Label: "a", Start: a2, Stop: a2Stop},
+		{Label: "c", Start: c, Open: true},
+	}
+	if got := Segments(evs); !slices.Equal(got, want) {
+		t.Errorf("Segments =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := Segments(nil); len(got) != 0 {
+		t.Errorf("Segments(nil) = %v", got)
+	}
+}
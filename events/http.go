@@ -0,0 +1,55 @@
+package events
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+// Handler serves l over HTTP:
+//
+//	GET  lists the events recorded so far as a JSON array.
+//	POST records an event. The kind and label are taken from a JSON body
+//	     ({"kind": "start", "label": "intention"}) or from form or query
+//	     values; kind defaults to "mark". The event is returned as JSON.
+func Handler(l *Log) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			writeJSON(w, http.StatusOK, l.Events())
+		case http.MethodPost:
+			var req struct {
+				Kind  string `json:"kind"`
+				Label string `json:"label"`
+			}
+			if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+				if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+					http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			} else {
+				req.Kind, req.Label = r.FormValue("kind"), r.FormValue("label")
+			}
+			kind, err := ParseKind(req.Kind)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			e, err := l.Add(kind, req.Label)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusCreated, e)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	l := NewLog(filepath.Join(t.TempDir(), "x.events.csv"))
+	defer l.Close()
+	h := Handler(l)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		want        Event // kind and label of the recorded event
+	}{
+		{"json", "POST", "/events", "application/json", `{"kind":"start","label":"intention"}`, http.StatusCreated, Event{Kind: Start, Label: "intention"}},
+		{"json with charset", "POST", "/events", "application/json; charset=utf-8", `{"kind":"stop","label":"intention"}`, http.StatusCreated, Event{Kind: Stop, Label: "intention"}},
+		{"form", "POST", "/events", "application/x-www-form-urlencoded", url.Values{"kind": {"start"}, "label": {"high aim"}}.Encode(), http.StatusCreated, Event{Kind: Start, Label: "high aim"}},
+		{"query, default kind", "POST", "/events?label=blink", "", "", http.StatusCreated, Event{Kind: Mark, Label: "blink"}},
+		{"invalid json", "POST", "/events", "application/json", `{"kind":`, http.StatusBadRequest, Event{}},
+		{"unknown kind", "POST", "/events?kind=pause", "", "", http.StatusBadRequest, Event{}},
+		{"start without label", "POST", "/events?kind=start", "", "", http.StatusBadRequest, Event{}},
+		{"method", "DELETE", "/events", "", "", http.StatusMethodNotAllowed, Event{}},
+	}
+	var want []Event
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+			var e Event
+			if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Kind != tt.want.Kind || e.Label != tt.want.Label {
+				t.Errorf("recorded %s, want %s", e, tt.want)
+			}
+			want = append(want, e)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	var got []Event
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || len(got) != len(want) {
+		t.Fatalf("GET: status %d, %s, %d events, want 200, JSON and %d", w.Code, w.Header().Get("Content-Type"), len(got), len(want))
+	}
+	for i := range got {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Kind != want[i].Kind || got[i].Label != want[i].Label {
+			t.Errorf("GET event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
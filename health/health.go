@@ -0,0 +1,158 @@
+// Package health runs the continuous health tests of NIST SP 800-90B
+// section 4.4 on a stream of bits, to catch a noise source that has failed
+// (stuck at one value, or strongly biased) while it is being collected.
+//
+// Both tests treat the stream as binary samples with a claimed min-entropy
+// of H bits per bit:
+//
+//   - The repetition count test fails when one bit value repeats
+//     RepetitionCutoff times in a row.
+//   - The adaptive proportion test looks at windows of Window bits and fails
+//     when the window's first bit value occurs ProportionCutoff times in it.
+//
+// The cutoffs are set for a false positive probability of 2^-30 per bit
+// (SP 800-90B allows 2^-20 to 2^-40), about one false alarm per 10^9 bits
+// from a healthy source. The stream is continuous across samples.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Window is the adaptive proportion test's window size for binary sources.
+const Window = 1024
+
+// alphaLog2 is log2 of the false positive probability the cutoffs are set for.
+const alphaLog2 = -30
+
+// Test names, as reported in Failure.Test.
+const (
+	RepetitionCount    = "repetition count"
+	AdaptiveProportion = "adaptive proportion"
+)
+
+// Failure is one test failure.
+type Failure struct {
+	Test string
+	// Bit is the position in the stream, counted from 0, of the bit that
+	// made the test fail.
+	Bit int64
+	// Count reached Cutoff.
+	Count, Cutoff int
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s test failed at bit %d (%d >= cutoff %d)", f.Test, f.Bit, f.Count, f.Cutoff)
+}
+
+// Monitor runs both tests over the bits it is fed. It is not safe for
+// concurrent use.
+type Monitor struct {
+	rctCutoff, aptCutoff int
+
+	bits int64
+	// Repetition count state.
+	last byte
+	run  int
+	// Adaptive proportion state.
+	ref      byte
+	winPos   int
+	winCount int
+
+	failures int
+}
+
+// New returns a Monitor for a source claiming h bits of min-entropy per bit,
+// 0 < h <= 1. Whitened sources such as the TrueRNG and BitBabbler claim 1.
+func New(h float64) (*Monitor, error) {
+	if !(h > 0 && h <= 1) {
+		return nil, errors.New("health: min-entropy must be in (0, 1]")
+	}
+	// SP 800-90B 4.4.1: C = 1 + ceil(-log2(alpha) / H).
+	rct := 1 + int(math.Ceil(-alphaLog2/h))
+	// SP 800-90B 4.4.2: C = 1 + CRITBINOM(W, 2^-H, 1 - alpha).
+	apt := 1 + critBinom(Window, math.Exp2(-h), 1-math.Exp2(alphaLog2))
+	if apt > Window {
+		apt = Window
+	}
+	return &Monitor{rctCutoff: rct, aptCutoff: apt}, nil
+}
+
+// Cutoffs returns the repetition count and adaptive proportion cutoffs.
+func (m *Monitor) Cutoffs() (repetition, proportion int) {
+	return m.rctCutoff, m.aptCutoff
+}
+
+// Failures returns the number of failures so far.
+func (m *Monitor) Failures() int {
+	return m.failures
+}
+
+// Bits returns the number of bits tested so far.
+func (m *Monitor) Bits() int64 {
+	return m.bits
+}
+
+// Failing reports whether the stream is still in a failure: the current run
+// of one bit value, or the current window, has reached its cutoff. Feed
+// reports each failure once, so a stuck source is reported when it sticks
+// and Failing stays true until it recovers.
+func (m *Monitor) Failing() bool {
+	return m.run >= m.rctCutoff || m.winPos != 0 && m.winCount >= m.aptCutoff
+}
+
+// Feed tests the first bitCount bits of sample, packed MSB-first, and
+// returns the failures they caused. A test reports a failure once per run
+// or window, when its count reaches the cutoff.
+func (m *Monitor) Feed(sample []byte, bitCount int) []Failure {
+	var out []Failure
+	for i := 0; i < bitCount && i/8 < len(sample); i++ {
+		b := sample[i/8] >> (7 - uint(i%8)) & 1
+		pos := m.bits
+		m.bits++
+
+		if pos > 0 && b == m.last {
+			m.run++
+			if m.run == m.rctCutoff {
+				out = append(out, Failure{Test: RepetitionCount, Bit: pos, Count: m.run, Cutoff: m.rctCutoff})
+			}
+		} else {
+			m.last, m.run = b, 1
+		}
+
+		if m.winPos == 0 {
+			m.ref, m.winCount = b, 1
+		} else if b == m.ref {
+			m.winCount++
+			if m.winCount == m.aptCutoff {
+				out = append(out, Failure{Test: AdaptiveProportion, Bit: pos, Count: m.winCount, Cutoff: m.aptCutoff})
+			}
+		}
+		m.winPos = (m.winPos + 1) % Window
+	}
+	m.failures += len(out)
+	return out
+}
+
+// critBinom returns the smallest k such that the binomial(n, p) CDF at k is
+// at least q.
+func critBinom(n int, p, q float64) int {
+	// Sum the upper tail from n down, in which the small probabilities that
+	// matter here are accurate.
+	tail := 0.0
+	for k := n; k >= 0; k-- {
+		tail += binomPMF(n, k, p)
+		if tail > 1-q {
+			return k
+		}
+	}
+	return 0
+}
+
+// binomPMF returns the binomial(n, p) probability of k.
+func binomPMF(n, k int, p float64) float64 {
+	lg := func(x int) float64 { v, _ := math.Lgamma(float64(x) + 1); return v }
+	return math.Exp(lg(n) - lg(k) - lg(n-k) + float64(k)*math.Log(p) + float64(n-k)*math.Log1p(-p))
+}
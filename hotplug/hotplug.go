@@ -0,0 +1,167 @@
+// Package hotplug watches for BitBabbler and TrueRNG devices being attached
+// or detached. It polls the same enumeration used for one-shot detection
+// (bbusb.IsBitBabblerConnected and truerng.FindDevices), so it works wherever
+// those do and needs no OS-specific notification support.
+package hotplug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bbusb"
+	"github.com/Thiagojm/rng_go_cli/naming"
+	"github.com/Thiagojm/rng_go_cli/truerng"
+)
+
+// DefaultInterval is the polling interval used when Watch is given 0.
+const DefaultInterval = time.Second
+
+// Device identifies one attached hardware RNG.
+type Device struct {
+	// Kind is the device type: naming.DeviceBitBabbler or naming.DeviceTrueRNG.
+	Kind naming.Device
+	// ID identifies the device instance: the USB device path for a BitBabbler,
+	// the serial port for a TrueRNG.
+	ID string
+	// Name is a human-friendly label if available.
+	Name string
+}
+
+// Event reports a change in device presence.
+type Event struct {
+	// When the change was observed.
+	Time time.Time
+	// Device that was attached or detached. Zero if Err is set.
+	Device Device
+	// Attached is true for attach events and false for detach events.
+	Attached bool
+	// Err is non-nil if enumeration failed during this poll; presence is
+	// then left unchanged until the next successful poll.
+	Err error
+}
+
+// String formats the event as a single log line.
+func (e Event) String() string {
+	stamp := e.Time.Format(time.RFC3339)
+	if e.Err != nil {
+		return fmt.Sprintf("%s  error     %v", stamp, e.Err)
+	}
+	state := "detached"
+	if e.Attached {
+		state = "attached"
+	}
+	s := fmt.Sprintf("%s  %-8s  %-6s %s", stamp, state, string(e.Device.Kind), e.Device.ID)
+	if e.Device.Name != "" && e.Device.Name != e.Device.ID {
+		s += " (" + e.Device.Name + ")"
+	}
+	return s
+}
+
+// List enumerates the currently attached devices of the given kinds.
+// Only naming.DeviceBitBabbler and naming.DeviceTrueRNG are supported.
+func List(kinds ...naming.Device) ([]Device, error) {
+	var out []Device
+	for _, k := range kinds {
+		switch k {
+		case naming.DeviceBitBabbler:
+			_, infos, err := bbusb.IsBitBabblerConnected()
+			if err != nil {
+				return nil, fmt.Errorf("bitb detect: %w", err)
+			}
+			for _, d := range infos {
+				out = append(out, Device{Kind: k, ID: d.DevicePath, Name: d.FriendlyName})
+			}
+		case naming.DeviceTrueRNG:
+			ports, err := truerng.FindDevices()
+			if err != nil {
+				return nil, fmt.Errorf("trng detect: %w", err)
+			}
+			for _, p := range ports {
+				out = append(out, Device{Kind: k, ID: p.Name, Name: string(p.Model)})
+			}
+		default:
+			return nil, fmt.Errorf("hotplug: unsupported device %q", string(k))
+		}
+	}
+	return out, nil
+}
+
+// Watch polls for the given device kinds every interval and sends an Event
+// each time a device appears or disappears. Devices already attached when
+// Watch is called are reported as attached on the first poll, so consumers
+// can build their view of the system from events alone.
+//
+// The returned channel is closed when ctx is cancelled.
+func Watch(ctx context.Context, interval time.Duration, kinds ...naming.Device) (<-chan Event, error) {
+	if len(kinds) == 0 {
+		return nil, errors.New("hotplug: no device kinds to watch")
+	}
+	if interval < 0 {
+		return nil, errors.New("interval must be >= 0")
+	}
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	// Validate kinds up front so misuse surfaces here rather than as events.
+	for _, k := range kinds {
+		if k != naming.DeviceBitBabbler && k != naming.DeviceTrueRNG {
+			return nil, fmt.Errorf("hotplug: unsupported device %q", string(k))
+		}
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		known := make(map[Device]bool)
+		send := func(ev Event) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			devices, err := List(kinds...)
+			now := time.Now()
+			if err != nil {
+				if !send(Event{Time: now, Err: err}) {
+					return
+				}
+			} else {
+				seen := make(map[Device]bool, len(devices))
+				for _, d := range devices {
+					seen[d] = true
+					if !known[d] {
+						known[d] = true
+						if !send(Event{Time: now, Device: d, Attached: true}) {
+							return
+						}
+					}
+				}
+				for d := range known {
+					if !seen[d] {
+						delete(known, d)
+						if !send(Event{Time: now, Device: d, Attached: false}) {
+							return
+						}
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return out, nil
+}
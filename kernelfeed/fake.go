@@ -0,0 +1,63 @@
+package kernelfeed
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Sink for running a Feeder without root. Its level
+// rises with the credited entropy, up to Capacity, and falls by Drain bits
+// per second of WaitLow, as if the system were consuming randomness.
+type Fake struct {
+	Capacity int
+	// Drain is the consumption in bits per second.
+	Drain float64
+
+	mu    sync.Mutex
+	level float64
+	data  []byte
+}
+
+// NewFake returns an empty Fake pool.
+func NewFake(capacity int, drain float64) *Fake {
+	return &Fake{Capacity: capacity, Drain: drain}
+}
+
+// EntropyAvail returns the simulated level.
+func (f *Fake) EntropyAvail() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int(f.level), nil
+}
+
+// AddEntropy keeps data and raises the level by bits.
+func (f *Fake) AddEntropy(data []byte, bits int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data = append(f.data, data...)
+	f.level = min(float64(f.Capacity), f.level+float64(bits))
+	return nil
+}
+
+// WaitLow waits d, or until ctx is done, draining the level meanwhile.
+func (f *Fake) WaitLow(ctx context.Context, d time.Duration) error {
+	start := time.Now()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+	f.mu.Lock()
+	f.level = max(0, f.level-f.Drain*time.Since(start).Seconds())
+	f.mu.Unlock()
+	return nil
+}
+
+// Data returns everything added so far.
+func (f *Fake) Data() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]byte(nil), f.data...)
+}
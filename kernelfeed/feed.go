@@ -0,0 +1,196 @@
+// Package kernelfeed feeds conditioned, health-tested random data to the
+// operating system's entropy pool, like the rngd of rng-tools.
+//
+// A Feeder reads raw bytes from a device, runs them through the SP 800-90B
+// continuous health tests (package health), discarding any block in which a
+// test fails, and conditions each 64-byte block into 32 bytes with SHA-256.
+// The result is added to a Sink, crediting a configurable number of bits of
+// entropy per byte, whenever the sink's entropy estimate is below a low-water
+// mark, and at least every Refresh interval so fresh hardware output keeps
+// being mixed in.
+//
+// On Linux, OpenKernel returns a Sink that uses the RNDADDENTROPY ioctl on
+// /dev/random, which needs root (CAP_SYS_ADMIN). Fake is an in-memory Sink
+// for trying the feeder without it.
+package kernelfeed
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/health"
+)
+
+// Sink is an entropy pool.
+type Sink interface {
+	// EntropyAvail returns the pool's entropy estimate in bits.
+	EntropyAvail() (int, error)
+	// AddEntropy mixes data into the pool, crediting bits of entropy.
+	AddEntropy(data []byte, bits int) error
+	// WaitLow waits until the pool may need entropy, for at most d or until
+	// ctx is done.
+	WaitLow(ctx context.Context, d time.Duration) error
+}
+
+// Stats counts what a Feeder has done.
+type Stats struct {
+	// Writes and Bytes count the additions to the sink and their size.
+	Writes int64
+	Bytes  int64
+	// Credited is the entropy credited, in bits.
+	Credited int64
+	// Discarded counts raw blocks dropped after a health test failure.
+	Discarded int64
+}
+
+// blockIn and blockOut are the conditioning block sizes: SHA-256 compresses
+// each 64 raw bytes to 32.
+const (
+	blockIn  = 64
+	blockOut = sha256.Size
+)
+
+// Feeder moves entropy from Read to Sink.
+type Feeder struct {
+	Sink Sink
+	// Read returns n raw bytes from the device.
+	Read func(ctx context.Context, n int) ([]byte, error)
+	// Credit is the entropy credited per conditioned byte, in bits (0-8).
+	Credit float64
+	// LowWater is the entropy estimate, in bits, below which the sink is
+	// fed.
+	LowWater int
+	// Chunk is the number of conditioned bytes added at a time; it is
+	// rounded up to a multiple of 32. Zero means 512.
+	Chunk int
+	// Poll bounds each wait for the sink to run low. Zero means 1s.
+	Poll time.Duration
+	// Refresh feeds the sink at least this often even when it is not low;
+	// zero disables it.
+	Refresh time.Duration
+	// Monitor health-tests the raw bytes; nil uses a new health.Monitor
+	// claiming full entropy.
+	Monitor *health.Monitor
+	// Logf receives health failures and other notices; nil means
+	// log.Printf.
+	Logf func(format string, args ...any)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats returns the counts so far.
+func (f *Feeder) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// Run feeds the sink until ctx is done, returning nil then, or until the
+// device or the sink fails.
+func (f *Feeder) Run(ctx context.Context) error {
+	if f.Credit < 0 || f.Credit > 8 {
+		return errors.New("kernelfeed: credit must be 0-8 bits per byte")
+	}
+	chunk := f.Chunk
+	if chunk <= 0 {
+		chunk = 512
+	}
+	chunk = (chunk + blockOut - 1) / blockOut * blockOut
+	poll := f.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	if f.Monitor == nil {
+		m, err := health.New(1)
+		if err != nil {
+			return err
+		}
+		f.Monitor = m
+	}
+
+	lastFeed := time.Now()
+	for ctx.Err() == nil {
+		level, err := f.Sink.EntropyAvail()
+		if err != nil {
+			return fmt.Errorf("entropy level: %w", err)
+		}
+		due := f.Refresh > 0 && time.Since(lastFeed) >= f.Refresh
+		if level >= f.LowWater && !due {
+			wait := poll
+			if f.Refresh > 0 {
+				wait = min(wait, f.Refresh-time.Since(lastFeed))
+			}
+			if err := f.Sink.WaitLow(ctx, wait); err != nil && ctx.Err() == nil {
+				return fmt.Errorf("wait: %w", err)
+			}
+			continue
+		}
+
+		raw, err := f.Read(ctx, chunk/blockOut*blockIn)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		data, discarded := f.condition(raw)
+		if discarded > 0 {
+			f.mu.Lock()
+			f.stats.Discarded += int64(discarded)
+			f.mu.Unlock()
+		}
+		if len(data) == 0 {
+			continue
+		}
+		bits := int(f.Credit * float64(len(data)))
+		if err := f.Sink.AddEntropy(data, bits); err != nil {
+			return fmt.Errorf("add entropy: %w", err)
+		}
+		lastFeed = time.Now()
+		f.mu.Lock()
+		f.stats.Writes++
+		f.stats.Bytes += int64(len(data))
+		f.stats.Credited += int64(bits)
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// condition health-tests raw in 64-byte blocks and hashes each passing block
+// to 32 bytes. A trailing partial block is dropped. It returns the
+// conditioned bytes and the number of blocks discarded.
+func (f *Feeder) condition(raw []byte) ([]byte, int) {
+	out := make([]byte, 0, len(raw)/blockIn*blockOut)
+	discarded := 0
+	for len(raw) >= blockIn {
+		block := raw[:blockIn]
+		raw = raw[blockIn:]
+		fails := f.Monitor.Feed(block, 8*blockIn)
+		for _, e := range fails {
+			f.logf("kernelfeed: discarding block: %v", e)
+		}
+		// A failure is reported once, in the block where it starts; the
+		// blocks after it are discarded until the source recovers.
+		if len(fails) > 0 || f.Monitor.Failing() {
+			discarded++
+			continue
+		}
+		sum := sha256.Sum256(block)
+		out = append(out, sum[:]...)
+	}
+	return out, discarded
+}
+
+func (f *Feeder) logf(format string, args ...any) {
+	if f.Logf != nil {
+		f.Logf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,143 @@
+package kernelfeed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// device returns a Read function serving a fixed ChaCha8 stream, and a
+// function returning everything read so far.
+func device() (func(ctx context.Context, n int) ([]byte, error), func() []byte) {
+	c := rand.NewChaCha8([32]byte{1})
+	var mu sync.Mutex
+	var all []byte
+	read := func(ctx context.Context, n int) ([]byte, error) {
+		b := make([]byte, n)
+		_, _ = c.Read(b)
+		mu.Lock()
+		all = append(all, b...)
+		mu.Unlock()
+		return b, nil
+	}
+	return read, func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]byte(nil), all...)
+	}
+}
+
+// run runs f until done reports true or a second passes, then stops it.
+func run(t *testing.T, f *Feeder, done func(Stats) bool) Stats {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- f.Run(ctx) }()
+	deadline := time.Now().Add(time.Second)
+	for !done(f.Stats()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return f.Stats()
+}
+
+func TestCredit(t *testing.T) {
+	for _, credit := range []float64{0, 0.5, 2, 8} {
+		sink := NewFake(1<<20, 0)
+		read, raw := device()
+		f := &Feeder{Sink: sink, Read: read, Credit: credit, LowWater: 1 << 20, Chunk: 64, Poll: time.Millisecond, Logf: t.Logf}
+		st := run(t, f, func(s Stats) bool { return s.Writes >= 3 })
+		if st.Writes < 3 {
+			t.Fatalf("credit %g: %d writes, want at least 3", credit, st.Writes)
+		}
+		if want := int64(credit * float64(st.Bytes)); st.Credited != want {
+			t.Errorf("credit %g: credited %d bits for %d bytes, want %d", credit, st.Credited, st.Bytes, want)
+		}
+		if level, _ := sink.EntropyAvail(); int64(level) != st.Credited {
+			t.Errorf("credit %g: sink level %d, want %d", credit, level, st.Credited)
+		}
+		// Each 64 raw bytes are conditioned into their SHA-256 digest.
+		data, in := sink.Data(), raw()
+		if int64(len(data)) != st.Bytes {
+			t.Fatalf("credit %g: sink holds %d bytes, stats say %d", credit, len(data), st.Bytes)
+		}
+		for i := 0; i < len(data)/blockOut; i++ {
+			sum := sha256.Sum256(in[i*blockIn : (i+1)*blockIn])
+			if !bytes.Equal(data[i*blockOut:(i+1)*blockOut], sum[:]) {
+				t.Fatalf("credit %g: block %d is not the SHA-256 of its raw block", credit, i)
+			}
+		}
+	}
+}
+
+func TestFeedsOnlyWhenLow(t *testing.T) {
+	tests := []struct {
+		name      string
+		drain     float64 // bits per second
+		wantMore  bool    // whether feeding continues after the first write
+		wantLevel int
+	}{
+		{"pool stays full", 0, false, 256},
+		{"pool drains", 1e6, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewFake(4096, tt.drain)
+			read, _ := device()
+			// One 32-byte chunk at 8 bits per byte reaches the low-water mark.
+			f := &Feeder{Sink: sink, Read: read, Credit: 8, LowWater: 256, Chunk: 32, Poll: 5 * time.Millisecond, Logf: t.Logf}
+			start := time.Now()
+			st := run(t, f, func(s Stats) bool { return s.Writes >= 3 || time.Since(start) > 100*time.Millisecond })
+			if more := st.Writes > 1; more != tt.wantMore {
+				t.Errorf("%d writes; fed again after reaching the low-water mark: %v, want %v", st.Writes, more, tt.wantMore)
+			}
+			if level, _ := sink.EntropyAvail(); !tt.wantMore && level != tt.wantLevel {
+				t.Errorf("sink level %d, want %d", level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestNoFeedAboveLowWater(t *testing.T) {
+	sink := NewFake(4096, 0)
+	_ = sink.AddEntropy(nil, 1000)
+	read, raw := device()
+	f := &Feeder{Sink: sink, Read: read, Credit: 8, LowWater: 512, Poll: time.Millisecond, Logf: t.Logf}
+	start := time.Now()
+	st := run(t, f, func(Stats) bool { return time.Since(start) > 100*time.Millisecond })
+	if st.Writes != 0 || len(raw()) != 0 {
+		t.Errorf("%d writes, %d bytes read from a device while the pool was above the low-water mark", st.Writes, len(raw()))
+	}
+}
+
+func TestHealthFailureStopsFeeding(t *testing.T) {
+	sink := NewFake(1<<20, 0)
+	// A stuck device fails the repetition count test and never recovers.
+	read := func(ctx context.Context, n int) ([]byte, error) {
+		return make([]byte, n), nil
+	}
+	f := &Feeder{Sink: sink, Read: read, Credit: 8, LowWater: 1 << 20, Chunk: 64, Poll: time.Millisecond, Logf: func(string, ...any) {}}
+	st := run(t, f, func(s Stats) bool { return s.Discarded >= 10 })
+	if st.Discarded < 10 {
+		t.Fatalf("%d blocks discarded, want at least 10", st.Discarded)
+	}
+	if st.Writes != 0 || st.Credited != 0 || len(sink.Data()) != 0 {
+		t.Errorf("fed %d writes (%d bits, %d bytes) from a failing device", st.Writes, st.Credited, len(sink.Data()))
+	}
+}
+
+func TestInvalidCredit(t *testing.T) {
+	for _, credit := range []float64{-1, 8.5} {
+		f := &Feeder{Sink: NewFake(1, 0), Credit: credit}
+		if err := f.Run(context.Background()); err == nil {
+			t.Errorf("Run with credit %g: no error", credit)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package kernelfeed
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// wakeupThresholdPath holds the level below which the kernel wakes writers
+// of /dev/random.
+const wakeupThresholdPath = "/proc/sys/kernel/random/write_wakeup_threshold"
+
+// Kernel is the Linux kernel's entropy pool, fed through /dev/random.
+type Kernel struct {
+	f *os.File
+}
+
+// OpenKernel opens the kernel's pool through path, normally /dev/random.
+// Adding entropy needs CAP_SYS_ADMIN.
+func OpenKernel(path string) (*Kernel, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Kernel{f: f}, nil
+}
+
+// Close closes the device.
+func (k *Kernel) Close() error {
+	return k.f.Close()
+}
+
+// WakeupThreshold returns the kernel's write_wakeup_threshold, a natural
+// low-water mark.
+func (k *Kernel) WakeupThreshold() (int, error) {
+	b, err := os.ReadFile(wakeupThresholdPath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// EntropyAvail returns the kernel's entropy estimate (RNDGETENTCNT).
+func (k *Kernel) EntropyAvail() (int, error) {
+	return unix.IoctlGetInt(int(k.f.Fd()), unix.RNDGETENTCNT)
+}
+
+// AddEntropy mixes data into the pool and credits bits (RNDADDENTROPY).
+func (k *Kernel) AddEntropy(data []byte, bits int) error {
+	// struct rand_pool_info { int entropy_count; int buf_size; __u32 buf[]; }
+	info := make([]byte, 8+len(data))
+	binary.NativeEndian.PutUint32(info[0:], uint32(bits))
+	binary.NativeEndian.PutUint32(info[4:], uint32(len(data)))
+	copy(info[8:], data)
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, k.f.Fd(), unix.RNDADDENTROPY, uintptr(unsafe.Pointer(&info[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// WaitLow polls /dev/random for writability, which older kernels signal
+// when the estimate drops below write_wakeup_threshold. Kernels since 5.18
+// always report it writable; the wait then lasts d.
+func (k *Kernel) WaitLow(ctx context.Context, d time.Duration) error {
+	start := time.Now()
+	fds := []unix.PollFd{{Fd: int32(k.f.Fd()), Events: unix.POLLOUT}}
+	for {
+		left := d - time.Since(start)
+		if left <= 0 || ctx.Err() != nil {
+			return nil
+		}
+		// Short polls keep the wait responsive to ctx.
+		n, err := unix.Poll(fds, int(min(left, 250*time.Millisecond)/time.Millisecond)+1)
+		if err != nil && err != unix.EINTR {
+			return err
+		}
+		if n > 0 {
+			level, err := k.EntropyAvail()
+			if err != nil {
+				return err
+			}
+			if threshold, terr := k.WakeupThreshold(); terr == nil && level < threshold {
+				return nil
+			}
+			// Always writable: wait out the rest.
+			t := time.NewTimer(d - time.Since(start))
+			select {
+			case <-ctx.Done():
+			case <-t.C:
+			}
+			t.Stop()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package kernelfeed
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Kernel is the kernel's entropy pool; it is only available on Linux.
+type Kernel struct{}
+
+// OpenKernel is not supported on this platform.
+func OpenKernel(path string) (*Kernel, error) {
+	return nil, errors.New("feeding the kernel entropy pool is only supported on Linux")
+}
+
+func (k *Kernel) Close() error                           { return nil }
+func (k *Kernel) WakeupThreshold() (int, error)          { return 0, errors.New("not supported") }
+func (k *Kernel) EntropyAvail() (int, error)             { return 0, errors.New("not supported") }
+func (k *Kernel) AddEntropy(data []byte, bits int) error { return errors.New("not supported") }
+func (k *Kernel) WaitLow(ctx context.Context, d time.Duration) error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,205 @@
+// Package metrics writes metrics in the Prometheus text exposition format
+// (version 0.0.4), without depending on the Prometheus client library.
+//
+// Values are written at scrape time from whatever state the caller keeps:
+//
+//	w := metrics.NewWriter(rw)
+//	w.Counter("rng_samples_total", "Samples collected.", labels, float64(n))
+//	w.Gauge("rng_cumulative_z", "Cumulative z-score.", labels, z)
+//	err := w.Err()
+//
+// Histogram accumulates observations for the histogram type.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Content-Type of the text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Labels are the label names and values of a series.
+type Labels map[string]string
+
+// Writer writes metric families. Each family (name) must be written once,
+// with all its series, before the next.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first write error.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Counter writes a counter family with one series.
+func (w *Writer) Counter(name, help string, labels Labels, v float64) {
+	w.header(name, help, "counter")
+	w.sample(name, labels, v)
+}
+
+// Gauge writes a gauge family with one series.
+func (w *Writer) Gauge(name, help string, labels Labels, v float64) {
+	w.header(name, help, "gauge")
+	w.sample(name, labels, v)
+}
+
+// CounterVec writes a counter family with one series per value of the
+// label named by key; labels are added to every series.
+func (w *Writer) CounterVec(name, help string, labels Labels, key string, values map[string]float64) {
+	w.header(name, help, "counter")
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.sample(name, with(labels, key, k), values[k])
+	}
+}
+
+// Histogram writes a histogram family with the observations in h.
+func (w *Writer) Histogram(name, help string, labels Labels, h *Histogram) {
+	w.header(name, help, "histogram")
+	bounds, counts, sum, count := h.snapshot()
+	var cum uint64
+	for i, b := range bounds {
+		cum += counts[i]
+		w.sample(name+"_bucket", with(labels, "le", formatFloat(b)), float64(cum))
+	}
+	w.sample(name+"_bucket", with(labels, "le", "+Inf"), float64(count))
+	w.sample(name+"_sum", labels, sum)
+	w.sample(name+"_count", labels, float64(count))
+}
+
+func (w *Writer) header(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+func (w *Writer) sample(name string, labels Labels, v float64) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), formatFloat(v))
+}
+
+func (w *Writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// Handler serves the metrics written by write on each request.
+func Handler(write func(w *Writer)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", ContentType)
+		write(NewWriter(rw))
+	})
+}
+
+// Histogram counts observations in buckets with the given upper bounds. It
+// is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns a Histogram with the given bucket upper bounds, which
+// must be increasing. The +Inf bucket is implicit.
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// ExponentialBuckets returns n bounds starting at start, each factor times
+// the previous one.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = start
+		start *= factor
+	}
+	return b
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) snapshot() (bounds []float64, counts []uint64, sum float64, count uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bounds, append([]uint64(nil), h.counts...), h.sum, h.count
+}
+
+// with returns labels plus name=value.
+func with(labels Labels, name, value string) Labels {
+	out := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + `="` + escapeLabel(labels[k]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
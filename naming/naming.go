@@ -4,26 +4,33 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Device represents the data source used to collect random bits.
-// Allowed values are: "trng" (TrueRNG3), "bitb" (BitBabbler), and "pseudo" (PRNG).
+// Allowed values are: "trng" (TrueRNG3), "bitb" (BitBabbler), "pseudo" (PRNG),
+// "replay" (an earlier capture played back) and "drbg" (SP 800-90A DRBG
+// seeded from a hardware source).
 type Device string
 
 const (
 	DeviceTrueRNG    Device = "trng"
 	DeviceBitBabbler Device = "bitb"
 	DevicePseudo     Device = "pseudo"
+	DeviceReplay     Device = "replay"
+	DeviceDRBG       Device = "drbg"
 )
 
 // Validate checks whether d is one of the allowed device identifiers.
 func (d Device) Validate() error {
-	if d == DeviceTrueRNG || d == DeviceBitBabbler || d == DevicePseudo {
+	switch d {
+	case DeviceTrueRNG, DeviceBitBabbler, DevicePseudo, DeviceReplay, DeviceDRBG:
 		return nil
 	}
-	return fmt.Errorf("invalid device: %q (allowed: trng, bitb, pseudo)", string(d))
+	return fmt.Errorf("invalid device: %q (allowed: trng, bitb, pseudo, replay, drbg)", string(d))
 }
 
 // BuildBaseName builds the base filename using the convention:
@@ -31,7 +38,7 @@ func (d Device) Validate() error {
 //	YYYYMMDDTHHMMSS_{device}_s{bits}_i{interval}
 //
 // where:
-// - device ∈ {trng, bitb, pseudo}
+// - device ∈ {trng, bitb, pseudo, replay, drbg}
 // - bits > 0 is the sample size in bits per collection
 // - interval > 0 is the interval in seconds between collections
 // The timestamp is generated from the provided time instant.
@@ -88,3 +95,126 @@ func BuildBinCSVPaths(dir string, now time.Time, device Device, bits int, interv
 	}
 	return JoinDir(dir, binName), JoinDir(dir, csvName), nil
 }
+
+// SidecarPath returns the path of a file that accompanies a capture, sharing
+// its base name: SidecarPath("data/X.bin", "meta.json") is "data/X.meta.json".
+func SidecarPath(capturePath string, suffix string) string {
+	return WithExt(strings.TrimSuffix(capturePath, filepath.Ext(capturePath)), suffix)
+}
+
+// BaseInfo holds the fields encoded in a base name by BuildBaseName.
+type BaseInfo struct {
+	Time            time.Time
+	Device          Device
+	Bits            int
+	IntervalSeconds int
+}
+
+var baseNameRe = regexp.MustCompile(`(\d{8}T\d{6})_([a-z]+)_s(\d+)_i(\d+)`)
+
+// ParseBaseName extracts the fields of the naming convention from a file name
+// or path, e.g. "data/20201011T142208_bitb_s2048_i1.bin". The timestamp is
+// interpreted in local time, matching BuildBaseName.
+func ParseBaseName(path string) (BaseInfo, error) {
+	name := filepath.Base(path)
+	m := baseNameRe.FindStringSubmatch(name)
+	if m == nil {
+		return BaseInfo{}, fmt.Errorf("file name does not follow the naming convention: %s", name)
+	}
+	t, err := time.ParseInLocation("20060102T150405", m[1], time.Local)
+	if err != nil {
+		return BaseInfo{}, err
+	}
+	dev := Device(m[2])
+	if err := dev.Validate(); err != nil {
+		return BaseInfo{}, err
+	}
+	bits, err := strconv.Atoi(m[3])
+	if err != nil {
+		return BaseInfo{}, err
+	}
+	interval, err := strconv.Atoi(m[4])
+	if err != nil {
+		return BaseInfo{}, err
+	}
+	return BaseInfo{Time: t, Device: dev, Bits: bits, IntervalSeconds: interval}, nil
+}
+
+// TimestampLayout is the layout of the timestamp column in collector .csv
+// files: local wall-clock time with its UTC offset, e.g.
+// "20250910T14:45:40-03:00" ("Z" for UTC), so a capture reads back as the
+// same instant wherever it is analysed.
+const TimestampLayout = "20060102T15:04:05Z07:00"
+
+// zonelessLayout is the layout written before timestamps carried an offset,
+// e.g. "20250910T14:45:40". ParseTimestamp still reads it, in the given
+// location.
+const zonelessLayout = "20060102T15:04:05"
+
+// timestampLayouts are tried in order by ParseTimestamp. Layouts with a zone
+// offset are honoured as written; the others are read in the given location.
+var timestampLayouts = []string{
+	TimestampLayout,
+	zonelessLayout,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+// clockLayouts are time-only layouts, accepted by ParseTimestampOn.
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// FormatTimestamp formats t for the timestamp column of a collector .csv,
+// in t's own location.
+func FormatTimestamp(t time.Time) string {
+	return t.Format(TimestampLayout)
+}
+
+// ParseTimestamp parses a .csv timestamp written by FormatTimestamp or in
+// one of the other common layouts (the zone-less collector layout, RFC 3339,
+// "2006-01-02 15:04:05", ...). Timestamps without a zone offset are
+// interpreted in loc; nil means time.Local, which is what the collector wrote
+// before it recorded the offset. Time-only values are
+// rejected, as they carry no date; see ParseTimestampOn.
+func ParseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	return ParseTimestampOn(s, loc, time.Time{})
+}
+
+// ParseTimestampOn is like ParseTimestamp but also accepts time-only values
+// ("15:04:05", "15:04"), which are placed on start's date in loc, where
+// start is typically the capture time from the file name. A time-only value
+// more than 12 hours before start is taken to be on the following day, so a
+// capture that runs past midnight keeps increasing. With a zero start,
+// time-only values are rejected.
+func ParseTimestampOn(s string, loc *time.Location, start time.Time) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range clockLayouts {
+		c, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if start.IsZero() {
+			return time.Time{}, fmt.Errorf("time-only timestamp has no date: %q", s)
+		}
+		y, m, d := start.In(loc).Date()
+		t := time.Date(y, m, d, c.Hour(), c.Minute(), c.Second(), c.Nanosecond(), loc)
+		if t.Before(start.Add(-12 * time.Hour)) {
+			t = time.Date(y, m, d+1, c.Hour(), c.Minute(), c.Second(), c.Nanosecond(), loc)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp: %q", s)
+}
@@ -0,0 +1,76 @@
+package naming
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestampOn(t *testing.T) {
+	loc := time.FixedZone("UTC-3", -3*3600)
+	start := time.Date(2026, 10, 1, 13, 0, 0, 0, loc)
+	tests := []struct {
+		name    string
+		in      string
+		start   time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{"collector layout", "20261001T13:00:05+01:00", start, time.Date(2026, 10, 1, 12, 0, 5, 0, time.UTC), false},
+		{"collector layout in utc", "20261001T13:00:05Z", start, time.Date(2026, 10, 1, 13, 0, 5, 0, time.UTC), false},
+		{"zone-less collector layout", "20261001T13:00:05", start, time.Date(2026, 10, 1, 13, 0, 5, 0, loc), false},
+		{"zone honoured", "2026-10-01T16:00:05Z", start, time.Date(2026, 10, 1, 16, 0, 5, 0, time.UTC), false},
+		{"clock on start date", "13:00:05", start, time.Date(2026, 10, 1, 13, 0, 5, 0, loc), false},
+		{"clock without seconds", "14:30", start, time.Date(2026, 10, 1, 14, 30, 0, 0, loc), false},
+		{"clock just before start", "12:59:59", start, time.Date(2026, 10, 1, 12, 59, 59, 0, loc), false},
+		{"clock past midnight", "00:00:01", start, time.Date(2026, 10, 2, 0, 0, 1, 0, loc), false},
+		{"clock without start", "13:00:05", time.Time{}, time.Time{}, true},
+		{"garbage", "yesterday", start, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestampOn(tt.in, loc, tt.start)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTimestampOn(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimestampOn(%q): %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTimestampOn(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampRejectsClock(t *testing.T) {
+	for _, s := range []string{"13:00:05", "13:00"} {
+		if got, err := ParseTimestamp(s, time.UTC); err == nil {
+			t.Errorf("ParseTimestamp(%q) = %v, want error", s, got)
+		}
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2025, 9, 10, 14, 45, 40, 0, time.FixedZone("", -3*3600)), "20250910T14:45:40-03:00"},
+		{time.Date(2025, 9, 10, 14, 45, 40, 999e6, time.FixedZone("", 5*3600+1800)), "20250910T14:45:40+05:30"},
+		{time.Date(2025, 9, 10, 14, 45, 40, 0, time.UTC), "20250910T14:45:40Z"},
+	}
+	for _, tt := range tests {
+		got := FormatTimestamp(tt.t)
+		if got != tt.want {
+			t.Errorf("FormatTimestamp(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+		// The offset is honoured whatever location the reader passes.
+		back, err := ParseTimestamp(got, time.FixedZone("", 9*3600))
+		if err != nil || !back.Equal(tt.t.Truncate(time.Second)) {
+			t.Errorf("ParseTimestamp(%q) = %v, %v, want %v", got, back, err, tt.t.Truncate(time.Second))
+		}
+	}
+}
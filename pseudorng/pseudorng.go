@@ -5,31 +5,30 @@ import (
 	crand "crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	mrand "math/rand"
+	mrand2 "math/rand/v2"
 	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
 )
 
 // Detect for pseudorng always returns true, since software RNG is always available.
 func Detect() (bool, error) { return true, nil }
 
-// ReadBits returns bitCount pseudorandom bits as bytes, MSB-first per byte.
+// ReadBits returns bitCount random bits, most significant bit first.
 // The final byte may be partially filled with zeros in the unused trailing bits.
+// The bits come from crypto/rand and cannot be replayed; use a Generator for
+// reproducible output.
 func ReadBits(bitCount int) ([]byte, error) {
 	if bitCount <= 0 {
 		return nil, errors.New("bitCount must be positive")
 	}
-	byteCount := (bitCount + 7) / 8
-	// Use crypto/rand as entropy for default generation, then fill with math/rand for speed if needed.
-	buf := make([]byte, byteCount)
+	buf := make([]byte, bitpack.BytesFor(bitCount))
 	if _, err := crand.Read(buf); err != nil {
 		return nil, err
 	}
-	// Zero out unused trailing bits for clarity.
-	extraBits := (8 - (bitCount % 8)) % 8
-	if extraBits != 0 {
-		mask := byte(0xFF << extraBits)
-		buf[len(buf)-1] &= mask
-	}
+	bitpack.Mask(buf, bitCount)
 	return buf, nil
 }
 
@@ -70,15 +69,64 @@ func CollectBitsAtInterval(ctx context.Context, bitCount int, interval time.Dura
 	}
 }
 
+// Algorithm selects the PRNG behind a Generator.
+type Algorithm string
+
+const (
+	// AlgoChaCha8 uses math/rand/v2's ChaCha8. The 32-byte key is the seed
+	// encoded little-endian in the first 8 bytes followed by 24 zero bytes, and
+	// the byte stream is exactly ChaCha8.Read's output.
+	AlgoChaCha8 Algorithm = "chacha8"
+	// AlgoPCG uses math/rand/v2's PCG seeded with (seed, 0). The byte stream is
+	// the sequence of Uint64 outputs, each encoded little-endian.
+	AlgoPCG Algorithm = "pcg"
+	// AlgoLegacy uses math/rand's source seeded with int64(seed), producing one
+	// byte per Intn(256) call. It is kept so captures made with earlier
+	// versions of this package can be reproduced.
+	AlgoLegacy Algorithm = "legacy"
+)
+
+// Algorithms lists the supported algorithms.
+var Algorithms = []Algorithm{AlgoChaCha8, AlgoPCG, AlgoLegacy}
+
+// ParseAlgorithm validates an algorithm name.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if string(a) == s {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("invalid algorithm: %q (allowed: chacha8, pcg, legacy)", s)
+}
+
 // Generator is a deterministic PRNG wrapper that can be seeded for reproducible streams.
-// It uses math/rand/v2 with a 64-bit seed; seed material can be derived from crypto/rand.
+//
+// The output is a fixed byte stream determined by the algorithm and seed (see
+// the Algorithm constants); each ReadBits call consumes ceil(bitCount/8) bytes
+// of it, so the same seed replays the same samples for the same sample size.
+// For seed 1 the stream begins:
+//
+//	chacha8: 6a e6 78 3f 4f bd e9 1b
+//	pcg:     03 29 ed ab 29 a1 27 99
+//	legacy:  21 0f c7 bb 81 86 39 ac
 type Generator struct {
-	r *mrand.Rand
+	algo Algorithm
+	seed uint64
+	// next fills buf with the next bytes of the stream.
+	next func(buf []byte)
 }
 
-// NewGenerator creates a new pseudorandom generator. If seed is zero, a random
-// seed is drawn from crypto/rand.
+// NewGenerator creates a new pseudorandom generator using AlgoLegacy, which
+// keeps streams from existing seeds unchanged. If seed is zero, a random seed
+// is drawn from crypto/rand.
 func NewGenerator(seed uint64) (*Generator, error) {
+	return NewGeneratorWithAlgorithm(AlgoLegacy, seed)
+}
+
+// NewGeneratorWithAlgorithm creates a generator for algo. If seed is zero, a
+// random seed is drawn from crypto/rand; Seed reports the one in use so the
+// stream can be replayed.
+func NewGeneratorWithAlgorithm(algo Algorithm, seed uint64) (*Generator, error) {
 	if seed == 0 {
 		var s [8]byte
 		if _, err := crand.Read(s[:]); err != nil {
@@ -86,34 +134,77 @@ func NewGenerator(seed uint64) (*Generator, error) {
 		}
 		seed = binary.LittleEndian.Uint64(s[:])
 	}
-	return &Generator{r: mrand.New(mrand.NewSource(int64(seed)))}, nil
+	g := &Generator{algo: algo, seed: seed}
+	switch algo {
+	case AlgoChaCha8:
+		var key [32]byte
+		binary.LittleEndian.PutUint64(key[:8], seed)
+		c := mrand2.NewChaCha8(key)
+		g.next = func(buf []byte) { _, _ = c.Read(buf) }
+	case AlgoPCG:
+		p := mrand2.NewPCG(seed, 0)
+		var word [8]byte
+		left := 0 // unread bytes remaining at the end of word
+		g.next = func(buf []byte) {
+			for i := range buf {
+				if left == 0 {
+					binary.LittleEndian.PutUint64(word[:], p.Uint64())
+					left = len(word)
+				}
+				buf[i] = word[len(word)-left]
+				left--
+			}
+		}
+	case AlgoLegacy:
+		r := mrand.New(mrand.NewSource(int64(seed)))
+		g.next = func(buf []byte) {
+			for i := range buf {
+				buf[i] = byte(r.Intn(256))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid algorithm: %q", string(algo))
+	}
+	return g, nil
 }
 
+// Algorithm returns the generator's algorithm.
+func (g *Generator) Algorithm() Algorithm { return g.algo }
+
+// Seed returns the seed in use, including one drawn at random.
+func (g *Generator) Seed() uint64 { return g.seed }
+
 // ReadBits reads bitCount bits from the deterministic generator.
 func (g *Generator) ReadBits(bitCount int) ([]byte, error) {
-	if g == nil || g.r == nil {
+	if g == nil || g.next == nil {
 		return nil, errors.New("generator is nil")
 	}
 	if bitCount <= 0 {
 		return nil, errors.New("bitCount must be positive")
 	}
-	byteCount := (bitCount + 7) / 8
-	buf := make([]byte, byteCount)
-	for i := 0; i < byteCount; i++ {
-		buf[i] = byte(g.r.Intn(256))
-	}
-	// Zero out unused trailing bits
-	extraBits := (8 - (bitCount % 8)) % 8
-	if extraBits != 0 {
-		mask := byte(0xFF << extraBits)
-		buf[len(buf)-1] &= mask
-	}
+	buf := make([]byte, bitpack.BytesFor(bitCount))
+	g.next(buf)
+	bitpack.Mask(buf, bitCount)
 	return buf, nil
 }
 
+// Read implements io.Reader: it fills p with the next len(p) bytes of the
+// stream and never fails, so ReadBits(8*n) and n bytes of Read output are
+// the same bytes.
+func (g *Generator) Read(p []byte) (int, error) {
+	if g == nil || g.next == nil {
+		return 0, errors.New("generator is nil")
+	}
+	g.next(p)
+	return len(p), nil
+}
+
+// Close implements io.Closer; a Generator holds no resources.
+func (g *Generator) Close() error { return nil }
+
 // CollectBitsAtInterval runs the deterministic generator at a fixed interval.
 func (g *Generator) CollectBitsAtInterval(ctx context.Context, bitCount int, interval time.Duration, onBatch func([]byte)) error {
-	if g == nil || g.r == nil {
+	if g == nil || g.next == nil {
 		return errors.New("generator is nil")
 	}
 	if bitCount <= 0 {
@@ -0,0 +1,106 @@
+package pseudorng
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// goldenSeed1 are the first bytes of each algorithm's stream for seed 1, as
+// listed in the Generator documentation.
+var goldenSeed1 = []struct {
+	algo Algorithm
+	want string
+}{
+	{AlgoChaCha8, "6ae6783f4fbde91b"},
+	{AlgoPCG, "0329edab29a12799"},
+	{AlgoLegacy, "210fc7bb818639ac"},
+}
+
+func TestGeneratorGolden(t *testing.T) {
+	for _, tt := range goldenSeed1 {
+		t.Run(string(tt.algo), func(t *testing.T) {
+			g, err := NewGeneratorWithAlgorithm(tt.algo, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := g.ReadBits(64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("ReadBits(64) = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGeneratorIsLegacy(t *testing.T) {
+	g, err := NewGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Algorithm() != AlgoLegacy || g.Seed() != 1 {
+		t.Fatalf("NewGenerator(1) = %s seed %d, want legacy seed 1", g.Algorithm(), g.Seed())
+	}
+}
+
+func TestGeneratorSplitReads(t *testing.T) {
+	for _, tt := range goldenSeed1 {
+		t.Run(string(tt.algo), func(t *testing.T) {
+			whole := newTestGenerator(t, tt.algo)
+			split := newTestGenerator(t, tt.algo)
+			reader := newTestGenerator(t, tt.algo)
+
+			want, _ := whole.ReadBits(64)
+			a, _ := split.ReadBits(24)
+			b, _ := split.ReadBits(40)
+			if got := append(a, b...); !bytes.Equal(got, want) {
+				t.Errorf("ReadBits(24)+ReadBits(40) = %x, want %x", got, want)
+			}
+			got := make([]byte, 8)
+			for i := range got {
+				if _, err := reader.Read(got[i : i+1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("byte-wise Read = %x, want %x", got, want)
+			}
+
+			// A sample that is not a whole number of bytes still consumes
+			// whole bytes of the stream, with the padding bits cleared.
+			next, _ := whole.ReadBits(16)
+			odd, _ := split.ReadBits(12)
+			if odd[0] != next[0] || odd[1] != next[1]&0xf0 {
+				t.Errorf("ReadBits(12) = %x, want %x masked to 12 bits", odd, next)
+			}
+		})
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	if _, err := NewGeneratorWithAlgorithm("xorshift", 1); err == nil {
+		t.Error("NewGeneratorWithAlgorithm accepted an unknown algorithm")
+	}
+	if _, err := ParseAlgorithm("xorshift"); err == nil {
+		t.Error("ParseAlgorithm accepted an unknown algorithm")
+	}
+	g := newTestGenerator(t, AlgoChaCha8)
+	if _, err := g.ReadBits(0); err == nil {
+		t.Error("ReadBits(0) did not fail")
+	}
+	var nilGen *Generator
+	if _, err := nilGen.ReadBits(8); err == nil {
+		t.Error("ReadBits on a nil Generator did not fail")
+	}
+}
+
+func newTestGenerator(t *testing.T, algo Algorithm) *Generator {
+	t.Helper()
+	g, err := NewGeneratorWithAlgorithm(algo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
@@ -0,0 +1,83 @@
+package randutil
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Buffered reads from a source in blocks of a fixed size and serves smaller
+// reads from the block, amortising a per-read cost such as a TrueRNG port
+// reopen or a BitBabbler MPSSE command across many calls. Each byte is
+// handed out once.
+//
+// Unlike bufio.Reader, Read fills p completely unless the source fails, and
+// a Buffered is safe for concurrent use, so one device can serve several
+// goroutines.
+type Buffered struct {
+	mu  sync.Mutex
+	r   io.Reader
+	buf []byte
+	// pending is the unread tail of buf.
+	pending []byte
+}
+
+// NewBuffered returns a Buffered reading size bytes at a time from r; size
+// <= 0 means 4096.
+func NewBuffered(r io.Reader, size int) *Buffered {
+	if size <= 0 {
+		size = 4096
+	}
+	return &Buffered{r: r, buf: make([]byte, size)}
+}
+
+// Read fills p, refilling the block from the source as needed. Requests of
+// at least a block go straight to the source once the pending bytes are
+// used. It returns fewer than len(p) bytes only with an error.
+func (b *Buffered) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.r == nil {
+		return 0, errors.New("randutil: buffered reader is closed")
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	for n < len(p) {
+		if len(p)-n >= len(b.buf) {
+			k, err := io.ReadFull(b.r, p[n:])
+			return n + k, err
+		}
+		k, err := io.ReadFull(b.r, b.buf)
+		b.pending = b.buf[:k]
+		c := copy(p[n:], b.pending)
+		b.pending = b.pending[c:]
+		n += c
+		if err != nil && n < len(p) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Buffered returns the number of bytes that can be read without touching
+// the source.
+func (b *Buffered) Buffered() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Close wipes the pending bytes and closes the source if it is an
+// io.Closer.
+func (b *Buffered) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.buf)
+	b.pending = nil
+	r := b.r
+	b.r = nil
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package randutil
+
+import (
+	_ "embed"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Alphabets for Password.
+const (
+	Lower    = "abcdefghijklmnopqrstuvwxyz"
+	Upper    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits   = "0123456789"
+	Symbols  = "!#$%&*+-=?@^_~"
+	AlphaNum = Lower + Upper + Digits
+)
+
+//go:embed words.txt
+var wordsTxt string
+
+// wordlist holds 512 short, common English words, so each word of a
+// passphrase adds 9 bits of entropy.
+var wordlist = strings.Fields(wordsTxt)
+
+// Wordlist returns a copy of the built-in passphrase word list.
+func Wordlist() []string {
+	return append([]string(nil), wordlist...)
+}
+
+// Password returns length characters drawn uniformly and independently
+// from alphabet, which must be non-empty ASCII. Its entropy is
+// PasswordEntropy(length, alphabet) bits.
+func (r *Rand) Password(length int, alphabet string) (string, error) {
+	if length <= 0 {
+		return "", errors.New("randutil: Password: length must be > 0")
+	}
+	if err := checkAlphabet(alphabet); err != nil {
+		return "", err
+	}
+	b := make([]byte, length)
+	for i := range b {
+		j, err := r.IntN(len(alphabet))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[j]
+	}
+	return string(b), nil
+}
+
+// checkAlphabet rejects empty alphabets, non-ASCII characters (which would
+// be split into bytes) and repeated characters (which would be favoured).
+func checkAlphabet(alphabet string) error {
+	if alphabet == "" {
+		return errors.New("randutil: empty alphabet")
+	}
+	var seen [128]bool
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if c >= 128 {
+			return errors.New("randutil: alphabet must be ASCII")
+		}
+		if seen[c] {
+			return errors.New("randutil: alphabet repeats " + string(c))
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// PasswordEntropy returns the entropy in bits of a Password of length
+// characters from alphabet.
+func PasswordEntropy(length int, alphabet string) float64 {
+	return entropyBits(length, len(alphabet))
+}
+
+// Passphrase returns words words chosen uniformly and independently from
+// the built-in word list, joined by sep. Each word adds 9 bits of entropy,
+// so 6 words give 54 bits and 8 give 72.
+func (r *Rand) Passphrase(words int, sep string) (string, error) {
+	return r.PassphraseFrom(wordlist, words, sep)
+}
+
+// PassphraseFrom is Passphrase with a caller-supplied list, such as the
+// EFF large word list. The entropy is PassphraseEntropy(words, len(list))
+// bits provided the list has no duplicates.
+func (r *Rand) PassphraseFrom(list []string, words int, sep string) (string, error) {
+	if words <= 0 {
+		return "", errors.New("randutil: Passphrase: words must be > 0")
+	}
+	if len(list) == 0 {
+		return "", errors.New("randutil: Passphrase: empty word list")
+	}
+	out := make([]string, words)
+	for i := range out {
+		w, err := Choice(r, list)
+		if err != nil {
+			return "", err
+		}
+		out[i] = w
+	}
+	return strings.Join(out, sep), nil
+}
+
+// PassphraseEntropy returns the entropy in bits of words words drawn from a
+// list of listSize distinct words.
+func PassphraseEntropy(words, listSize int) float64 {
+	return entropyBits(words, listSize)
+}
+
+// entropyBits returns the entropy of count independent uniform choices
+// among n values, in bits.
+func entropyBits(count, n int) float64 {
+	if count <= 0 || n <= 1 {
+		return 0
+	}
+	return float64(count) * math.Log2(float64(n))
+}
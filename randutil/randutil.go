@@ -0,0 +1,291 @@
+// Package randutil turns a stream of random bytes from any source (a
+// TrueRNG, a BitBabbler, the pseudo generator, a DRBG or a capture file)
+// into the values programs actually need: bounded integers, floats,
+// Gaussian deviates, shuffles, choices, UUIDs, passwords and passphrases.
+//
+// Bounded values are unbiased: IntN and friends use rejection sampling
+// rather than taking the raw value modulo n, which would favour the low
+// results whenever n does not divide 2^64.
+//
+// Every method reads from the source as needed and returns its error. Each
+// call reads only what it uses, so a source with a high per-read cost should
+// be wrapped with NewBuffered first. A Rand is not safe for concurrent use.
+package randutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+)
+
+// Rand produces random values from a byte source.
+type Rand struct {
+	r io.Reader
+	// spare is the second deviate of the last Marsaglia polar pair.
+	spare    float64
+	hasSpare bool
+}
+
+// New returns a Rand reading from r.
+func New(r io.Reader) *Rand {
+	return &Rand{r: r}
+}
+
+// FromBits adapts a ReadBits-style function, such as truerng.ReadBits, to an
+// io.Reader for New. Each Read asks for exactly the bits needed to fill p.
+// truerng.Reader, bbusb.DeviceSession and pseudorng.Generator are
+// io.Readers already.
+func FromBits(read func(bitCount int) ([]byte, error)) io.Reader {
+	return bitsReader(read)
+}
+
+type bitsReader func(bitCount int) ([]byte, error)
+
+func (f bitsReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, err := f(8 * len(p))
+	n := copy(p, b)
+	if err == nil && n < len(p) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Read fills p from the source.
+func (r *Rand) Read(p []byte) (int, error) {
+	return io.ReadFull(r.r, p)
+}
+
+// Uint64 returns a uniform 64-bit value.
+func (r *Rand) Uint64() (uint64, error) {
+	var b [8]byte
+	if _, err := r.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// Uint32 returns a uniform 32-bit value.
+func (r *Rand) Uint32() (uint32, error) {
+	var b [4]byte
+	if _, err := r.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// Uint64N returns a uniform value in [0, n). n must be > 0.
+func (r *Rand) Uint64N(n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, errors.New("randutil: Uint64N: n must be > 0")
+	}
+	if n&(n-1) == 0 {
+		v, err := r.Uint64()
+		return v & (n - 1), err
+	}
+	// Reject the lowest 2^64 mod n values so every residue is equally
+	// likely; at most half the draws are rejected.
+	threshold := -n % n
+	for {
+		v, err := r.Uint64()
+		if err != nil {
+			return 0, err
+		}
+		if v >= threshold {
+			return v % n, nil
+		}
+	}
+}
+
+// IntN returns a uniform value in [0, n). n must be > 0.
+func (r *Rand) IntN(n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("randutil: IntN: n must be > 0")
+	}
+	v, err := r.Uint64N(uint64(n))
+	return int(v), err
+}
+
+// IntRange returns a uniform value in [lo, hi], both inclusive, e.g.
+// IntRange(1, 6) for a die roll.
+func (r *Rand) IntRange(lo, hi int64) (int64, error) {
+	if hi < lo {
+		return 0, fmt.Errorf("randutil: IntRange: hi (%d) < lo (%d)", hi, lo)
+	}
+	// span is the number of possible values; 0 stands for 2^64.
+	span := uint64(hi) - uint64(lo) + 1
+	if span == 0 {
+		v, err := r.Uint64()
+		return int64(v), err
+	}
+	v, err := r.Uint64N(span)
+	return lo + int64(v), err
+}
+
+// Float64 returns a uniform value in [0, 1) with 53 random bits.
+func (r *Rand) Float64() (float64, error) {
+	v, err := r.Uint64()
+	// 53 random bits fill the mantissa exactly.
+	return float64(v>>11) / (1 << 53), err
+}
+
+// NormFloat64 returns a standard normal deviate (mean 0, standard deviation
+// 1), using the Marsaglia polar method. Deviates come in pairs; the second
+// is kept for the next call.
+func (r *Rand) NormFloat64() (float64, error) {
+	if r.hasSpare {
+		r.hasSpare = false
+		return r.spare, nil
+	}
+	for {
+		u, err := r.Float64()
+		if err != nil {
+			return 0, err
+		}
+		v, err := r.Float64()
+		if err != nil {
+			return 0, err
+		}
+		u, v = 2*u-1, 2*v-1
+		s := u*u + v*v
+		if s == 0 || s >= 1 {
+			continue
+		}
+		m := math.Sqrt(-2 * math.Log(s) / s)
+		r.spare, r.hasSpare = v*m, true
+		return u * m, nil
+	}
+}
+
+// Gaussian returns a normal deviate with the given mean and standard
+// deviation.
+func (r *Rand) Gaussian(mean, stddev float64) (float64, error) {
+	z, err := r.NormFloat64()
+	return mean + stddev*z, err
+}
+
+// Shuffle permutes n elements uniformly with the Fisher-Yates algorithm,
+// calling swap to exchange elements i and j.
+func (r *Rand) Shuffle(n int, swap func(i, j int)) error {
+	if n < 0 {
+		return errors.New("randutil: Shuffle: n must be >= 0")
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := r.IntN(i + 1)
+		if err != nil {
+			return err
+		}
+		swap(i, j)
+	}
+	return nil
+}
+
+// Perm returns a uniform permutation of [0, n).
+func (r *Rand) Perm(n int) ([]int, error) {
+	if n < 0 {
+		return nil, errors.New("randutil: Perm: n must be >= 0")
+	}
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	err := r.Shuffle(n, func(i, j int) { p[i], p[j] = p[j], p[i] })
+	return p, err
+}
+
+// Choice returns a uniformly chosen element of items.
+func Choice[T any](r *Rand, items []T) (T, error) {
+	var zero T
+	if len(items) == 0 {
+		return zero, errors.New("randutil: Choice: no items")
+	}
+	i, err := r.IntN(len(items))
+	if err != nil {
+		return zero, err
+	}
+	return items[i], nil
+}
+
+// WeightedIndex returns i with probability weights[i] / sum(weights).
+// Weights must be finite and >= 0, with a positive sum.
+func (r *Rand) WeightedIndex(weights []float64) (int, error) {
+	total := 0.0
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return 0, fmt.Errorf("randutil: weight %d is %v; weights must be finite and >= 0", i, w)
+		}
+		total += w
+	}
+	if total <= 0 || math.IsInf(total, 0) {
+		return 0, errors.New("randutil: weights must have a positive, finite sum")
+	}
+	u, err := r.Float64()
+	if err != nil {
+		return 0, err
+	}
+	x := u * total
+	last := 0
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		if x < w {
+			return i, nil
+		}
+		x -= w
+		last = i
+	}
+	// Rounding can leave x just past the end; the last positive weight
+	// owns that sliver.
+	return last, nil
+}
+
+// WeightedChoice returns items[i] with probability weights[i] /
+// sum(weights). items and weights must have the same length.
+func WeightedChoice[T any](r *Rand, items []T, weights []float64) (T, error) {
+	var zero T
+	if len(items) != len(weights) {
+		return zero, fmt.Errorf("randutil: WeightedChoice: %d items but %d weights", len(items), len(weights))
+	}
+	i, err := r.WeightedIndex(weights)
+	if err != nil {
+		return zero, err
+	}
+	return items[i], nil
+}
+
+// UUID returns a random (version 4) UUID in its canonical text form.
+func (r *Rand) UUID() (string, error) {
+	var b [16]byte
+	if _, err := r.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Source returns a math/rand/v2 Source drawing from r, so device output can
+// drive rand.New(r.Source()) and any code written against math/rand/v2.
+// The rand.Source interface has no way to report errors, so its Uint64
+// panics if the underlying source fails.
+func (r *Rand) Source() rand.Source {
+	return source{r}
+}
+
+type source struct {
+	r *Rand
+}
+
+func (s source) Uint64() uint64 {
+	v, err := s.r.Uint64()
+	if err != nil {
+		panic("randutil: source failed: " + err.Error())
+	}
+	return v
+}
@@ -0,0 +1,246 @@
+package randutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// script returns a reader serving vs as big-endian 64-bit values, the way
+// Uint64 reads them.
+func script(vs ...uint64) *bytes.Reader {
+	b := make([]byte, 0, 8*len(vs))
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint64(b, v)
+	}
+	return bytes.NewReader(b)
+}
+
+func TestUint64N(t *testing.T) {
+	const half = 1 << 63
+	tests := []struct {
+		name   string
+		n      uint64
+		stream []uint64
+		want   uint64
+		used   int // values read
+	}{
+		// 2^64 mod 6 = 4, so 0-3 are rejected.
+		{"below threshold", 6, []uint64{0, 3, 4}, 4, 3},
+		{"at threshold", 6, []uint64{4}, 4, 1},
+		{"top value", 6, []uint64{math.MaxUint64}, 3, 1},
+		// 2^64 mod 3 = 1: only 0 is rejected.
+		{"single rejection", 3, []uint64{0, 1}, 1, 2},
+		// 2^64 mod (2^63+1) = 2^63-1, the worst case: half the draws go.
+		{"half rejected", half + 1, []uint64{0, half - 2, half - 1}, half - 1, 3},
+		{"wraps", half + 1, []uint64{half + 8}, 7, 1},
+		// Powers of two mask and never reject.
+		{"power of two", 8, []uint64{0}, 0, 1},
+		{"power of two masked", 8, []uint64{math.MaxUint64}, 7, 1},
+		{"one", 1, []uint64{12345}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := script(tt.stream...)
+			got, err := New(src).Uint64N(tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Uint64N(%d) = %d, want %d", tt.n, got, tt.want)
+			}
+			if used := len(tt.stream) - src.Len()/8; used != tt.used {
+				t.Errorf("Uint64N(%d) read %d values, want %d", tt.n, used, tt.used)
+			}
+		})
+	}
+}
+
+func TestUint64NErrors(t *testing.T) {
+	if _, err := New(script(1)).Uint64N(0); err == nil {
+		t.Error("Uint64N(0): no error")
+	}
+	// Every value is rejected until the source runs dry.
+	if _, err := New(script(0, 1, 2, 3)).Uint64N(6); !errors.Is(err, io.EOF) {
+		t.Errorf("Uint64N(6) on rejected values only: err = %v, want EOF", err)
+	}
+}
+
+func TestIntRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		lo, hi int64
+		stream []uint64
+		want   int64
+	}{
+		{"die", 1, 6, []uint64{2, 9}, 4},
+		{"single value", -5, -5, []uint64{math.MaxUint64}, -5},
+		{"negative", -10, -1, []uint64{math.MaxUint64}, -5},
+		{"full span low", math.MinInt64, math.MaxInt64, []uint64{0}, 0},
+		{"full span high bit", math.MinInt64, math.MaxInt64, []uint64{1 << 63}, math.MinInt64},
+		{"full span top", math.MinInt64, math.MaxInt64, []uint64{math.MaxUint64}, -1},
+		// A span of 2^64-1 rejects only 0.
+		{"almost full span", math.MinInt64, math.MaxInt64 - 1, []uint64{0, 5}, math.MinInt64 + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(script(tt.stream...)).IntRange(tt.lo, tt.hi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IntRange(%d, %d) = %d, want %d", tt.lo, tt.hi, got, tt.want)
+			}
+		})
+	}
+	if _, err := New(script(0)).IntRange(2, 1); err == nil {
+		t.Error("IntRange(2, 1): no error")
+	}
+}
+
+func TestUUID(t *testing.T) {
+	tests := []struct {
+		fill byte
+		want string
+	}{
+		{0x00, "00000000-0000-4000-8000-000000000000"},
+		{0xff, "ffffffff-ffff-4fff-bfff-ffffffffffff"},
+		{0x5a, "5a5a5a5a-5a5a-4a5a-9a5a-5a5a5a5a5a5a"},
+	}
+	for _, tt := range tests {
+		got, err := New(bytes.NewReader(bytes.Repeat([]byte{tt.fill}, 16))).UUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UUID from %#02x bytes = %s, want %s", tt.fill, got, tt.want)
+		}
+	}
+}
+
+func TestPerm(t *testing.T) {
+	// i=2: 0 is rejected (2^64 mod 3 = 1), 4%3 = 1 swaps 2 and 1.
+	// i=1: 2&1 = 0 swaps 1 and 0.
+	got, err := New(script(0, 4, 2)).Perm(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("Perm(3) = %v, want %v", got, want)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	var swaps [][2]int
+	err := New(script(7, 0, 1)).Shuffle(3, func(i, j int) { swaps = append(swaps, [2]int{i, j}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// i=2: 7%3 = 1. i=1: IntN(2) masks, 0&1 = 0.
+	if want := [][2]int{{2, 1}, {1, 0}}; !slices.Equal(swaps, want) {
+		t.Errorf("Shuffle(3) swaps = %v, want %v", swaps, want)
+	}
+	if err := New(script()).Shuffle(1, func(i, j int) { t.Error("Shuffle(1) swapped") }); err != nil {
+		t.Errorf("Shuffle(1) read the source: %v", err)
+	}
+}
+
+func TestChoice(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	// 0 is rejected, 7%3 = 1.
+	got, err := Choice(New(script(0, 7)), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "b" {
+		t.Errorf("Choice = %q, want %q", got, "b")
+	}
+	if _, err := Choice(New(script(0)), []string(nil)); err == nil {
+		t.Error("Choice of no items: no error")
+	}
+}
+
+func TestWeightedIndex(t *testing.T) {
+	// Float64 is the top 53 bits of the value over 2^53.
+	const half = 1 << 63
+	tests := []struct {
+		name    string
+		weights []float64
+		v       uint64
+		want    int
+	}{
+		{"start", []float64{1, 0, 3}, 0, 0},
+		{"middle", []float64{1, 0, 3}, half, 2},
+		{"end", []float64{1, 0, 3}, math.MaxUint64, 2},
+		{"first boundary", []float64{1, 1}, half - 1<<11, 0},
+		{"second boundary", []float64{1, 1}, half, 1},
+		{"zero weight skipped", []float64{0, 1}, 0, 1},
+		{"trailing zero weight", []float64{1, 0}, math.MaxUint64, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(script(tt.v)).WeightedIndex(tt.weights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("WeightedIndex(%v) with %#x = %d, want %d", tt.weights, tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedChoice(t *testing.T) {
+	got, err := WeightedChoice(New(script(1<<63)), []string{"x", "y", "z"}, []float64{1, 0, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "z" {
+		t.Errorf("WeightedChoice = %q, want %q", got, "z")
+	}
+	for _, weights := range [][]float64{{1, 2}, {1, -1, 1}, {0, 0, 0}, {1, math.NaN(), 1}, {1, math.Inf(1), 1}} {
+		if _, err := WeightedChoice(New(script(0)), []string{"x", "y", "z"}, weights); err == nil {
+			t.Errorf("WeightedChoice with weights %v: no error", weights)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	r := rand.New(New(script(42, math.MaxUint64)).Source())
+	if got := r.Uint64(); got != 42 {
+		t.Errorf("Uint64 = %d, want 42", got)
+	}
+	if got := r.Uint64(); got != math.MaxUint64 {
+		t.Errorf("Uint64 = %d, want %d", got, uint64(math.MaxUint64))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Uint64 on an exhausted source did not panic")
+		}
+	}()
+	r.Uint64()
+}
+
+func TestFromBits(t *testing.T) {
+	var asked []int
+	read := func(bitCount int) ([]byte, error) {
+		asked = append(asked, bitCount)
+		return bytes.Repeat([]byte{0xab}, bitCount/8), nil
+	}
+	got, err := New(FromBits(read)).Uint32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 0xabababab || !slices.Equal(asked, []int{32}) {
+		t.Errorf("Uint32 = %#x after asking for %v bits, want 0xabababab after [32]", got, asked)
+	}
+	short := func(bitCount int) ([]byte, error) { return []byte{1}, nil }
+	if _, err := New(FromBits(short)).Uint32(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Uint32 from a short read: err = %v, want ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package replay plays back a .bin capture written by cmd/collect as if it
+// were a live device, one recorded sample at a time. It is meant for
+// exercising analyses and the collector pipeline without hardware.
+//
+// Only the raw .bin format is supported: samples are read back-to-back as
+// ceil(bits/8) bytes each, in the bitpack layout. The sample size comes from
+// the file name (see naming.ParseBaseName) unless overridden, and original
+// timing is taken from the sibling .csv file when requested.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+	"github.com/Thiagojm/rng_go_cli/naming"
+)
+
+// Options configures a replay.
+type Options struct {
+	// Bits per sample. If 0, it is taken from the capture's file name.
+	Bits int
+	// Offset is the number of samples to skip before the first one returned.
+	Offset int
+	// Loop restarts at Offset when the end of the capture is reached instead
+	// of returning io.EOF.
+	Loop bool
+	// Realtime makes Next wait between samples for the gaps recorded in the
+	// sibling .csv file, falling back to the file name's interval when the
+	// .csv is missing or shorter than the .bin.
+	Realtime bool
+}
+
+// Sample is one recorded sample.
+type Sample struct {
+	// Index is the zero-based sample position within the capture.
+	Index int
+	// Data holds ceil(bits/8) bytes exactly as recorded.
+	Data []byte
+	// Recorded is the original timestamp from the .csv, or zero if unknown.
+	Recorded time.Time
+}
+
+// Source replays a capture. It is not safe for concurrent use.
+type Source struct {
+	f        *os.File
+	r        *bufio.Reader
+	opts     Options
+	info     naming.BaseInfo
+	bits     int
+	bytesPer int
+	times    []time.Time
+	index    int
+	last     time.Time // when the previous sample was returned (Realtime)
+	lastRec  time.Time // recorded time of the previous sample (Realtime)
+}
+
+// Open prepares path (a .bin capture) for replay.
+func Open(path string, opts Options) (*Source, error) {
+	if opts.Offset < 0 {
+		return nil, errors.New("offset must be >= 0")
+	}
+	if opts.Bits < 0 {
+		return nil, errors.New("bits must be >= 0")
+	}
+	info, infoErr := naming.ParseBaseName(path)
+	bits := opts.Bits
+	if bits == 0 {
+		if infoErr != nil {
+			return nil, fmt.Errorf("bits not given and %w", infoErr)
+		}
+		bits = info.Bits
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{f: f, opts: opts, info: info, bits: bits, bytesPer: bitpack.BytesFor(bits)}
+	if opts.Realtime {
+		// A missing .csv is not an error: pacing falls back to the interval.
+		s.times, _ = readTimestamps(naming.SidecarPath(path, "csv"))
+	}
+	if err := s.rewind(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Bits returns the number of bits per sample.
+func (s *Source) Bits() int { return s.bits }
+
+// Info returns the fields parsed from the capture's file name. It is the
+// zero value if the name does not follow the naming convention.
+func (s *Source) Info() naming.BaseInfo { return s.info }
+
+// Interval returns the capture's sampling interval from its file name, or 0
+// if unknown.
+func (s *Source) Interval() time.Duration {
+	return time.Duration(s.info.IntervalSeconds) * time.Second
+}
+
+// Next returns the next sample, waiting first if Realtime is set. At the end
+// of the capture it returns io.EOF unless Loop is set. A trailing partial
+// sample is ignored.
+func (s *Source) Next(ctx context.Context) (Sample, error) {
+	buf := make([]byte, s.bytesPer)
+	_, err := io.ReadFull(s.r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		if !s.opts.Loop || s.index == s.opts.Offset {
+			// Nothing was replayed since the last rewind: the capture is too short.
+			return Sample{}, io.EOF
+		}
+		if err := s.rewind(); err != nil {
+			return Sample{}, err
+		}
+		_, err = io.ReadFull(s.r, buf)
+	}
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return Sample{}, err
+	}
+
+	smp := Sample{Index: s.index, Data: buf}
+	if s.index < len(s.times) {
+		smp.Recorded = s.times[s.index]
+	}
+	s.index++
+
+	if s.opts.Realtime {
+		if err := s.pace(ctx, smp.Recorded); err != nil {
+			return Sample{}, err
+		}
+	}
+	return smp, nil
+}
+
+// ReadBits returns the next sample's bytes. bitCount must equal Bits, since
+// a replay can only reproduce samples of the recorded size.
+func (s *Source) ReadBits(ctx context.Context, bitCount int) ([]byte, error) {
+	if bitCount != s.bits {
+		return nil, fmt.Errorf("replay has %d-bit samples, %d requested", s.bits, bitCount)
+	}
+	smp, err := s.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return smp.Data, nil
+}
+
+// Close closes the capture file.
+func (s *Source) Close() error {
+	if s == nil || s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// rewind positions the reader at Offset.
+func (s *Source) rewind() error {
+	if _, err := s.f.Seek(int64(s.opts.Offset)*int64(s.bytesPer), io.SeekStart); err != nil {
+		return err
+	}
+	s.r = bufio.NewReader(s.f)
+	s.index = s.opts.Offset
+	s.lastRec = time.Time{}
+	return nil
+}
+
+// pace sleeps so that the gap since the previous sample matches the recorded
+// gap (or the interval when timestamps are unavailable).
+func (s *Source) pace(ctx context.Context, recorded time.Time) error {
+	gap := s.Interval()
+	if !recorded.IsZero() && !s.lastRec.IsZero() {
+		gap = recorded.Sub(s.lastRec)
+	}
+	s.lastRec = recorded
+	if !s.last.IsZero() && gap > 0 {
+		wait := time.Until(s.last.Add(gap))
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+	s.last = time.Now()
+	return nil
+}
+
+// readTimestamps reads the first column of a collector .csv as local times.
+// Unparseable rows yield a zero time so indices stay aligned with samples.
+func readTimestamps(path string) ([]time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var out []time.Time
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		t, _ := naming.ParseTimestamp(rec[0], time.Local)
+		out = append(out, t)
+	}
+	return out, nil
+}
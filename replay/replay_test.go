@@ -0,0 +1,181 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// capture writes a .bin capture (and its .csv when csv is not empty) and
+// returns its path.
+func capture(t *testing.T, name string, data []byte, csv string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if csv != "" {
+		if err := os.WriteFile(path[:len(path)-len(".bin")]+".csv", []byte(csv), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// indices reads n samples from s and returns their indices.
+func indices(t *testing.T, s *Source, n int) ([]int, error) {
+	t.Helper()
+	var out []int
+	for range n {
+		smp, err := s.Next(context.Background())
+		if err != nil {
+			return out, err
+		}
+		if want := []byte{byte(smp.Index)}; !bytes.Equal(smp.Data, want) {
+			t.Fatalf("sample %d holds %x, want %x", smp.Index, smp.Data, want)
+		}
+		out = append(out, smp.Index)
+	}
+	return out, nil
+}
+
+func TestOffsetAndLoop(t *testing.T) {
+	// Four 8-bit samples holding their own index.
+	path := capture(t, "20261001T130000_trng_s8_i1.bin", []byte{0, 1, 2, 3}, "")
+	tests := []struct {
+		name    string
+		opts    Options
+		reads   int
+		want    []int
+		wantEOF bool // whether the reads end in io.EOF
+	}{
+		{"from start", Options{}, 5, []int{0, 1, 2, 3}, true},
+		{"offset", Options{Offset: 2}, 3, []int{2, 3}, true},
+		{"loop", Options{Loop: true}, 6, []int{0, 1, 2, 3, 0, 1}, false},
+		{"loop from offset", Options{Offset: 2, Loop: true}, 5, []int{2, 3, 2, 3, 2}, false},
+		{"offset past the end", Options{Offset: 4, Loop: true}, 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(path, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			got, err := indices(t, s, tt.reads)
+			if eof := errors.Is(err, io.EOF); eof != tt.wantEOF || (err != nil && !eof) {
+				t.Errorf("err = %v, want EOF %v", err, tt.wantEOF)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("read samples %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBits(t *testing.T) {
+	// 12-bit samples take two bytes each, the second half padded; a
+	// trailing partial sample is ignored.
+	data := []byte{0xab, 0xc0, 0xde, 0xf0, 0x12}
+	path := capture(t, "20261001T130000_trng_s12_i1.bin", data, "")
+	s, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Bits() != 12 || s.Interval() != time.Second {
+		t.Errorf("Bits, Interval = %d, %v, want 12, 1s from the name", s.Bits(), s.Interval())
+	}
+	if _, err := s.ReadBits(context.Background(), 8); err == nil {
+		t.Error("ReadBits(8) of 12-bit samples: no error")
+	}
+	for i, want := range [][]byte{{0xab, 0xc0}, {0xde, 0xf0}} {
+		got, err := s.ReadBits(context.Background(), 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("sample %d = %x, want %x", i, got, want)
+		}
+	}
+	if _, err := s.ReadBits(context.Background(), 12); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadBits on the partial sample: err = %v, want EOF", err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	path := capture(t, "capture.bin", []byte{0}, "")
+	tests := []struct {
+		name string
+		path string
+		opts Options
+	}{
+		{"no bits in name", path, Options{}},
+		{"negative offset", path, Options{Bits: 8, Offset: -1}},
+		{"negative bits", path, Options{Bits: -8}},
+		{"missing file", filepath.Join(t.TempDir(), "20261001T130000_trng_s8_i1.bin"), Options{}},
+	}
+	for _, tt := range tests {
+		if s, err := Open(tt.path, tt.opts); err == nil {
+			s.Close()
+			t.Errorf("%s: Open succeeded", tt.name)
+		}
+	}
+	// Bits overrides a name without the convention.
+	s, err := Open(path, Options{Bits: 8})
+	if err != nil {
+		t.Fatalf("Open with Bits: %v", err)
+	}
+	s.Close()
+}
+
+func TestPacing(t *testing.T) {
+	// Recorded an hour apart: a paced replay waits, an unpaced one does not.
+	csv := "20261001T13:00:00,4\n20261001T14:00:00,4\n"
+	path := capture(t, "20261001T130000_trng_s8_i3600.bin", []byte{0x0f, 0xf0}, csv)
+
+	t.Run("off", func(t *testing.T) {
+		s, err := Open(path, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		for i := range 2 {
+			smp, err := s.Next(ctx)
+			if err != nil {
+				t.Fatalf("Next %d: %v", i, err)
+			}
+			if !smp.Recorded.IsZero() {
+				t.Errorf("sample %d recorded at %v without Realtime, want zero", i, smp.Recorded)
+			}
+		}
+	})
+
+	t.Run("on", func(t *testing.T) {
+		s, err := Open(path, Options{Realtime: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		smp, err := s.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Date(2026, 10, 1, 13, 0, 0, 0, time.Local); !smp.Recorded.Equal(want) {
+			t.Errorf("first sample recorded at %v, want %v", smp.Recorded, want)
+		}
+		if _, err := s.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("second Next: err = %v, want it to wait out the deadline", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+// Package stats holds the p-values shared by the analyses: the normal tails
+// of a z-score and the chi-square upper tail.
+package stats
+
+import "math"
+
+// TwoSidedP returns the two-sided p-value of a standard normal z-score,
+// P(|Z| >= |z|).
+func TwoSidedP(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// UpperP returns the one-sided p-value of a standard normal z-score,
+// P(Z >= z).
+func UpperP(z float64) float64 {
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// ChiSquareP returns the upper-tail probability P(X >= x) for a chi-square
+// distribution with df degrees of freedom.
+func ChiSquareP(x float64, df int) float64 {
+	if df <= 0 {
+		return math.NaN()
+	}
+	return GammaQ(float64(df)/2, x/2)
+}
+
+// GammaQ is the regularized upper incomplete gamma function Q(a, x), using
+// the series expansion below a+1 and a continued fraction above it
+// (Numerical Recipes, section 6.2).
+func GammaQ(a, x float64) float64 {
+	const (
+		eps     = 1e-14
+		tiny    = 1e-300
+		maxIter = 10_000_000
+	)
+	switch {
+	case a <= 0 || x < 0 || math.IsNaN(x):
+		return math.NaN()
+	case x == 0:
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	front := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		ap, del := a, 1/a
+		sum := del
+		for i := 0; i < maxIter; i++ {
+			ap++
+			del *= x / ap
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*eps {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*front)
+	}
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < maxIter; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return front * h
+}
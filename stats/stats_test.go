@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether got is within a relative 1e-9 of want.
+func near(got, want float64) bool {
+	return got == want || math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+func TestNormalP(t *testing.T) {
+	tests := []struct {
+		z, two, upper float64
+	}{
+		{0, 1, 0.5},
+		{1.959963984540054, 0.05, 0.025},
+		{-1.959963984540054, 0.05, 0.975},
+		{2.5758293035489004, 0.01, 0.005},
+		{math.Inf(1), 0, 0},
+		{math.Inf(-1), 0, 1},
+	}
+	for _, tt := range tests {
+		if got := TwoSidedP(tt.z); !near(got, tt.two) {
+			t.Errorf("TwoSidedP(%v) = %v, want %v", tt.z, got, tt.two)
+		}
+		if got := UpperP(tt.z); !near(got, tt.upper) {
+			t.Errorf("UpperP(%v) = %v, want %v", tt.z, got, tt.upper)
+		}
+	}
+}
+
+func TestChiSquareP(t *testing.T) {
+	tests := []struct {
+		x    float64
+		df   int
+		want float64
+	}{
+		{0, 5, 1},
+		// With 2 degrees of freedom the tail is exp(-x/2).
+		{2, 2, math.Exp(-1)},
+		{10, 2, math.Exp(-5)},
+		// With 4, it is exp(-x/2) * (1 + x/2).
+		{4, 4, 3 * math.Exp(-2)},
+		{0.5, 4, 1.25 * math.Exp(-0.25)},
+		// With 1, it is erfc(sqrt(x/2)).
+		{3.841458820694124, 1, 0.05},
+		{0.1, 1, math.Erfc(math.Sqrt(0.05))},
+		{1, 1, math.Erfc(math.Sqrt(0.5))},
+	}
+	for _, tt := range tests {
+		if got := ChiSquareP(tt.x, tt.df); !near(got, tt.want) {
+			t.Errorf("ChiSquareP(%v, %d) = %v, want %v", tt.x, tt.df, got, tt.want)
+		}
+	}
+	for _, df := range []int{0, -1} {
+		if got := ChiSquareP(1, df); !math.IsNaN(got) {
+			t.Errorf("ChiSquareP(1, %d) = %v, want NaN", df, got)
+		}
+	}
+}
+
+func TestGammaQ(t *testing.T) {
+	// Q(a, x) = e^-x * sum_{k<a} x^k/k! for integer a, on both sides of
+	// the series/continued fraction switch at x = a+1.
+	poisson := func(a int, x float64) float64 {
+		sum, term := 0.0, 1.0
+		for k := 0; k < a; k++ {
+			sum += term
+			term *= x / float64(k+1)
+		}
+		return math.Exp(-x) * sum
+	}
+	for _, a := range []int{1, 3, 10, 128} {
+		for _, x := range []float64{0.5, float64(a) / 2, float64(a), float64(a) + 1, 2 * float64(a), 3 * float64(a)} {
+			if got, want := GammaQ(float64(a), x), poisson(a, x); !near(got, want) {
+				t.Errorf("GammaQ(%d, %v) = %v, want %v", a, x, got, want)
+			}
+		}
+	}
+	if got := GammaQ(0, 1); !math.IsNaN(got) {
+		t.Errorf("GammaQ(0, 1) = %v, want NaN", got)
+	}
+	if got := GammaQ(1, -1); !math.IsNaN(got) {
+		t.Errorf("GammaQ(1, -1) = %v, want NaN", got)
+	}
+}
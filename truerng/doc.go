@@ -1,5 +1,7 @@
 // Package truerng provides utilities to detect and read random data from a
-// TrueRNG USB device presented as a serial (COM) port on Windows. It mirrors
-// the behavior of the provided Python script `truerng.py` while exposing a
-// Go-friendly API that is suitable for use in GUI applications.
+// TrueRNG USB device presented as a serial port (COMx on Windows, /dev/ttyACMx
+// on Linux). It mirrors the behavior of the provided Python script
+// `truerng.py` while exposing a Go-friendly API that is suitable for use in
+// GUI applications. Ports are recognised by declarative match rules that
+// report the device model and can be extended from a JSON file.
 package truerng
@@ -0,0 +1,49 @@
+package truerng
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// linkDirs are scanned for symlinks to serial ports. udev creates stable names
+// under /dev/serial, and vendor rules often add /dev/TrueRNG-style links.
+var linkDirs = []string{"/dev/serial/by-id", "/dev/serial/by-path", "/dev"}
+
+// portLinks maps each resolved port path to the symlinks pointing at it.
+func portLinks() map[string][]string {
+	out := make(map[string][]string)
+	for _, dir := range linkDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			link := filepath.Join(dir, e.Name())
+			target, err := filepath.EvalSymlinks(link)
+			if err != nil || !strings.HasPrefix(target, "/dev/") {
+				continue
+			}
+			out[target] = append(out[target], link)
+		}
+	}
+	return out
+}
+
+// usbProduct reads the USB product string for a tty from sysfs, since the
+// serial enumerator leaves it empty on Linux.
+func usbProduct(portPath string) string {
+	// /sys/class/tty/<name>/device links to the USB interface; its parent is the device.
+	intf, err := filepath.EvalSymlinks(filepath.Join("/sys/class/tty", filepath.Base(portPath), "device"))
+	if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(intf), "product"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,54 @@
+package truerng
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPortLinks(t *testing.T) {
+	// /dev/null stands in for the tty: the scan keeps links resolving
+	// under /dev.
+	dir := t.TempDir()
+	byID := filepath.Join(dir, "by-id")
+	byPath := filepath.Join(dir, "by-path")
+	for _, d := range []string{byID, byPath} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(dir, "file")
+	if err := os.WriteFile(other, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		filepath.Join(byID, "usb-ubld.it_TrueRNG_1234-if00"):  "/dev/null",
+		filepath.Join(byPath, "pci-0000:00:14.0-usb-0:2:1.0"): "../../../../dev/null",
+		filepath.Join(byID, "outside-dev"):                    other,
+		filepath.Join(byID, "dangling"):                       filepath.Join(dir, "missing"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(byID, "not-a-link"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := linkDirs
+	linkDirs = []string{byID, byPath, filepath.Join(dir, "absent")}
+	t.Cleanup(func() { linkDirs = saved })
+
+	got := portLinks()
+	want := []string{filepath.Join(byID, "usb-ubld.it_TrueRNG_1234-if00"), filepath.Join(byPath, "pci-0000:00:14.0-usb-0:2:1.0")}
+	if len(got) != 1 || !slices.Equal(got["/dev/null"], want) {
+		t.Fatalf("portLinks = %v, want /dev/null: %v", got, want)
+	}
+	// The by-id link identifies the port to the generic link rule.
+	p := Port{Name: "/dev/null", Links: got["/dev/null"]}
+	if m, ok := matchPort(&p); !ok || m != ModelTrueRNG {
+		t.Errorf("matchPort of the linked port = %q, %v, want %q", m, ok, ModelTrueRNG)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package truerng
+
+// portLinks returns no symlinks: stable device links are a Linux udev feature.
+func portLinks() map[string][]string { return nil }
+
+// usbProduct returns "" because the serial enumerator already reports the
+// product string on this platform.
+func usbProduct(portPath string) string { return "" }
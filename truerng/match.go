@@ -0,0 +1,265 @@
+package truerng
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// Model identifies a TrueRNG hardware model.
+type Model string
+
+const (
+	// ModelTrueRNG is a TrueRNG whose exact model could not be determined.
+	ModelTrueRNG      Model = "TrueRNG"
+	ModelTrueRNG3     Model = "TrueRNG3"
+	ModelTrueRNGPro   Model = "TrueRNGpro"
+	ModelTrueRNGProV2 Model = "TrueRNGpro V2"
+)
+
+// Rule declares how to recognise a TrueRNG serial port. Every non-empty
+// criterion must match for the rule to apply; a rule without criteria never
+// matches. VID/PID comparisons are case-insensitive hex strings (e.g. "04D8").
+// Product, Serial and Link are regular expressions.
+type Rule struct {
+	// Model reported for ports matching this rule.
+	Model Model `json:"model"`
+	// VID is the USB vendor ID.
+	VID string `json:"vid,omitempty"`
+	// PIDs lists accepted USB product IDs; empty accepts any product ID.
+	PIDs []string `json:"pids,omitempty"`
+	// Product matches the USB product string.
+	Product string `json:"product,omitempty"`
+	// Serial matches the USB serial number.
+	Serial string `json:"serial,omitempty"`
+	// Link matches the port name or any symlink resolving to it, such as
+	// /dev/serial/by-id/usb-ubld.it_TrueRNG_... or a udev-created /dev/TrueRNG.
+	Link string `json:"link,omitempty"`
+
+	product, serial, link *regexp.Regexp
+}
+
+// DefaultRules are the built-in rules, checked after any loaded via
+// LoadRules or AddRules. VID/PID rules come first so the exact model wins
+// over the looser name-based rules.
+var DefaultRules = []Rule{
+	{Model: ModelTrueRNGProV2, VID: "04D8", PIDs: []string{"EBB5"}},
+	{Model: ModelTrueRNGPro, VID: "16D0", PIDs: []string{"0AA0"}},
+	{Model: ModelTrueRNG3, VID: "04D8", PIDs: []string{"F5FE"}},
+	{Model: ModelTrueRNG, VID: "16D0", PIDs: []string{"0AA2", "0AA4"}},
+	{Model: ModelTrueRNGProV2, Product: `^TrueRNGpro ?V2`},
+	{Model: ModelTrueRNGPro, Product: `^TrueRNGpro`},
+	{Model: ModelTrueRNG, Product: "^" + DeviceNamePrefix},
+	{Model: ModelTrueRNG, Serial: "^" + DeviceNamePrefix},
+	{Model: ModelTrueRNGProV2, Link: `TrueRNGpro_?V2`},
+	{Model: ModelTrueRNGPro, Link: `TrueRNGpro`},
+	{Model: ModelTrueRNG, Link: DeviceNamePrefix},
+}
+
+var (
+	rulesMu    sync.RWMutex
+	extraRules []Rule
+)
+
+// AddRules compiles rules and registers them ahead of DefaultRules, so they
+// can both add new devices and override the model of known ones. Rules added
+// later take precedence over earlier ones.
+func AddRules(rules ...Rule) error {
+	compiled := make([]Rule, 0, len(rules))
+	for i, r := range rules {
+		c, err := r.compile()
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		compiled = append(compiled, c)
+	}
+	rulesMu.Lock()
+	extraRules = append(compiled, extraRules...)
+	rulesMu.Unlock()
+	// The new rules may pick another port for ReadBytes.
+	forgetPort("")
+	return nil
+}
+
+// LoadRules reads a JSON rules file and registers its rules with AddRules.
+// The file holds either an array of rules or an object {"rules": [...]}:
+//
+//	{"rules": [{"model": "TrueRNG3", "vid": "04D8", "pids": ["F5FE"]}]}
+//
+// A file without rules, such as an object whose "rules" key is misspelt, is
+// an error rather than silently leaving only the defaults.
+func LoadRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var wrapped struct {
+			Rules []Rule `json:"rules"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		rules = wrapped.Rules
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("%s: no rules found", path)
+	}
+	return AddRules(rules...)
+}
+
+// Port describes a serial port recognised as a TrueRNG.
+type Port struct {
+	// Name is the path used to open the port, e.g. "COM5" or "/dev/ttyACM0".
+	Name string
+	// Model is the model reported by the first matching rule.
+	Model Model
+	// Links lists symlinks resolving to Name (Linux udev, e.g. /dev/serial/by-id/...).
+	Links []string
+	// USB descriptor fields, if available.
+	VID, PID, Product, SerialNumber string
+}
+
+// FindDevices enumerates serial ports and returns those matching a rule,
+// in enumeration order.
+func FindDevices() ([]Port, error) {
+	details, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("enumerating ports: %w", err)
+	}
+	links := portLinks()
+	var out []Port
+	for _, d := range details {
+		if d == nil || d.Name == "" {
+			continue
+		}
+		p := Port{Name: d.Name, Links: links[d.Name]}
+		if d.IsUSB {
+			p.VID = strings.ToUpper(d.VID)
+			p.PID = strings.ToUpper(d.PID)
+			p.Product = d.Product
+			p.SerialNumber = d.SerialNumber
+			if p.Product == "" {
+				p.Product = usbProduct(d.Name)
+			}
+		}
+		if m, ok := matchPort(&p); ok {
+			p.Model = m
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// FindDevice returns the first detected TrueRNG port.
+func FindDevice() (Port, error) {
+	ports, err := FindDevices()
+	if err != nil {
+		return Port{}, err
+	}
+	if len(ports) == 0 {
+		return Port{}, errors.New("TrueRNG device not found")
+	}
+	return ports[0], nil
+}
+
+func matchPort(p *Port) (Model, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	for _, r := range extraRules {
+		if r.matches(p) {
+			return r.Model, true
+		}
+	}
+	for _, r := range defaultCompiled() {
+		if r.matches(p) {
+			return r.Model, true
+		}
+	}
+	return "", false
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultRules []Rule
+)
+
+// defaultCompiled compiles DefaultRules on first use. The built-in patterns
+// are known to be valid, so compilation errors are not expected.
+func defaultCompiled() []Rule {
+	defaultOnce.Do(func() {
+		for _, r := range DefaultRules {
+			if c, err := r.compile(); err == nil {
+				defaultRules = append(defaultRules, c)
+			}
+		}
+	})
+	return defaultRules
+}
+
+func (r Rule) compile() (Rule, error) {
+	if r.Model == "" {
+		return r, errors.New("model must be set")
+	}
+	if r.VID == "" && len(r.PIDs) == 0 && r.Product == "" && r.Serial == "" && r.Link == "" {
+		return r, errors.New("at least one criterion must be set")
+	}
+	var err error
+	if r.Product != "" {
+		if r.product, err = regexp.Compile(r.Product); err != nil {
+			return r, fmt.Errorf("product: %w", err)
+		}
+	}
+	if r.Serial != "" {
+		if r.serial, err = regexp.Compile(r.Serial); err != nil {
+			return r, fmt.Errorf("serial: %w", err)
+		}
+	}
+	if r.Link != "" {
+		if r.link, err = regexp.Compile(r.Link); err != nil {
+			return r, fmt.Errorf("link: %w", err)
+		}
+	}
+	return r, nil
+}
+
+func (r Rule) matches(p *Port) bool {
+	if r.VID != "" && !strings.EqualFold(r.VID, p.VID) {
+		return false
+	}
+	if len(r.PIDs) > 0 {
+		found := false
+		for _, pid := range r.PIDs {
+			if strings.EqualFold(pid, p.PID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.product != nil && !r.product.MatchString(p.Product) {
+		return false
+	}
+	if r.serial != nil && !r.serial.MatchString(p.SerialNumber) {
+		return false
+	}
+	if r.link != nil {
+		found := r.link.MatchString(p.Name)
+		for _, l := range p.Links {
+			found = found || r.link.MatchString(l)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
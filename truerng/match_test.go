@@ -0,0 +1,164 @@
+package truerng
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+		want    int
+	}{
+		{name: "array", json: `[{"model": "TrueRNG3", "vid": "04D8", "pids": ["F5FE"]}]`, want: 1},
+		{name: "object", json: `{"rules": [{"model": "TrueRNG3", "vid": "04D8"}, {"model": "TrueRNG", "link": "rng"}]}`, want: 2},
+		{name: "misspelt key", json: `{"rule": [{"model": "TrueRNG3", "vid": "04D8"}]}`, wantErr: "no rules found"},
+		{name: "empty object", json: `{}`, wantErr: "no rules found"},
+		{name: "empty array", json: `[]`, wantErr: "no rules found"},
+		{name: "no criteria", json: `[{"model": "TrueRNG3"}]`, wantErr: "at least one criterion"},
+		{name: "bad regexp", json: `[{"model": "TrueRNG3", "product": "("}]`, wantErr: "product"},
+		{name: "not json", json: `rules`, wantErr: "parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesMu.Lock()
+			extraRules = nil
+			rulesMu.Unlock()
+			t.Cleanup(func() {
+				rulesMu.Lock()
+				extraRules = nil
+				rulesMu.Unlock()
+			})
+
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := LoadRules(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadRules() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if len(extraRules) != 0 {
+					t.Fatalf("LoadRules() registered %d rules despite the error", len(extraRules))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRules() error = %v", err)
+			}
+			if len(extraRules) != tt.want {
+				t.Fatalf("LoadRules() registered %d rules, want %d", len(extraRules), tt.want)
+			}
+		})
+	}
+}
+
+// resetRules clears the rules added by a test when it ends.
+func resetRules(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		rulesMu.Lock()
+		extraRules = nil
+		rulesMu.Unlock()
+	})
+}
+
+func TestRuleMatches(t *testing.T) {
+	port := Port{
+		Name:         "/dev/ttyACM0",
+		Links:        []string{"/dev/serial/by-id/usb-ubld.it_TrueRNGpro_V2_1234-if00", "/dev/serial/by-path/pci-0000:00:14.0-usb-0:2:1.0"},
+		VID:          "04D8",
+		PID:          "EBB5",
+		Product:      "TrueRNGpro V2",
+		SerialNumber: "1234",
+	}
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"vid", Rule{VID: "04d8"}, true},
+		{"other vid", Rule{VID: "16D0"}, false},
+		{"vid and pid", Rule{VID: "04D8", PIDs: []string{"F5FE", "ebb5"}}, true},
+		{"vid and other pid", Rule{VID: "04D8", PIDs: []string{"F5FE"}}, false},
+		{"product", Rule{Product: `^TrueRNGpro ?V2`}, true},
+		{"product anchored", Rule{Product: `^V2`}, false},
+		{"serial", Rule{Serial: `^12`}, true},
+		{"port name", Rule{Link: `ttyACM\d`}, true},
+		{"by-id link", Rule{Link: `TrueRNGpro_?V2`}, true},
+		{"by-path link", Rule{Link: `by-path/pci-.*usb-0:2`}, true},
+		{"no link", Rule{Link: `TrueRNG3`}, false},
+		{"all criteria", Rule{VID: "04D8", PIDs: []string{"EBB5"}, Product: "pro", Serial: "1234", Link: "by-id"}, true},
+		{"one criterion fails", Rule{VID: "04D8", PIDs: []string{"EBB5"}, Product: "pro", Serial: "9999"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Model = ModelTrueRNG
+			r, err := tt.rule.compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.matches(&port); got != tt.want {
+				t.Errorf("%+v matches = %v, want %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		port Port
+		want Model
+	}{
+		// VID/PID rules come before the name rules, whatever the product says.
+		{"pro v2 ids", Port{VID: "04D8", PID: "EBB5", Product: "TrueRNGpro"}, ModelTrueRNGProV2},
+		{"pro ids", Port{VID: "16D0", PID: "0AA0", Product: "TrueRNG"}, ModelTrueRNGPro},
+		{"v3 ids", Port{VID: "04D8", PID: "F5FE"}, ModelTrueRNG3},
+		{"v1 ids", Port{VID: "16D0", PID: "0AA4"}, ModelTrueRNG},
+		// The more specific product pattern comes first.
+		{"pro v2 product", Port{Product: "TrueRNGpro V2"}, ModelTrueRNGProV2},
+		{"pro product", Port{Product: "TrueRNGpro"}, ModelTrueRNGPro},
+		{"generic product", Port{Product: "TrueRNG 3"}, ModelTrueRNG},
+		{"serial", Port{SerialNumber: "TrueRNG-0001"}, ModelTrueRNG},
+		{"pro v2 link", Port{Name: "/dev/ttyACM0", Links: []string{"/dev/serial/by-id/usb-ubld.it_TrueRNGpro_V2_1-if00"}}, ModelTrueRNGProV2},
+		{"udev link", Port{Name: "/dev/ttyACM0", Links: []string{"/dev/TrueRNG"}}, ModelTrueRNG},
+		{"unknown", Port{Name: "/dev/ttyUSB0", VID: "0403", PID: "6001", Product: "FT232R"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchPort(&tt.port)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("matchPort = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddedRulesTakePrecedence(t *testing.T) {
+	resetRules(t)
+	v3 := Port{VID: "04D8", PID: "F5FE"}
+	if err := AddRules(Rule{Model: "first", VID: "04D8"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := matchPort(&v3); got != "first" {
+		t.Errorf("with an added rule, matchPort = %q, want %q over the default", got, "first")
+	}
+	if err := AddRules(Rule{Model: "second", PIDs: []string{"F5FE"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := matchPort(&v3); got != "second" {
+		t.Errorf("with a later rule, matchPort = %q, want %q", got, "second")
+	}
+	if got, _ := matchPort(&Port{Product: "TrueRNGpro"}); got != ModelTrueRNGPro {
+		t.Errorf("for a port no added rule matches, matchPort = %q, want the default %q", got, ModelTrueRNGPro)
+	}
+	if err := AddRules(Rule{Model: "bad"}); err == nil {
+		t.Error("AddRules accepted a rule without criteria")
+	}
+}
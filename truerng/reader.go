@@ -0,0 +1,113 @@
+package truerng
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.bug.st/serial"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
+)
+
+// Reader keeps a TrueRNG's serial port open and reads from it as an
+// io.ReadCloser. Unlike ReadBytes and ReadBits, which find and open the port
+// on every call, it pays that cost once, so it suits many small reads and
+// consumers such as io.Copy or crypto/ecdsa.GenerateKey.
+type Reader struct {
+	port serial.Port
+	name string
+	// Timeout bounds how long Read waits for the first byte; zero means
+	// 10 seconds.
+	Timeout time.Duration
+}
+
+// Open opens the TrueRNG on portName, or the first detected device if
+// portName is "", sets DTR and flushes stale input.
+func Open(portName string) (*Reader, error) {
+	if portName == "" {
+		p, err := FindPort()
+		if err != nil {
+			return nil, err
+		}
+		portName = p
+	}
+	mode := &serial.Mode{
+		BaudRate: 3000000,
+		Parity:   serial.NoParity,
+		StopBits: serial.OneStopBit,
+	}
+	port, err := serial.Open(portName, mode)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", portName, err)
+	}
+	_ = port.SetDTR(true)
+	_ = port.SetReadTimeout(100 * time.Millisecond)
+	_ = port.ResetInputBuffer()
+	return &Reader{port: port, name: portName}, nil
+}
+
+// Port returns the port path, e.g. "COM5" or "/dev/ttyACM0".
+func (r *Reader) Port() string { return r.name }
+
+// Read reads up to len(p) bytes. Like any io.Reader it may return fewer
+// than len(p), but never 0 with a nil error: it waits until at least one
+// byte arrives, failing after Timeout. Use io.ReadFull to fill p.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.port == nil {
+		return 0, errors.New("truerng: reader is closed")
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		n, err := r.port.Read(p)
+		if err != nil {
+			return n, fmt.Errorf("read error: %w", err)
+		}
+		if n > 0 {
+			return n, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("read timeout after %s", timeout)
+		}
+	}
+}
+
+// ReadBits reads bitCount bits, zeroing the unused bits of the final byte.
+func (r *Reader) ReadBits(bitCount int) ([]byte, error) {
+	if bitCount <= 0 {
+		return nil, errors.New("bitCount must be positive")
+	}
+	buf := make([]byte, bitpack.BytesFor(bitCount))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	bitpack.Mask(buf, bitCount)
+	return buf, nil
+}
+
+// Flush discards input the port has buffered, so that the next Read
+// returns bytes the device generated after the call.
+func (r *Reader) Flush() error {
+	if r.port == nil {
+		return errors.New("truerng: reader is closed")
+	}
+	return r.port.ResetInputBuffer()
+}
+
+// Close closes the port. Further reads fail.
+func (r *Reader) Close() error {
+	if r.port == nil {
+		return nil
+	}
+	err := r.port.Close()
+	r.port = nil
+	return err
+}
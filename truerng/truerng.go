@@ -4,67 +4,107 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.bug.st/serial"
-	"go.bug.st/serial/enumerator"
+
+	"github.com/Thiagojm/rng_go_cli/bitpack"
 )
 
 // DeviceNamePrefix is the prefix used in the device name/description to
 // identify a TrueRNG serial device. Mirrors the Python logic that checks
-// description starts with "TrueRNG".
+// description starts with "TrueRNG". The generic name-based entries of
+// DefaultRules are built from it.
 const DeviceNamePrefix = "TrueRNG"
 
 // Detect returns true if a TrueRNG serial device is present on the system.
-// It enumerates available serial ports and checks their friendly name or
-// description for a TrueRNG prefix.
+// It enumerates available serial ports and checks them against the match
+// rules (see DefaultRules and AddRules).
 func Detect() (bool, error) {
-	ports, err := enumerator.GetDetailedPortsList()
+	ports, err := FindDevices()
 	if err != nil {
-		return false, fmt.Errorf("enumerating ports: %w", err)
-	}
-	for _, p := range ports {
-		if p == nil {
-			continue
-		}
-		if hasTrueRNGPrefix(p) {
-			return true, nil
-		}
+		return false, err
 	}
-	return false, nil
+	return len(ports) > 0, nil
 }
 
-// FindPort returns the first COM port path for a detected TrueRNG device, e.g.
-// "COM5" on Windows.
+// FindPort returns the first port path for a detected TrueRNG device, e.g.
+// "COM5" on Windows or "/dev/ttyACM0" on Linux.
 func FindPort() (string, error) {
-	ports, err := enumerator.GetDetailedPortsList()
+	p, err := FindDevice()
+	if err != nil {
+		return "", err
+	}
+	return p.Name, nil
+}
+
+// FindPorts returns the port paths of every detected TrueRNG device, in
+// enumeration order. An empty slice means no device is attached.
+func FindPorts() ([]string, error) {
+	ports, err := FindDevices()
 	if err != nil {
-		return "", fmt.Errorf("enumerating ports: %w", err)
+		return nil, err
 	}
+	var names []string
 	for _, p := range ports {
-		if p == nil {
-			continue
-		}
-		if hasTrueRNGPrefix(p) {
-			if p.Name != "" {
-				return p.Name, nil
-			}
-		}
+		names = append(names, p.Name)
 	}
-	return "", errors.New("TrueRNG device not found")
+	return names, nil
 }
 
+// cachedPort is the port ReadBytes reads from, or "" until one is found.
+var (
+	portMu     sync.Mutex
+	cachedPort string
+)
+
 // ReadBytes opens the TrueRNG serial port, sets DTR, flushes input, and reads
-// blockSize bytes. The behavior mirrors `truerng.py`'s read_bytes.
+// blockSize bytes. The behavior mirrors `truerng.py`'s read_bytes. The port
+// is found on the first call and reused; after an error it is looked up
+// again, so a replugged device is followed to its new port.
 func ReadBytes(blockSize int) ([]byte, error) {
 	if blockSize <= 0 {
 		return nil, errors.New("blockSize must be positive")
 	}
-	portName, err := FindPort()
+	portName, err := resolvePort()
 	if err != nil {
 		return nil, err
 	}
+	buf, err := readPort(portName, blockSize)
+	if err != nil {
+		forgetPort(portName)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// resolvePort returns the cached port, finding one if there is none.
+func resolvePort() (string, error) {
+	portMu.Lock()
+	defer portMu.Unlock()
+	if cachedPort == "" {
+		name, err := FindPort()
+		if err != nil {
+			return "", err
+		}
+		cachedPort = name
+	}
+	return cachedPort, nil
+}
+
+// forgetPort clears the cached port if it is still name, or whatever it is
+// if name is empty.
+func forgetPort(name string) {
+	portMu.Lock()
+	if name == "" || cachedPort == name {
+		cachedPort = ""
+	}
+	portMu.Unlock()
+}
 
+// readPort reads blockSize bytes from the serial port portName.
+func readPort(portName string, blockSize int) ([]byte, error) {
 	mode := &serial.Mode{
 		BaudRate: 3000000, // TrueRNG models typically support high baud; OS will clamp if unsupported
 		Parity:   serial.NoParity,
@@ -103,23 +143,18 @@ func ReadBytes(blockSize int) ([]byte, error) {
 	return buf, nil
 }
 
-// ReadBits reads bitCount bits from the TrueRNG and returns them as a byte
-// slice packed MSB-first in each byte. The final byte may be partially filled.
+// ReadBits reads bitCount bits from the TrueRNG, most significant bit first.
+// When bitCount is not a multiple of 8 the unused low bits of the final
+// byte are zero.
 func ReadBits(bitCount int) ([]byte, error) {
 	if bitCount <= 0 {
 		return nil, errors.New("bitCount must be positive")
 	}
-	byteCount := (bitCount + 7) / 8
-	data, err := ReadBytes(byteCount)
+	data, err := ReadBytes(bitpack.BytesFor(bitCount))
 	if err != nil {
 		return nil, err
 	}
-	// If bitCount is not a multiple of 8, zero out the unused trailing bits for clarity.
-	extraBits := (8 - (bitCount % 8)) % 8
-	if extraBits != 0 {
-		mask := byte(0xFF << extraBits)
-		data[len(data)-1] &= mask
-	}
+	bitpack.Mask(data, bitCount)
 	return data, nil
 }
 
@@ -161,22 +196,3 @@ func CollectBitsAtInterval(ctx context.Context, bitCount int, interval time.Dura
 		}
 	}
 }
-
-func hasTrueRNGPrefix(p *enumerator.PortDetails) bool {
-	if p == nil {
-		return false
-	}
-	if p.IsUSB && p.Product != "" && len(p.Product) >= len(DeviceNamePrefix) && p.Product[:len(DeviceNamePrefix)] == DeviceNamePrefix {
-		return true
-	}
-	if p.IsUSB && p.SerialNumber != "" && len(p.SerialNumber) >= len(DeviceNamePrefix) && p.SerialNumber[:len(DeviceNamePrefix)] == DeviceNamePrefix {
-		return true
-	}
-	if p.Name != "" && len(p.Name) >= len(DeviceNamePrefix) && p.Name[:len(DeviceNamePrefix)] == DeviceNamePrefix {
-		return true
-	}
-	if p.VID == "16D0" && (p.PID == "0AA0" || p.PID == "0AA2" || p.PID == "0AA4") { // Common TrueRNG VIDs/PIDs
-		return true
-	}
-	return false
-}
@@ -0,0 +1,42 @@
+package truerng
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadBytesForgetsPortOnError(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "ttyACM9")
+	portMu.Lock()
+	cachedPort = missing
+	portMu.Unlock()
+	t.Cleanup(func() { forgetPort("") })
+
+	// The cached port is used without enumerating the ports again.
+	_, err := ReadBytes(1)
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("ReadBytes: err = %v, want an open error for %s", err, missing)
+	}
+	portMu.Lock()
+	defer portMu.Unlock()
+	if cachedPort != "" {
+		t.Errorf("cached port after a failed read = %q, want it forgotten", cachedPort)
+	}
+}
+
+func TestAddRulesForgetsPort(t *testing.T) {
+	resetRules(t)
+	portMu.Lock()
+	cachedPort = "/dev/ttyACM0"
+	portMu.Unlock()
+	t.Cleanup(func() { forgetPort("") })
+	if err := AddRules(Rule{Model: ModelTrueRNG, VID: "1234"}); err != nil {
+		t.Fatal(err)
+	}
+	portMu.Lock()
+	defer portMu.Unlock()
+	if cachedPort != "" {
+		t.Errorf("cached port after AddRules = %q, want it forgotten", cachedPort)
+	}
+}